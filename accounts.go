@@ -0,0 +1,171 @@
+package wx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+)
+
+func WithMultipleAccounts(max int) authOpt {
+	return func(a *authServer) {
+		a.maxAccounts = max
+	}
+}
+
+type Account struct {
+	Account int    `json:"account"`
+	Subject string `json:"sub"`
+	Email   string `json:"email,omitempty"`
+	Active  bool   `json:"active"`
+}
+
+func (a *authServer) SwitchAccount(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		a.serveError(w, r, NewStatusError(http.StatusMethodNotAllowed, nil))
+		return
+	}
+
+	if err := verifySameOrigin(r); err != nil {
+		a.serveError(w, r, err)
+		return
+	}
+
+	index, err := strconv.Atoi(r.FormValue("account"))
+	if err != nil || index < 0 || index >= a.accounts() {
+		a.serveError(w, r, fmt.Errorf("%w: invalid account", ErrBadRequest))
+		return
+	}
+
+	if _, err := r.Cookie(a.accountCookieName(index)); err != nil {
+		a.serveError(w, r, fmt.Errorf("%w: account %d not signed in", ErrBadRequest, index))
+		return
+	}
+
+	redirectUri := r.FormValue("redirect_uri")
+	if redirectUri == "" {
+		redirectUri = "/"
+	}
+
+	redirectUrl, err := a.redirect(redirectUri)
+	if err != nil {
+		a.serveError(w, r, err)
+		return
+	}
+
+	a.setActiveAccount(w, index)
+
+	http.Redirect(w, r, redirectUrl.String(), http.StatusSeeOther)
+}
+
+func (a *authServer) Accounts(w http.ResponseWriter, r *http.Request) {
+
+	active := a.activeAccount(r)
+	accounts := []Account{}
+
+	for index := 0; index < a.accounts(); index++ {
+		authorization, err := a.authorizationCookie(r, a.accountCookieName(index))
+		if err != nil {
+			continue
+		}
+
+		claims, err := a.verifiedClaims(r, a.accountCookieName(index), authorization)
+		if err != nil {
+			continue
+		}
+
+		identity := NewIdentity(authorization, claims, a.roleClaim)
+		if identity.Expired() {
+			continue
+		}
+
+		accounts = append(accounts, Account{
+			Account: index,
+			Subject: identity.Subject,
+			Email:   identity.Email,
+			Active:  index == active,
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(accounts)
+}
+
+func (a *authServer) accounts() int {
+	if a.maxAccounts < 1 {
+		return 1
+	}
+	return a.maxAccounts
+}
+
+func (a *authServer) accountCookieName(index int) string {
+	if index == 0 {
+		return a.authCookieName
+	}
+	return fmt.Sprintf("%s.%d", a.authCookieName, index)
+}
+
+func (a *authServer) accountSelectorName() string {
+	return a.authCookieName + "_account"
+}
+
+func (a *authServer) activeAccount(r *http.Request) int {
+
+	cookie, err := r.Cookie(a.accountSelectorName())
+	if err != nil {
+		return 0
+	}
+
+	index, err := strconv.Atoi(cookie.Value)
+	if err != nil || index < 0 || index >= a.accounts() {
+		return 0
+	}
+
+	return index
+}
+
+func (a *authServer) sessionCookieName(r *http.Request) string {
+	return a.accountCookieName(a.activeAccount(r))
+}
+
+func (a *authServer) loginAccount(r *http.Request, add bool) (int, error) {
+
+	if !add || a.accounts() == 1 {
+		return a.activeAccount(r), nil
+	}
+
+	for index := 0; index < a.accounts(); index++ {
+		if _, err := r.Cookie(a.accountCookieName(index)); err != nil {
+			return index, nil
+		}
+	}
+
+	return 0, fmt.Errorf("%w: all %d accounts in use", ErrBadRequest, a.accounts())
+}
+
+func (a *authServer) setActiveAccount(w http.ResponseWriter, index int) {
+	if a.accounts() == 1 {
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.accountSelectorName(),
+		Value:    strconv.Itoa(index),
+		Path:     "/",
+		HttpOnly: true,
+	})
+}
+
+func (a *authServer) clearAccount(w http.ResponseWriter, index int) {
+	name := a.accountCookieName(index)
+
+	for _, cookie := range []string{name, a.scopesCookieName(name), a.authTimeCookieName(name), a.sessionMetaCookieName(name), a.claimsCookieName(name)} {
+		http.SetCookie(w, &http.Cookie{
+			Name:   cookie,
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestSwitchAccount(t *testing.T) {
+
+	a := NewAuthServer(nopLogger{}, WithMultipleAccounts(2)).(*authServer)
+
+	tests := []struct {
+		name    string
+		method  string
+		headers map[string]string
+		status  int
+	}{
+		{"get", http.MethodGet, map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusMethodNotAllowed},
+		{"same origin fetch", http.MethodPost, map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusSeeOther},
+		{"cross site fetch", http.MethodPost, map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"same site fetch", http.MethodPost, map[string]string{"Sec-Fetch-Site": "same-site"}, http.StatusForbidden},
+		{"same origin header", http.MethodPost, map[string]string{"Origin": "https://wx.example.com"}, http.StatusSeeOther},
+		{"cross origin header", http.MethodPost, map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"missing origin", http.MethodPost, nil, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(test.method, "https://wx.example.com/auth/switch", strings.NewReader("account=1&redirect_uri=/home"))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.AddCookie(&http.Cookie{Name: a.accountCookieName(1), Value: "token"})
+			for name, value := range test.headers {
+				r.Header.Set(name, value)
+			}
+
+			w := httptest.NewRecorder()
+			a.SwitchAccount(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if test.status == http.StatusSeeOther && w.Header().Get("Location") != "/home" {
+				t.Fatalf("expected redirect to /home, got %v", w.Header().Get("Location"))
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package wx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+)
+
+type Purger interface {
+	Purge(url string)
+	PurgeSurrogateKey(surrogateKey string)
+	PurgeAll()
+}
+
+type HealthCheck func(ctx context.Context) error
+
+type adminOpt func(*adminServer)
+
+func WithPurger(purger Purger) adminOpt {
+	return func(a *adminServer) {
+		a.Purgers = append(a.Purgers, purger)
+	}
+}
+
+func WithHealthCheck(name string, check HealthCheck) adminOpt {
+	return func(a *adminServer) {
+		a.HealthChecks[name] = check
+	}
+}
+
+func NewAdminServer(logger Logger, opts ...adminOpt) *adminServer {
+	server := &adminServer{
+		Logger:       logger,
+		Purgers:      []Purger{},
+		HealthChecks: map[string]HealthCheck{},
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	return server
+}
+
+type adminServer struct {
+	Logger
+	Purgers      []Purger
+	HealthChecks map[string]HealthCheck
+	maintenance  atomic.Bool
+	dashboard    *dashboardMetrics
+}
+
+func (a *adminServer) Handler() http.Handler {
+	server := http.NewServeMux()
+	server.HandleFunc("GET /admin/maintenance", a.GetMaintenance)
+	server.HandleFunc("PUT /admin/maintenance", a.SetMaintenance)
+	server.HandleFunc("POST /admin/cache/purge", a.PurgeCache)
+	server.HandleFunc("GET /admin/health", a.Health)
+
+	if a.dashboard != nil {
+		server.HandleFunc("GET /admin/dashboard", a.Dashboard)
+		server.HandleFunc("GET /admin/dashboard/data", a.DashboardData)
+	}
+	return server
+}
+
+func (a *adminServer) Maintenance(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.maintenance.Load() && !strings.HasPrefix(r.URL.Path, "/admin/") {
+			w.Header().Set("Retry-After", "120")
+			RenderError(w, r, fmt.Errorf("%w: service under maintenance", ErrServiceUnavailable))
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *adminServer) GetMaintenance(w http.ResponseWriter, r *http.Request) {
+	json.NewEncoder(w).Encode(maintenanceState{a.maintenance.Load()})
+}
+
+func (a *adminServer) SetMaintenance(w http.ResponseWriter, r *http.Request) {
+
+	if err := verifySameOrigin(r); err != nil {
+		RenderError(w, r, err)
+		return
+	}
+
+	var state maintenanceState
+	if err := json.NewDecoder(r.Body).Decode(&state); err != nil {
+		RenderError(w, r, fmt.Errorf("%w: decode maintenance state : %w", ErrBadRequest, err))
+		return
+	}
+
+	a.maintenance.Store(state.Enabled)
+	a.Logger.Infof("maintenance mode : %v", state.Enabled)
+
+	Audit(r, AuditAdminAction, map[string]string{"action": "maintenance", "enabled": fmt.Sprint(state.Enabled)})
+
+	json.NewEncoder(w).Encode(state)
+}
+
+func (a *adminServer) PurgeCache(w http.ResponseWriter, r *http.Request) {
+
+	if err := verifySameOrigin(r); err != nil {
+		RenderError(w, r, err)
+		return
+	}
+
+	url := r.FormValue("url")
+	surrogateKey := r.FormValue("key")
+	all := r.FormValue("all") == "1"
+
+	if url == "" && surrogateKey == "" && !all {
+		RenderError(w, r, fmt.Errorf("%w: url, key or all=1 required", ErrBadRequest))
+		return
+	}
+
+	for _, purger := range a.Purgers {
+		switch {
+		case url != "":
+			purger.Purge(url)
+		case surrogateKey != "":
+			purger.PurgeSurrogateKey(surrogateKey)
+		default:
+			purger.PurgeAll()
+		}
+	}
+
+	Audit(r, AuditCachePurge, map[string]string{"url": url, "key": surrogateKey, "all": fmt.Sprint(all)})
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (a *adminServer) Health(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	status, results := runHealthChecks(ctx, a.HealthChecks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
+
+func runHealthChecks(ctx context.Context, checks map[string]HealthCheck) (int, map[string]string) {
+
+	status := http.StatusOK
+	results := map[string]string{}
+
+	for name, check := range checks {
+		if err := check(ctx); err != nil {
+			status = http.StatusServiceUnavailable
+			results[name] = err.Error()
+		} else {
+			results[name] = "ok"
+		}
+	}
+
+	return status, results
+}
+
+type maintenanceState struct {
+	Enabled bool `json:"enabled"`
+}
@@ -0,0 +1,102 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+type recordingPurger struct {
+	purged []string
+}
+
+func (p *recordingPurger) Purge(url string) {
+	p.purged = append(p.purged, "url:"+url)
+}
+
+func (p *recordingPurger) PurgeSurrogateKey(surrogateKey string) {
+	p.purged = append(p.purged, "key:"+surrogateKey)
+}
+
+func (p *recordingPurger) PurgeAll() {
+	p.purged = append(p.purged, "all")
+}
+
+func TestPurgeCache(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		query  string
+		site   string
+		status int
+		purged string
+	}{
+		{"url", "url=/page", "same-origin", http.StatusNoContent, "url:/page"},
+		{"surrogate key", "key=products", "same-origin", http.StatusNoContent, "key:products"},
+		{"everything", "all=1", "same-origin", http.StatusNoContent, "all"},
+		{"everything without all", "", "same-origin", http.StatusBadRequest, ""},
+		{"cross site", "all=1", "cross-site", http.StatusForbidden, ""},
+		{"missing origin", "all=1", "", http.StatusForbidden, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			purger := &recordingPurger{}
+			admin := NewAdminServer(nopLogger{}, WithPurger(purger))
+
+			r := httptest.NewRequest(http.MethodPost, "https://wx.example.com/admin/cache/purge?"+test.query, nil)
+			if test.site != "" {
+				r.Header.Set("Sec-Fetch-Site", test.site)
+			}
+
+			w := httptest.NewRecorder()
+			admin.Handler().ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if purged := strings.Join(purger.purged, ","); purged != test.purged {
+				t.Fatalf("expected purged %q, got %q", test.purged, purged)
+			}
+		})
+	}
+}
+
+func TestSetMaintenance(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		body    string
+		site    string
+		status  int
+		enabled bool
+	}{
+		{"enable", `{"enabled": true}`, "same-origin", http.StatusOK, true},
+		{"malformed", `{"enabled":`, "same-origin", http.StatusBadRequest, false},
+		{"cross site", `{"enabled": true}`, "cross-site", http.StatusForbidden, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			admin := NewAdminServer(nopLogger{})
+
+			r := httptest.NewRequest(http.MethodPut, "https://wx.example.com/admin/maintenance", strings.NewReader(test.body))
+			r.Header.Set("Sec-Fetch-Site", test.site)
+
+			w := httptest.NewRecorder()
+			admin.Handler().ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if admin.maintenance.Load() != test.enabled {
+				t.Fatalf("expected maintenance %v, got %v", test.enabled, admin.maintenance.Load())
+			}
+		})
+	}
+}
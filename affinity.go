@@ -0,0 +1,99 @@
+package wx
+
+import (
+	"errors"
+	"hash/fnv"
+	"net/http"
+	"net/url"
+	"sync"
+)
+
+type AffinityKey func(r *http.Request) (string, bool)
+
+func ClaimAffinity(name string) AffinityKey {
+	return func(r *http.Request) (string, bool) {
+		identity, ok := UserFromContext(r.Context())
+		if !ok {
+			return "", false
+		}
+
+		values := claimValues(identity.Claims, name)
+		if len(values) == 0 {
+			return "", false
+		}
+
+		return values[0], true
+	}
+}
+
+func HeaderAffinity(name string) AffinityKey {
+	return func(r *http.Request) (string, bool) {
+		value := r.Header.Get(name)
+		return value, value != ""
+	}
+}
+
+func NewAffinitySelector(logger Logger, key AffinityKey, targets ...*url.URL) *affinitySelector {
+	return &affinitySelector{
+		Logger:  logger,
+		key:     key,
+		targets: targets,
+	}
+}
+
+type affinitySelector struct {
+	Logger
+	key     AffinityKey
+	mutex   sync.RWMutex
+	targets []*url.URL
+}
+
+func (a *affinitySelector) SetTargets(targets ...*url.URL) {
+	a.mutex.Lock()
+	defer a.mutex.Unlock()
+
+	a.targets = targets
+	a.Logger.Infof("affinity targets : %d", len(targets))
+}
+
+func (a *affinitySelector) Select(r *http.Request) (*url.URL, error) {
+
+	key, ok := a.key(r)
+	if !ok {
+		if ip, found := ClientIP(r); found {
+			key = ip.String()
+		}
+	}
+
+	a.mutex.RLock()
+	defer a.mutex.RUnlock()
+
+	if len(a.targets) == 0 {
+		return nil, NewStatusError(http.StatusServiceUnavailable, errors.New("no affinity targets"))
+	}
+
+	var selected *url.URL
+	var highest uint64
+
+	for _, target := range a.targets {
+		hash := fnv.New64a()
+		hash.Write([]byte(target.String()))
+		hash.Write([]byte{0})
+		hash.Write([]byte(key))
+
+		if score := mix64(hash.Sum64()); selected == nil || score > highest {
+			selected, highest = target, score
+		}
+	}
+
+	return selected, nil
+}
+
+func mix64(x uint64) uint64 {
+	x ^= x >> 33
+	x *= 0xff51afd7ed558ccd
+	x ^= x >> 33
+	x *= 0xc4ceb9fe1a85ec53
+	x ^= x >> 33
+	return x
+}
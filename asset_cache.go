@@ -0,0 +1,411 @@
+package wx
+
+import (
+	"bytes"
+	"context"
+	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/golang/groupcache/singleflight"
+)
+
+const (
+	hashedNameLength      = 12
+	immutableCacheControl = "public, max-age=31536000, immutable"
+)
+
+type AssetTransform func(asset string, content []byte) ([]byte, error)
+
+type assetOpt func(*assetCache)
+
+func WithFingerprinting(enabled bool) assetOpt {
+	return func(a *assetCache) {
+		a.disabled = !enabled
+	}
+}
+
+func WithHashedFilenames() assetOpt {
+	return func(a *assetCache) {
+		a.hashedFilenames = true
+	}
+}
+
+func WithTransform(ext string, transform AssetTransform) assetOpt {
+	return func(a *assetCache) {
+		a.transforms[ext] = transform
+	}
+}
+
+func WithFingerprintStore(store FingerprintStore) assetOpt {
+	return func(a *assetCache) {
+		a.store = store
+	}
+}
+
+func NewAssetCache(fs http.FileSystem, opts ...assetOpt) *assetCache {
+	return NewAssetCacheFS(NewFileSystemFS(fs), opts...)
+}
+
+func NewAssetCacheFS(fsys fs.FS, opts ...assetOpt) *assetCache {
+	cache := &assetCache{
+		fsys:       fsys,
+		cache:      map[string]assetEntry{},
+		originals:  map[string]string{},
+		transforms: map[string]AssetTransform{},
+	}
+
+	for _, opt := range opts {
+		opt(cache)
+	}
+
+	return cache
+}
+
+type assetCache struct {
+	mutex           sync.RWMutex
+	flight          singleflight.Group
+	fsys            fs.FS
+	cache           map[string]assetEntry
+	originals       map[string]string
+	transforms      map[string]AssetTransform
+	store           FingerprintStore
+	hashedFilenames bool
+	disabled        bool
+}
+
+type assetEntry struct {
+	id        string
+	integrity string
+	content   []byte
+	modTime   time.Time
+}
+
+func (self *assetCache) Asset(asset string) (string, error) {
+	if self.disabled {
+		return asset, nil
+	}
+
+	entry, err := self.entry(asset)
+	if err != nil {
+		return "", err
+	}
+
+	return self.url(asset, entry), nil
+}
+
+func (self *assetCache) AssetWithIntegrity(asset string) (string, string, error) {
+	if self.disabled {
+		return asset, "", nil
+	}
+
+	entry, err := self.entry(asset)
+	if err != nil {
+		return "", "", err
+	}
+
+	return self.url(asset, entry), entry.integrity, nil
+}
+
+func assetKey(asset string) string {
+	return "/" + strings.TrimPrefix(asset, "/")
+}
+
+func (self *assetCache) entry(asset string) (assetEntry, error) {
+
+	asset = assetKey(asset)
+
+	self.mutex.RLock()
+	entry, found := self.cache[asset]
+	self.mutex.RUnlock()
+
+	if found {
+		return entry, nil
+	}
+
+	value, err := self.flight.Do(asset, func() (interface{}, error) {
+		entry, err := self.load(asset)
+		if err != nil {
+			return nil, err
+		}
+
+		self.mutex.Lock()
+		self.cache[asset] = entry
+		self.mutex.Unlock()
+
+		return entry, nil
+	})
+	if err != nil {
+		return assetEntry{}, err
+	}
+
+	return value.(assetEntry), nil
+}
+
+func (self *assetCache) load(asset string) (assetEntry, error) {
+
+	_, transformed := self.transforms[path.Ext(asset)]
+
+	if self.store != nil && !transformed {
+		fingerprint, found, err := self.store.Load(asset)
+		if err != nil {
+			return assetEntry{}, fmt.Errorf("load fingerprint [%s] : %w", asset, err)
+		}
+
+		if found {
+			return assetEntry{id: fingerprint.ID, integrity: fingerprint.Integrity}, nil
+		}
+	}
+
+	entry, err := self.hash(asset)
+	if err != nil {
+		return assetEntry{}, err
+	}
+
+	if self.store != nil {
+		fingerprint, err := self.store.Store(asset, Fingerprint{entry.id, entry.integrity})
+		if err != nil {
+			return assetEntry{}, fmt.Errorf("store fingerprint [%s] : %w", asset, err)
+		}
+
+		entry.id, entry.integrity = fingerprint.ID, fingerprint.Integrity
+	}
+
+	return entry, nil
+}
+
+func (self *assetCache) hash(asset string) (assetEntry, error) {
+
+	file, err := self.fsys.Open(strings.TrimPrefix(asset, "/"))
+	if err != nil {
+		return assetEntry{}, fmt.Errorf("open [%s] : %w", asset, err)
+	}
+
+	defer file.Close()
+
+	var entry assetEntry
+	var reader io.Reader = file
+
+	if transform, ok := self.transforms[path.Ext(asset)]; ok {
+		info, err := file.Stat()
+		if err != nil {
+			return assetEntry{}, fmt.Errorf("stat [%s] : %w", asset, err)
+		}
+
+		contents, err := io.ReadAll(file)
+		if err != nil {
+			return assetEntry{}, fmt.Errorf("read [%s] : %w", asset, err)
+		}
+
+		if entry.content, err = transform(asset, contents); err != nil {
+			return assetEntry{}, fmt.Errorf("transform [%s] : %w", asset, err)
+		}
+
+		entry.modTime = info.ModTime()
+		reader = bytes.NewReader(entry.content)
+	}
+
+	md5Hash, sha256Hash, sha384Hash := md5.New(), sha256.New(), sha512.New384()
+
+	if _, err = io.Copy(io.MultiWriter(md5Hash, sha256Hash, sha384Hash), reader); err != nil {
+		return assetEntry{}, fmt.Errorf("hash [%s] : %w", asset, err)
+	}
+
+	entry.id = fmt.Sprintf("%x", md5Hash.Sum(nil))
+	entry.integrity = fmt.Sprintf(
+		"sha256-%s sha384-%s",
+		base64.StdEncoding.EncodeToString(sha256Hash.Sum(nil)),
+		base64.StdEncoding.EncodeToString(sha384Hash.Sum(nil)),
+	)
+
+	return entry, nil
+}
+
+func (self *assetCache) url(asset string, entry assetEntry) string {
+
+	if self.hashedFilenames {
+		hashed := hashedName(asset, entry.id)
+
+		self.mutex.Lock()
+		self.originals[hashed] = asset
+		self.mutex.Unlock()
+
+		return hashed
+	}
+
+	return fmt.Sprintf("%s?id=%s", asset, entry.id)
+}
+
+func (self *assetCache) Invalidate(asset string) error {
+
+	asset = assetKey(asset)
+
+	self.mutex.Lock()
+	delete(self.cache, asset)
+
+	for hashed, original := range self.originals {
+		if assetKey(original) == asset {
+			delete(self.originals, hashed)
+		}
+	}
+	self.mutex.Unlock()
+
+	if self.store != nil {
+		if err := self.store.Delete(asset); err != nil {
+			return fmt.Errorf("delete fingerprint [%s] : %w", asset, err)
+		}
+	}
+
+	return nil
+}
+
+func (self *assetCache) Reset() {
+	self.mutex.Lock()
+	defer self.mutex.Unlock()
+
+	self.cache = map[string]assetEntry{}
+	self.originals = map[string]string{}
+}
+
+func (self *assetCache) Watch(ctx context.Context, logger Logger, dir string) error {
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("new watcher : %w", err)
+	}
+
+	defer watcher.Close()
+
+	err = filepath.WalkDir(dir, func(path string, d fs.DirEntry, err error) error {
+		if err == nil && d.IsDir() {
+			err = watcher.Add(path)
+		}
+		return err
+	})
+	if err != nil {
+		return fmt.Errorf("watch [%s] : %w", dir, err)
+	}
+
+	for {
+		select {
+		case event := <-watcher.Events:
+			rel, err := filepath.Rel(dir, event.Name)
+			if err != nil {
+				logger.Errorf("watch [%s] : %v", event.Name, err)
+				continue
+			}
+
+			if event.Has(fsnotify.Create) {
+				if info, err := os.Stat(event.Name); err == nil && info.IsDir() {
+					watcher.Add(event.Name)
+				}
+			}
+
+			asset := assetKey(filepath.ToSlash(rel))
+			if err := self.Invalidate(asset); err != nil {
+				logger.Errorf("invalidate [%s] : %v", asset, err)
+				continue
+			}
+			logger.Debug("invalidated asset : ", asset)
+
+		case err := <-watcher.Errors:
+			logger.Errorf("watch [%s] : %v", dir, err)
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (self *assetCache) Original(hashed string) (string, bool) {
+	if self.disabled {
+		return hashed, true
+	}
+
+	self.mutex.RLock()
+	asset, found := self.originals[hashed]
+	self.mutex.RUnlock()
+
+	if found {
+		return asset, true
+	}
+
+	asset, ok := unhashedName(hashed)
+	if !ok {
+		return "", false
+	}
+
+	if expected, err := self.Asset(asset); err != nil || expected != hashed {
+		return "", false
+	}
+
+	return asset, true
+}
+
+func (self *assetCache) Handler(opts ...staticOpt) http.Handler {
+	fileServer := NewStaticServer(self.fsys, opts...)
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if !self.disabled {
+			if asset, ok := self.Original(r.URL.Path); ok {
+				r = r.Clone(r.Context())
+				r.URL.Path = asset
+				w.Header().Set("Cache-Control", immutableCacheControl)
+			} else if self.fingerprinted(r.URL.Path, r.URL.Query().Get("id")) {
+				w.Header().Set("Cache-Control", immutableCacheControl)
+			}
+		}
+
+		if _, ok := self.transforms[path.Ext(r.URL.Path)]; ok {
+			if entry, err := self.entry(r.URL.Path); err == nil {
+				http.ServeContent(w, r, r.URL.Path, entry.modTime, bytes.NewReader(entry.content))
+				return
+			}
+		}
+
+		fileServer.ServeHTTP(w, r)
+	})
+}
+
+func (self *assetCache) fingerprinted(asset string, id string) bool {
+	if id == "" {
+		return false
+	}
+
+	entry, err := self.entry(asset)
+	return err == nil && entry.id == id
+}
+
+func hashedName(asset string, id string) string {
+	ext := path.Ext(asset)
+	return fmt.Sprintf("%s.%s%s", strings.TrimSuffix(asset, ext), id[:hashedNameLength], ext)
+}
+
+func unhashedName(hashed string) (string, bool) {
+	ext := path.Ext(hashed)
+	base := strings.TrimSuffix(hashed, ext)
+
+	id := path.Ext(base)
+	if len(id) != hashedNameLength+1 {
+		return "", false
+	}
+
+	if _, err := hex.DecodeString(id[1:]); err != nil {
+		return "", false
+	}
+
+	return strings.TrimSuffix(base, id) + ext, true
+}
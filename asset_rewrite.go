@@ -0,0 +1,62 @@
+package wx
+
+import (
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+var (
+	assetTagPattern  = regexp.MustCompile(`(?i)<(?:script|link|img)\b[^>]*>`)
+	assetAttrPattern = regexp.MustCompile(`(?i)(\s(?:src|href)\s*=\s*)(?:"([^"]*)"|'([^']*)')`)
+)
+
+type AssetResolver interface {
+	Asset(asset string) (string, error)
+}
+
+func NewAssetRewriter(logger Logger, assets AssetResolver, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := NewBufferedWriter(w, bufferHTML)
+		handler.ServeHTTP(writer, r)
+
+		if !writer.Buffering() {
+			return
+		}
+
+		body := rewriteAssets(logger, assets, writer.Bytes())
+
+		if err := writer.Commit(writer.StatusCode(), body); err != nil {
+			logger.Errorf("rewrite assets : %v", err)
+		}
+	})
+}
+
+func bufferHTML(statusCode int, header http.Header) bool {
+	return strings.HasPrefix(header.Get("Content-Type"), "text/html") && header.Get("Content-Encoding") == ""
+}
+
+func rewriteAssets(logger Logger, assets AssetResolver, body []byte) []byte {
+	return assetTagPattern.ReplaceAllFunc(body, func(tag []byte) []byte {
+		return assetAttrPattern.ReplaceAllFunc(tag, func(attr []byte) []byte {
+			match := assetAttrPattern.FindSubmatch(attr)
+
+			quote, value := `"`, string(match[2])
+			if match[3] != nil {
+				quote, value = `'`, string(match[3])
+			}
+
+			if !strings.HasPrefix(value, "/") || strings.HasPrefix(value, "//") || strings.Contains(value, "?") {
+				return attr
+			}
+
+			url, err := assets.Asset(value)
+			if err != nil {
+				logger.Debug("rewrite asset : ", err)
+				return attr
+			}
+
+			return []byte(string(match[1]) + quote + url + quote)
+		})
+	})
+}
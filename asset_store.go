@@ -0,0 +1,191 @@
+package wx
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/fs"
+	"sync"
+	"time"
+)
+
+const redisCommandTimeout = 5 * time.Second
+
+type Fingerprint struct {
+	ID        string `json:"id"`
+	Integrity string `json:"integrity"`
+}
+
+type FingerprintStore interface {
+	Load(asset string) (Fingerprint, bool, error)
+	Store(asset string, fingerprint Fingerprint) (Fingerprint, error)
+	Delete(asset string) error
+}
+
+func NewManifestStore(fsys fs.FS, name string) (*manifestStore, error) {
+
+	file, err := fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("open manifest [%s] : %w", name, err)
+	}
+
+	defer file.Close()
+
+	manifest := map[string]Fingerprint{}
+	if err = json.NewDecoder(file).Decode(&manifest); err != nil {
+		return nil, fmt.Errorf("decode manifest [%s] : %w", name, err)
+	}
+
+	return &manifestStore{manifest: manifest}, nil
+}
+
+type manifestStore struct {
+	mutex    sync.RWMutex
+	manifest map[string]Fingerprint
+}
+
+func (m *manifestStore) Load(asset string) (Fingerprint, bool, error) {
+	m.mutex.RLock()
+	defer m.mutex.RUnlock()
+
+	fingerprint, found := m.manifest[asset]
+	return fingerprint, found, nil
+}
+
+func (m *manifestStore) Store(asset string, fingerprint Fingerprint) (Fingerprint, error) {
+	return fingerprint, nil
+}
+
+func (m *manifestStore) Delete(asset string) error {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	delete(m.manifest, asset)
+	return nil
+}
+
+func NewRedisFingerprintStore(addr string, prefix string, opts ...redisOpt) *redisFingerprintStore {
+	return &redisFingerprintStore{
+		addr:   addr,
+		prefix: prefix,
+		config: newRedisConfig(opts...),
+	}
+}
+
+type redisFingerprintStore struct {
+	addr   string
+	prefix string
+	config redisConfig
+}
+
+func (s *redisFingerprintStore) Load(asset string) (Fingerprint, bool, error) {
+
+	replies, err := s.do([]string{"GET", s.prefix + asset})
+	if err != nil {
+		return Fingerprint{}, false, err
+	}
+
+	return s.decode(replies[0])
+}
+
+func (s *redisFingerprintStore) Store(asset string, fingerprint Fingerprint) (Fingerprint, error) {
+
+	payload, err := json.Marshal(fingerprint)
+	if err != nil {
+		return Fingerprint{}, fmt.Errorf("marshal fingerprint : %w", err)
+	}
+
+	replies, err := s.do(
+		[]string{"SET", s.prefix + asset, string(payload), "NX"},
+		[]string{"GET", s.prefix + asset},
+	)
+	if err != nil {
+		return Fingerprint{}, err
+	}
+
+	stored, found, err := s.decode(replies[1])
+	if err != nil || !found {
+		return fingerprint, err
+	}
+
+	return stored, nil
+}
+
+func (s *redisFingerprintStore) Delete(asset string) error {
+	_, err := s.do([]string{"DEL", s.prefix + asset})
+	return err
+}
+
+func (s *redisFingerprintStore) decode(reply interface{}) (Fingerprint, bool, error) {
+
+	payload, ok := reply.(string)
+	if !ok {
+		return Fingerprint{}, false, nil
+	}
+
+	var fingerprint Fingerprint
+	if err := json.Unmarshal([]byte(payload), &fingerprint); err != nil {
+		return Fingerprint{}, false, fmt.Errorf("decode fingerprint : %w", err)
+	}
+
+	return fingerprint, true, nil
+}
+
+func (s *redisFingerprintStore) do(commands ...[]string) ([]interface{}, error) {
+
+	ctx, cancel := context.WithTimeout(context.Background(), redisCommandTimeout)
+	defer cancel()
+
+	conn, err := dialRedis(ctx, s.addr, s.config)
+	if err != nil {
+		return nil, err
+	}
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+	replies := []interface{}{}
+
+	for _, command := range commands {
+		if err := writeRESP(conn, command...); err != nil {
+			return nil, fmt.Errorf("%v : %w", command[0], err)
+		}
+
+		reply, err := readRESP(reader)
+		if err != nil {
+			return nil, fmt.Errorf("%v : %w", command[0], err)
+		}
+
+		replies = append(replies, reply)
+	}
+
+	return replies, nil
+}
+
+func (self *assetCache) WriteManifest(w io.Writer) error {
+
+	manifest := map[string]Fingerprint{}
+
+	err := fs.WalkDir(self.fsys, ".", func(name string, d fs.DirEntry, err error) error {
+		if err != nil || d.IsDir() {
+			return err
+		}
+
+		entry, err := self.entry("/" + name)
+		if err != nil {
+			return err
+		}
+
+		manifest["/"+name] = Fingerprint{entry.id, entry.integrity}
+		return nil
+	})
+	if err != nil {
+		return fmt.Errorf("walk assets : %w", err)
+	}
+
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(manifest)
+}
@@ -0,0 +1,187 @@
+package wx
+
+import (
+	"bufio"
+	"crypto/md5"
+	"fmt"
+	"net"
+	"strings"
+	"sync"
+	"testing"
+	"testing/fstest"
+)
+
+type fakeRedis struct {
+	net.Listener
+
+	mutex    sync.Mutex
+	values   map[string]string
+	commands []string
+}
+
+func newFakeRedis(t *testing.T) *fakeRedis {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	redis := &fakeRedis{Listener: listener, values: map[string]string{}}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go redis.serve(conn)
+		}
+	}()
+
+	return redis
+}
+
+func (f *fakeRedis) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	for {
+		request, err := readRESP(reader)
+		if err != nil {
+			return
+		}
+
+		args := []string{}
+		for _, arg := range request.([]interface{}) {
+			args = append(args, arg.(string))
+		}
+
+		conn.Write([]byte(f.reply(args)))
+	}
+}
+
+func (f *fakeRedis) reply(args []string) string {
+	f.mutex.Lock()
+	defer f.mutex.Unlock()
+
+	f.commands = append(f.commands, strings.Join(args, " "))
+
+	switch strings.ToUpper(args[0]) {
+	case "GET":
+		if value, ok := f.values[args[1]]; ok {
+			return fmt.Sprintf("$%d\r\n%s\r\n", len(value), value)
+		}
+		return "$-1\r\n"
+	case "SET":
+		if _, ok := f.values[args[1]]; ok && len(args) > 3 && args[3] == "NX" {
+			return "$-1\r\n"
+		}
+		f.values[args[1]] = args[2]
+		return "+OK\r\n"
+	case "DEL":
+		_, ok := f.values[args[1]]
+		delete(f.values, args[1])
+		if ok {
+			return ":1\r\n"
+		}
+		return ":0\r\n"
+	}
+
+	return "-ERR unknown command\r\n"
+}
+
+func TestSharedFingerprintStore(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		store func(t *testing.T) FingerprintStore
+	}{
+		{"redis", func(t *testing.T) FingerprintStore {
+			return NewRedisFingerprintStore(newFakeRedis(t).Addr().String(), "wx:assets:")
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			store := test.store(t)
+
+			first := fstest.MapFS{"css/app.css": {Data: []byte("body { color: red }")}}
+			second := fstest.MapFS{"css/app.css": {Data: []byte("body { color: blue }")}}
+
+			replica := NewAssetCacheFS(first, WithFingerprintStore(store))
+			other := NewAssetCacheFS(second, WithFingerprintStore(store))
+
+			url, err := replica.Asset("/css/app.css")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if shared, err := other.Asset("/css/app.css"); err != nil || shared != url {
+				t.Fatalf("expected replicas to share %v, got %v : %v", url, shared, err)
+			}
+
+			first["css/app.css"] = &fstest.MapFile{Data: []byte("body { color: green }")}
+
+			if err := replica.Invalidate("css/app.css"); err != nil {
+				t.Fatal(err)
+			}
+
+			updated, err := replica.Asset("/css/app.css")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if updated == url {
+				t.Fatalf("expected new fingerprint after invalidation, got %v", updated)
+			}
+		})
+	}
+}
+
+func TestInvalidateManifestStore(t *testing.T) {
+
+	fsys := fstest.MapFS{
+		"app.js":        {Data: []byte("console.log(1)")},
+		"manifest.json": {Data: []byte(`{"/app.js": {"id": "manifest", "integrity": "sha256-manifest"}}`)},
+	}
+
+	store, err := NewManifestStore(fsys, "manifest.json")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	cache := NewAssetCacheFS(fsys, WithFingerprintStore(store))
+
+	tests := []struct {
+		name       string
+		invalidate string
+		expected   string
+	}{
+		{"manifest fingerprint", "", "app.js?id=manifest"},
+		{"key without leading slash", "app.js", fmt.Sprintf("app.js?id=%x", md5.Sum([]byte("console.log(1)")))},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			if test.invalidate != "" {
+				if err := cache.Invalidate(test.invalidate); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			url, err := cache.Asset("app.js")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if url != test.expected {
+				t.Fatalf("expected %v, got %v", test.expected, url)
+			}
+		})
+	}
+}
@@ -0,0 +1,147 @@
+package wx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+)
+
+const contextKeyAuditor contextKey = "auditor"
+
+const (
+	AuditLogin               = "login"
+	AuditLoginFailed         = "login_failed"
+	AuditIDTokenRejected     = "id_token_rejected"
+	AuditLogout              = "logout"
+	AuditTokenRefresh        = "token_refresh"
+	AuditAuthorizationDenied = "authorization_denied"
+	AuditAdminAction         = "admin_action"
+	AuditCachePurge          = "cache_purge"
+	AuditLockout             = "lockout"
+)
+
+type AuditEvent struct {
+	Type      string            `json:"type"`
+	Subject   string            `json:"subject,omitempty"`
+	IP        string            `json:"ip,omitempty"`
+	RequestID string            `json:"request_id,omitempty"`
+	Timestamp time.Time         `json:"timestamp"`
+	Details   map[string]string `json:"details,omitempty"`
+}
+
+type AuditSink interface {
+	Audit(event AuditEvent) error
+}
+
+func NewAuditor(logger Logger, sinks ...AuditSink) *auditor {
+	return &auditor{
+		Logger: logger,
+		sinks:  sinks,
+	}
+}
+
+type auditor struct {
+	Logger
+	sinks []AuditSink
+}
+
+func (a *auditor) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKeyAuditor, a)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (a *auditor) Audit(event AuditEvent) {
+	for _, sink := range a.sinks {
+		if err := sink.Audit(event); err != nil {
+			a.Logger.Errorf("audit [%v] : %v", event.Type, err)
+		}
+	}
+}
+
+func Audit(r *http.Request, eventType string, details map[string]string) {
+	MetricsFromContext(r.Context()).Counter("auth_events_total", 1, map[string]string{"type": eventType})
+
+	auditor, ok := r.Context().Value(contextKeyAuditor).(*auditor)
+	if !ok {
+		return
+	}
+
+	auditor.Audit(NewAuditEvent(r, eventType, details))
+}
+
+func NewAuditEvent(r *http.Request, eventType string, details map[string]string) AuditEvent {
+	event := AuditEvent{
+		Type:      eventType,
+		RequestID: RequestID(r.Context()),
+		Timestamp: time.Now().UTC(),
+		Details:   details,
+	}
+
+	if ip, ok := ClientIP(r); ok {
+		event.IP = ip.String()
+	}
+
+	if identity, ok := UserFromContext(r.Context()); ok {
+		event.Subject = identity.Subject
+	}
+
+	return event
+}
+
+func NewWriterAuditSink(w io.Writer) *writerAuditSink {
+	return &writerAuditSink{
+		Writer: w,
+	}
+}
+
+type writerAuditSink struct {
+	sync.Mutex
+	io.Writer
+}
+
+func (s *writerAuditSink) Audit(event AuditEvent) error {
+	s.Lock()
+	defer s.Unlock()
+
+	return json.NewEncoder(s.Writer).Encode(event)
+}
+
+func NewWebhookAuditSink(client *http.Client, url string) *webhookAuditSink {
+	return &webhookAuditSink{
+		Client: client,
+		url:    url,
+	}
+}
+
+type webhookAuditSink struct {
+	*http.Client
+	url string
+}
+
+func (s *webhookAuditSink) Audit(event AuditEvent) error {
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event : %w", err)
+	}
+
+	resp, err := s.Client.Post(s.url, "application/json", bytes.NewReader(body))
+	if err != nil {
+		return fmt.Errorf("post event : %w", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return NewStatusError(resp.StatusCode, fmt.Errorf("post event [%v]", s.url))
+	}
+
+	return nil
+}
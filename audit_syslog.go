@@ -0,0 +1,33 @@
+//go:build !windows && !plan9
+
+package wx
+
+import (
+	"encoding/json"
+	"fmt"
+	"log/syslog"
+)
+
+func NewSyslogAuditSink(network string, raddr string, tag string) (*syslogAuditSink, error) {
+
+	writer, err := syslog.Dial(network, raddr, syslog.LOG_INFO|syslog.LOG_AUTH, tag)
+	if err != nil {
+		return nil, fmt.Errorf("dial syslog : %w", err)
+	}
+
+	return &syslogAuditSink{writer}, nil
+}
+
+type syslogAuditSink struct {
+	*syslog.Writer
+}
+
+func (s *syslogAuditSink) Audit(event AuditEvent) error {
+
+	message, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event : %w", err)
+	}
+
+	return s.Writer.Info(string(message))
+}
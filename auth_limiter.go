@@ -0,0 +1,175 @@
+package wx
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+)
+
+const (
+	authLimiterEntries    = 4096
+	authLimiterMaxLockout = 24 * time.Hour
+)
+
+type AuthLimits struct {
+	Requests    int
+	Window      time.Duration
+	MaxFailures int
+	Lockout     time.Duration
+	MaxLockout  time.Duration
+	Identifier  func(r *http.Request) string
+}
+
+func NewAuthLimiter(logger Logger, limits AuthLimits) *authLimiter {
+
+	if limits.MaxLockout <= 0 {
+		limits.MaxLockout = authLimiterMaxLockout
+	}
+
+	return &authLimiter{
+		Logger:  logger,
+		limits:  limits,
+		entries: lru.New(authLimiterEntries),
+	}
+}
+
+type authLimiter struct {
+	Logger
+
+	mutex   sync.Mutex
+	limits  AuthLimits
+	entries *lru.Cache
+}
+
+type authAttempts struct {
+	windowStart time.Time
+	requests    int
+	failures    int
+	lockouts    int
+	lockedUntil time.Time
+}
+
+func (l *authLimiter) Limit(next http.HandlerFunc) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+
+		keys := l.keys(r)
+
+		if retryAfter, limited := l.allow(keys, time.Now()); limited {
+			w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(retryAfter.Seconds()))))
+			RenderError(w, r, fmt.Errorf("%w: retry after %v", ErrTooManyRequests, retryAfter.Round(time.Second)))
+			l.Logger.Infof("auth rate limited : %v", keys)
+			return
+		}
+
+		writer := NewCountingWriter(w)
+		next.ServeHTTP(writer, r)
+
+		if writer.StatusCode() >= http.StatusBadRequest {
+			for _, key := range l.fail(keys, time.Now()) {
+				Audit(r, AuditLockout, map[string]string{"key": key, "path": r.URL.Path})
+				l.Logger.Infof("auth locked out : %v", key)
+			}
+		} else if r.URL.Query().Has("code") {
+			l.reset(keys)
+		}
+	}
+}
+
+func (l *authLimiter) keys(r *http.Request) []string {
+	keys := []string{}
+
+	if ip, ok := ClientIP(r); ok {
+		keys = append(keys, "ip:"+ip.String())
+	}
+
+	if l.limits.Identifier != nil {
+		if identifier := l.limits.Identifier(r); identifier != "" {
+			keys = append(keys, "id:"+identifier)
+		}
+	}
+
+	return keys
+}
+
+func (l *authLimiter) allow(keys []string, now time.Time) (time.Duration, bool) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, key := range keys {
+		attempts := l.attempts(key)
+
+		if now.Before(attempts.lockedUntil) {
+			return attempts.lockedUntil.Sub(now), true
+		}
+
+		if now.Sub(attempts.windowStart) >= l.limits.Window {
+			attempts.windowStart = now
+			attempts.requests = 0
+		}
+
+		attempts.requests++
+
+		if l.limits.Requests > 0 && attempts.requests > l.limits.Requests {
+			return attempts.windowStart.Add(l.limits.Window).Sub(now), true
+		}
+	}
+
+	return 0, false
+}
+
+func (l *authLimiter) fail(keys []string, now time.Time) []string {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	locked := []string{}
+
+	for _, key := range keys {
+		attempts := l.attempts(key)
+		attempts.failures++
+
+		if l.limits.MaxFailures <= 0 || attempts.failures < l.limits.MaxFailures {
+			continue
+		}
+
+		lockout := l.limits.Lockout
+		for i := 0; i < attempts.lockouts && lockout > 0 && lockout < l.limits.MaxLockout; i++ {
+			lockout *= 2
+		}
+
+		if lockout > l.limits.MaxLockout {
+			lockout = l.limits.MaxLockout
+		}
+
+		attempts.failures = 0
+		attempts.lockouts++
+		attempts.lockedUntil = now.Add(lockout)
+
+		locked = append(locked, key)
+	}
+
+	return locked
+}
+
+func (l *authLimiter) reset(keys []string) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, key := range keys {
+		l.entries.Remove(key)
+	}
+}
+
+func (l *authLimiter) attempts(key string) *authAttempts {
+	if value, ok := l.entries.Get(key); ok {
+		return value.(*authAttempts)
+	}
+
+	attempts := &authAttempts{}
+	l.entries.Add(key, attempts)
+	return attempts
+}
@@ -0,0 +1,40 @@
+package wx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestAuthLimiterLockoutBackoff(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		limits   AuthLimits
+		lockouts int
+		expected time.Duration
+	}{
+		{"first lockout", AuthLimits{MaxFailures: 1, Lockout: time.Minute, MaxLockout: time.Hour}, 1, time.Minute},
+		{"doubled lockout", AuthLimits{MaxFailures: 1, Lockout: time.Minute, MaxLockout: time.Hour}, 3, 4 * time.Minute},
+		{"capped lockout", AuthLimits{MaxFailures: 1, Lockout: time.Minute, MaxLockout: time.Hour}, 10, time.Hour},
+		{"default max lockout", AuthLimits{MaxFailures: 1, Lockout: time.Minute}, 100, authLimiterMaxLockout},
+		{"lockout above max", AuthLimits{MaxFailures: 1, Lockout: 2 * time.Hour, MaxLockout: time.Hour}, 1, time.Hour},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			l := NewAuthLimiter(nopLogger{}, test.limits)
+			now := time.Now()
+
+			for i := 0; i < test.lockouts; i++ {
+				if locked := l.fail([]string{"ip:203.0.113.7"}, now); len(locked) != 1 {
+					t.Fatalf("expected lockout %v, got %v", i+1, locked)
+				}
+			}
+
+			if lockout := l.attempts("ip:203.0.113.7").lockedUntil.Sub(now); lockout != test.expected {
+				t.Fatalf("expected lockout %v, got %v", test.expected, lockout)
+			}
+		})
+	}
+}
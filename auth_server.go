@@ -1,17 +1,37 @@
 package wx
 
 import (
+	"context"
 	"encoding/base64"
 	"encoding/json"
-	"errors"
+	"fmt"
 	"net/http"
 	"net/url"
+	"path"
 	"strings"
 	"time"
 
 	"golang.org/x/oauth2"
 )
 
+type AuthServer interface {
+	Login(w http.ResponseWriter, r *http.Request)
+	Callback(w http.ResponseWriter, r *http.Request)
+	Logout(w http.ResponseWriter, r *http.Request)
+	UserInfo(w http.ResponseWriter, r *http.Request)
+	Verify(w http.ResponseWriter, r *http.Request)
+	ForwardAuth(w http.ResponseWriter, r *http.Request)
+	Reauth(w http.ResponseWriter, r *http.Request)
+	SwitchAccount(w http.ResponseWriter, r *http.Request)
+	Accounts(w http.ResponseWriter, r *http.Request)
+	Authenticate(next http.Handler) http.Handler
+	RequireRole(role string) Middleware
+	ModifyHeader(r *http.Request) error
+	Health(ctx context.Context) error
+}
+
+var errMissingCredentials = fmt.Errorf("%w: missing credentials", ErrUnauthorized)
+
 type authOpt func(*authServer)
 
 func WithOAuthConfig(config oauth2.Config) authOpt {
@@ -32,33 +52,138 @@ func WithStateCookieName(name string) authOpt {
 	}
 }
 
-func NewAuthServer(logger Logger, opts ...authOpt) *authServer {
+func WithClientSecretSource(source SecretSource, refresh time.Duration) authOpt {
+	return func(a *authServer) {
+		a.clientSecret = source
+		a.secretRefresh = refresh
+	}
+}
+
+func WithClientSecretFile(path string) authOpt {
+	return WithClientSecretSource(NewFileSecret(path), time.Minute)
+}
+
+func WithCookieKeyring(keyring *keyring) authOpt {
+	return func(a *authServer) {
+		a.keyring = keyring
+	}
+}
+
+func WithUnverifiedSessions() authOpt {
+	return func(a *authServer) {
+		a.unverified = true
+	}
+}
+
+func WithStateStore(store StateStore) authOpt {
+	return func(a *authServer) {
+		a.stateStore = store
+	}
+}
+
+func WithRedirectAllowlist(patterns ...string) authOpt {
+	return func(a *authServer) {
+		a.redirectAllowlist = append(a.redirectAllowlist, patterns...)
+	}
+}
+
+func WithLoginPath(path string) authOpt {
+	return func(a *authServer) {
+		a.loginPath = path
+	}
+}
+
+func WithAuthCodeOptions(opts ...oauth2.AuthCodeOption) authOpt {
+	return func(a *authServer) {
+		a.authCodeOptions = opts
+	}
+}
+
+func WithRoleClaim(name string) authOpt {
+	return func(a *authServer) {
+		a.roleClaim = name
+	}
+}
+
+func NewAuthServer(logger Logger, opts ...authOpt) AuthServer {
 	server := &authServer{
 		Logger:          logger,
 		authCookieName:  "auth",
 		stateCookieName: "state",
+		roleClaim:       "roles",
+		loginPath:       "/auth/login",
+		stateStore:      NewMemoryStateStore(),
+		loginParams:     defaultLoginParams,
+		authCodeOptions: []oauth2.AuthCodeOption{oauth2.AccessTypeOffline},
 	}
 
 	for _, opt := range opts {
 		opt(server)
 	}
 
+	if server.clientSecret != nil {
+		server.clientSecret = NewCachedSecret(logger, server.clientSecret, server.secretRefresh)
+	}
+
 	return server
 }
 
 type authServer struct {
 	Logger
 	oauth2.Config
-	authCookieName  string
-	stateCookieName string
+	authCookieName    string
+	stateCookieName   string
+	roleClaim         string
+	clientSecret      SecretSource
+	secretRefresh     time.Duration
+	keyring           *keyring
+	jwks              *jwksVerifier
+	idTokens          *jwksVerifier
+	unverified        bool
+	stateStore        StateStore
+	redirectAllowlist []string
+	loginPath         string
+	identityHeaders   []IdentityHeaderStyle
+	alb               *albVerifier
+	albSigner         *albSigner
+	trustedHeaders    *TrustedHeaders
+	loginParams       []string
+	authCodeOptions   []oauth2.AuthCodeOption
+	scopeAllowlist    []string
+	maxAccounts       int
+	lifetimes         *SessionLifetimes
+	strictOIDC        *StrictOIDC
+	pkce              bool
 }
 
 func (a *authServer) Login(w http.ResponseWriter, r *http.Request) {
+	a.login(w, r)
+}
+
+func (a *authServer) login(w http.ResponseWriter, r *http.Request, forced ...oauth2.AuthCodeOption) {
+
+	params, err := a.loginParamOptions(r)
+	if err != nil {
+		a.serveError(w, r, err)
+		return
+	}
+
+	scopes, err := a.requestedScopes(r)
+	if err != nil {
+		a.serveError(w, r, err)
+		return
+	}
+
+	nonce := newRequestID()
 
-	state, err := a.encodeState(r)
+	state, err := a.encodeState(r, scopes, nonce)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		a.Logger.Error(err)
+		a.serveError(w, r, NewStatusError(http.StatusBadRequest, err))
+		return
+	}
+
+	if err := a.stateStore.Issue(r.Context(), state, time.Now().Add(stateLifetime)); err != nil {
+		a.serveError(w, r, NewStatusError(http.StatusInternalServerError, err))
 		return
 	}
 
@@ -66,11 +191,29 @@ func (a *authServer) Login(w http.ResponseWriter, r *http.Request) {
 		Name:     a.stateCookieName,
 		Value:    state,
 		Path:     "/",
-		Expires:  time.Now().Add(time.Hour),
+		Expires:  time.Now().Add(stateLifetime),
 		HttpOnly: true,
 	})
 
-	url := a.Config.AuthCodeURL(state, oauth2.AccessTypeOffline)
+	opts := append(append(append([]oauth2.AuthCodeOption{}, a.authCodeOptions...), params...), forced...)
+
+	if a.strictOIDC != nil {
+		opts = append(opts, oauth2.SetAuthURLParam("nonce", nonce))
+	}
+
+	if a.pkce {
+		challenge, err := a.setVerifier(w)
+		if err != nil {
+			a.serveError(w, r, NewStatusError(http.StatusInternalServerError, err))
+			return
+		}
+		opts = append(opts, challenge)
+	}
+
+	config := a.Config
+	config.Scopes = append(append([]string{}, a.Config.Scopes...), scopes...)
+
+	url := config.AuthCodeURL(state, opts...)
 
 	http.Redirect(w, r, url, http.StatusTemporaryRedirect)
 }
@@ -78,51 +221,128 @@ func (a *authServer) Login(w http.ResponseWriter, r *http.Request) {
 func (a *authServer) Callback(w http.ResponseWriter, r *http.Request) {
 
 	if err := a.checkError(r); err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		a.Logger.Error(err)
+		a.loginFailed(w, r, err)
 		return
 	}
 
 	state, err := a.decodeState(r)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		a.Logger.Error(err)
+		a.loginFailed(w, r, err)
 		return
 	}
 
-	redirectUrl, err := url.ParseRequestURI(state.RedirectUri)
+	http.SetCookie(w, &http.Cookie{
+		Name:   a.stateCookieName,
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	fresh, err := a.stateStore.Consume(r.Context(), r.FormValue("state"), time.Unix(state.Timestamp, 0).Add(stateLifetime))
+	if err != nil {
+		a.loginFailed(w, r, NewStatusError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if !fresh {
+		a.loginFailed(w, r, fmt.Errorf("%w: state not issued or already used", ErrBadRequest))
+		return
+	}
+
+	redirectUrl, err := a.redirect(state.RedirectUri)
+	if err != nil {
+		a.loginFailed(w, r, err)
+		return
+	}
+
+	config, err := a.oauthConfig(r.Context())
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		a.Logger.Error(err)
+		a.loginFailed(w, r, NewStatusError(http.StatusInternalServerError, err))
 		return
 	}
 
-	if redirectUrl.Host != "" {
-		w.WriteHeader(http.StatusBadRequest)
-		a.Logger.Error(errors.New("invalid redirect"))
+	exchangeOpts := []oauth2.AuthCodeOption{}
+
+	if a.pkce {
+		verifier, err := a.verifier(w, r)
+		if err != nil {
+			a.loginFailed(w, r, err)
+			return
+		}
+		exchangeOpts = append(exchangeOpts, verifier)
+	}
+
+	token, err := config.Exchange(r.Context(), r.FormValue("code"), exchangeOpts...)
+	if err != nil {
+		a.loginFailed(w, r, NewStatusError(http.StatusBadRequest, err))
 		return
 	}
 
-	token, err := a.Config.Exchange(r.Context(), r.FormValue("code"))
+	if a.strictOIDC != nil {
+		if details, err := a.validateIDToken(token, state.Nonce, config.ClientID); err != nil {
+			Audit(r, AuditIDTokenRejected, details)
+			a.loginFailed(w, r, err)
+			return
+		}
+	}
+
+	account, err := a.loginAccount(r, state.AddAccount)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		a.Logger.Error(err)
+		a.loginFailed(w, r, err)
 		return
 	}
 
+	name := a.accountCookieName(account)
+	expiry := a.cookieExpiry(token, state.Remember)
+	value := token.TokenType + " " + token.AccessToken
+
+	if err := a.setClaims(r.Context(), w, name, token, expiry); err != nil {
+		a.loginFailed(w, r, err)
+		return
+	}
+
+	if a.keyring != nil {
+		if value, err = a.keyring.Seal(value); err != nil {
+			a.loginFailed(w, r, NewStatusError(http.StatusInternalServerError, err))
+			return
+		}
+	}
+
 	http.SetCookie(w, &http.Cookie{
-		Name:     a.authCookieName,
-		Value:    token.TokenType + " " + token.AccessToken,
+		Name:     name,
+		Value:    value,
 		Path:     "/",
-		Expires:  token.Expiry,
+		Expires:  expiry,
 		HttpOnly: true,
+		SameSite: http.SameSiteLaxMode,
 	})
 
-	http.SetCookie(w, &http.Cookie{
-		Name:   a.stateCookieName,
-		Path:   "/",
-		MaxAge: -1,
-	})
+	granted := strings.Fields(fmt.Sprint(token.Extra("scope")))
+	if token.Extra("scope") == nil {
+		granted = append(append([]string{}, config.Scopes...), state.Scopes...)
+	}
+
+	if err := a.setScopes(w, name, granted, expiry); err != nil {
+		a.loginFailed(w, r, NewStatusError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if err := a.setAuthTime(w, name, token, expiry); err != nil {
+		a.loginFailed(w, r, NewStatusError(http.StatusInternalServerError, err))
+		return
+	}
+
+	if err := a.setSessionMeta(w, name, state.Remember, expiry); err != nil {
+		a.loginFailed(w, r, NewStatusError(http.StatusInternalServerError, err))
+		return
+	}
+
+	a.setActiveAccount(w, account)
+
+	if claims, err := a.claims(token.AccessToken); err == nil {
+		r = r.WithContext(ContextWithUser(r.Context(), NewIdentity(token.AccessToken, claims, a.roleClaim)))
+	}
+
+	Audit(r, AuditLogin, nil)
 
 	http.Redirect(w, r, redirectUrl.String(), http.StatusTemporaryRedirect)
 }
@@ -134,77 +354,352 @@ func (a *authServer) Logout(w http.ResponseWriter, r *http.Request) {
 		redirectUri = "/"
 	}
 
-	redirectUrl, err := url.ParseRequestURI(redirectUri)
+	redirectUrl, err := a.redirect(redirectUri)
 	if err != nil {
-		w.WriteHeader(http.StatusBadRequest)
-		a.Logger.Error(err)
+		a.serveError(w, r, err)
 		return
 	}
 
-	if redirectUrl.Host != "" {
-		w.WriteHeader(http.StatusBadRequest)
-		a.Logger.Error(errors.New("invalid redirect"))
-		return
+	active := a.activeAccount(r)
+	remaining := -1
+
+	for index := 0; index < a.accounts(); index++ {
+		if index == active || r.FormValue("all") == "true" {
+			a.clearAccount(w, index)
+		} else if _, err := r.Cookie(a.accountCookieName(index)); err == nil && remaining < 0 {
+			remaining = index
+		}
 	}
 
-	http.SetCookie(w, &http.Cookie{
-		Name:   a.authCookieName,
-		Path:   "/",
-		MaxAge: -1,
-	})
+	if remaining >= 0 {
+		a.setActiveAccount(w, remaining)
+	} else if a.accounts() > 1 {
+		http.SetCookie(w, &http.Cookie{
+			Name:   a.accountSelectorName(),
+			Path:   "/",
+			MaxAge: -1,
+		})
+	}
+
+	Audit(r, AuditLogout, nil)
 
 	http.Redirect(w, r, redirectUrl.String(), http.StatusTemporaryRedirect)
 }
 
 func (a *authServer) UserInfo(w http.ResponseWriter, r *http.Request) {
 
-	cookie, err := r.Cookie(a.authCookieName)
+	identity, err := a.identity(r)
 	if err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		a.Logger.Debug("missing authorization cookie")
+		w.WriteHeader(StatusCode(err))
+		a.Logger.Debug(err)
 		return
 	}
 
-	parts := strings.Split(cookie.Value, ".")
-	if len(parts) < 2 {
-		w.WriteHeader(http.StatusUnauthorized)
-		a.Logger.Debug("marlformed authorization cookie")
+	json.NewEncoder(w).Encode(identity.Claims)
+}
+
+func (a *authServer) Verify(w http.ResponseWriter, r *http.Request) {
+
+	identity, err := a.identity(r)
+	if err != nil {
+		w.WriteHeader(StatusCode(err))
+		a.Logger.Debug(err)
 		return
 	}
 
-	var claims map[string]interface{}
-	if err = a.decode(parts[1], &claims); err != nil {
-		w.WriteHeader(http.StatusUnauthorized)
-		a.Logger.Error(err)
+	if role := r.FormValue("role"); role != "" && !identity.HasRole(role) {
+		w.WriteHeader(http.StatusForbidden)
+		a.Logger.Debug("missing role : ", role)
 		return
 	}
 
-	json.NewEncoder(w).Encode(claims)
+	if scope := r.FormValue("scope"); scope != "" && !identity.HasScope(scope) {
+		w.WriteHeader(http.StatusForbidden)
+		a.Logger.Debug("missing scope : ", scope)
+		return
+	}
+
+	w.Header().Set("X-Auth-Request-User", identity.Subject)
+	w.Header().Set("X-Auth-Request-Email", identity.Email)
+	w.Header().Set("X-Auth-Request-Groups", strings.Join(identity.Roles, ","))
+	w.Header().Set("X-Auth-Request-Scopes", strings.Join(identity.Scopes, " "))
+	w.Header().Set("X-Auth-Request-Access-Token", strings.TrimPrefix(identity.Token, "Bearer "))
+	w.Header().Set("Authorization", identity.Token)
+	w.WriteHeader(http.StatusAccepted)
+}
+
+func (a *authServer) ForwardAuth(w http.ResponseWriter, r *http.Request) {
+
+	forwarded := r.Clone(r.Context())
+
+	if method := r.Header.Get("X-Forwarded-Method"); method != "" {
+		forwarded.Method = method
+	}
+
+	if uri := r.Header.Get("X-Forwarded-Uri"); uri != "" {
+		if forwardedUrl, err := url.ParseRequestURI(uri); err == nil {
+			forwarded.URL = forwardedUrl
+		}
+	}
+
+	identity, err := a.identity(r)
+	if err != nil {
+		ChallengeLogin(w, forwarded, a.loginPath, err)
+		a.Logger.Debug(err)
+		return
+	}
+
+	w.Header().Set("X-Forwarded-User", identity.Subject)
+	w.Header().Set("X-Forwarded-Email", identity.Email)
+	w.Header().Set("X-Forwarded-Groups", strings.Join(identity.Roles, ","))
+	w.Header().Set("Authorization", identity.Token)
+	w.WriteHeader(http.StatusOK)
+}
+
+func (a *authServer) Authenticate(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		r = a.withTrustedSource(r)
+
+		if identity, err := a.identity(r); err == nil {
+			r = r.WithContext(ContextWithUser(r.Context(), identity))
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (a *authServer) RequireRole(role string) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if role == "" {
+				RenderError(w, r, fmt.Errorf("%w: no role configured for %v", ErrForbidden, r.URL.Path))
+				a.Logger.Error("require role : empty role denies all requests")
+				return
+			}
+
+			identity, err := a.identity(r)
+			if err != nil {
+				Audit(r, AuditAuthorizationDenied, map[string]string{"path": r.URL.Path, "reason": err.Error()})
+				ChallengeLogin(w, r, a.loginPath, err)
+				a.Logger.Debug(err)
+				return
+			}
+
+			if !identity.HasRole(role) {
+				Audit(r, AuditAuthorizationDenied, map[string]string{"path": r.URL.Path, "role": role})
+				w.Header().Set("WWW-Authenticate", BearerChallenge("insufficient_scope", "scope", role))
+				RenderError(w, r, fmt.Errorf("%w: missing role %v", ErrForbidden, role))
+				a.Logger.Infof("missing role : %v", role)
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
 }
 
 func (a *authServer) ModifyHeader(r *http.Request) error {
 
-	cookie, err := r.Cookie(a.authCookieName)
+	a.stripTrustedHeaders(r)
+	a.setIdentityHeaders(r)
+
+	authorization, err := a.authorization(r)
 	if err != nil {
-		a.Logger.Debug("missing authorization cookie")
+		a.Logger.Debug(err)
 		return nil
 	}
 
-	r.Header.Add("Authorization", cookie.Value)
+	r.Header.Add("Authorization", authorization)
 	r.Header.Del("Cookie")
 	return nil
 }
 
-func (a *authServer) encodeState(r *http.Request) (string, error) {
+func (a *authServer) Health(ctx context.Context) error {
+
+	if store, ok := a.stateStore.(interface{ Health(context.Context) error }); ok {
+		if err := store.Health(ctx); err != nil {
+			return fmt.Errorf("state store : %w", err)
+		}
+	}
+
+	return nil
+}
+
+func (a *authServer) authorization(r *http.Request) (string, error) {
+	return a.authorizationCookie(r, a.sessionCookieName(r))
+}
+
+func (a *authServer) authorizationCookie(r *http.Request, name string) (string, error) {
+
+	cookie, err := r.Cookie(name)
+	if err != nil {
+		return "", errMissingCredentials
+	}
+
+	if a.keyring == nil {
+		return cookie.Value, nil
+	}
+
+	authorization, err := a.keyring.Open(cookie.Value)
+	if err != nil {
+		return "", fmt.Errorf("%w: invalid authorization cookie : %w", ErrUnauthorized, err)
+	}
+
+	return authorization, nil
+}
+
+func (a *authServer) redirect(redirectUri string) (*url.URL, error) {
+
+	redirectUrl, err := url.Parse(redirectUri)
+	if err != nil {
+		return nil, NewStatusError(http.StatusBadRequest, err)
+	}
+
+	if redirectUrl.Scheme == "" && redirectUrl.Host == "" && strings.HasPrefix(redirectUrl.Path, "/") && !strings.HasPrefix(redirectUrl.Path, "/\\") {
+		return redirectUrl, nil
+	}
+
+	for _, pattern := range a.redirectAllowlist {
+		if matchRedirect(pattern, redirectUrl) {
+			return redirectUrl, nil
+		}
+	}
+
+	return nil, fmt.Errorf("%w: invalid redirect", ErrBadRequest)
+}
+
+func (a *authServer) oauthConfig(ctx context.Context) (oauth2.Config, error) {
+	config := a.Config
+
+	if a.clientSecret != nil {
+		secret, err := a.clientSecret.Secret(ctx)
+		if err != nil {
+			return config, fmt.Errorf("client secret : %w", err)
+		}
+		config.ClientSecret = secret
+	}
+
+	return config, nil
+}
+
+func (a *authServer) loginFailed(w http.ResponseWriter, r *http.Request, err error) {
+	Audit(r, AuditLoginFailed, map[string]string{"error": err.Error()})
+	a.serveError(w, r, err)
+}
+
+func (a *authServer) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	RenderError(w, r, err)
+	a.Logger.Error(err)
+}
+
+func (a *authServer) Identity(r *http.Request) (*Identity, error) {
+	return a.identity(r)
+}
+
+func (a *authServer) identity(r *http.Request) (*Identity, error) {
+
+	if identity, ok := UserFromContext(r.Context()); ok {
+		return identity, nil
+	}
+
+	if identity, ok := a.trustedIdentity(r); ok {
+		return identity, nil
+	}
+
+	if identity, ok := a.albIdentity(r); ok {
+		return identity, nil
+	}
+
+	authorization, err := a.authorization(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if err := a.checkSessionAge(r); err != nil {
+		return nil, err
+	}
+
+	claims, err := a.verifiedClaims(r, a.sessionCookieName(r), authorization)
+	if err != nil {
+		return nil, err
+	}
+
+	identity := NewIdentity(authorization, claims, a.roleClaim)
+	identity.Scopes = a.scopes(r, claims)
+	identity.AuthTime = a.authTime(r, claims)
+
+	if identity.Expired() {
+		return nil, fmt.Errorf("%w: expired authorization cookie", ErrUnauthorized)
+	}
+
+	return identity, nil
+}
+
+func (a *authServer) verifiedClaims(r *http.Request, name string, authorization string) (map[string]interface{}, error) {
+
+	if a.jwks != nil {
+		claims, err := a.jwks.Verify(r.Context(), authorization)
+		if err != nil {
+			return nil, err
+		}
+
+		if azp, ok := claims["azp"].(string); ok && a.Config.ClientID != "" && azp != a.Config.ClientID {
+			return nil, fmt.Errorf("%w: token issued to %q", ErrUnauthorized, azp)
+		}
+
+		return claims, nil
+	}
+
+	if claims, ok := a.sealedClaims(r, name); ok {
+		return claims, nil
+	}
+
+	if a.keyring != nil || a.unverified {
+		return a.claims(authorization)
+	}
+
+	return nil, errUnverifiable
+}
+
+func (a *authServer) claims(token string) (map[string]interface{}, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) < 2 {
+		return nil, fmt.Errorf("%w: malformed authorization cookie", ErrUnauthorized)
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[1], "="))
+	if err != nil {
+		return nil, fmt.Errorf("%w: decode claims : %w", ErrUnauthorized, err)
+	}
+
+	var claims map[string]interface{}
+	if err = json.Unmarshal(payload, &claims); err != nil {
+		return nil, fmt.Errorf("%w: unmarshal claims : %w", ErrUnauthorized, err)
+	}
+
+	return claims, nil
+}
+
+func (a *authServer) encodeState(r *http.Request, scopes []string, nonce string) (string, error) {
 
 	redirectUri := r.FormValue("redirect_uri")
 	if redirectUri == "" {
 		redirectUri = "/"
 	}
 
+	if _, err := a.redirect(redirectUri); err != nil {
+		return "", err
+	}
+
 	state := State{
 		RedirectUri: redirectUri,
 		Timestamp:   time.Now().Unix(),
+		Nonce:       nonce,
+		Scopes:      scopes,
+		AddAccount:  r.FormValue("add_account") == "true",
+		Remember:    r.FormValue("remember") == "true",
 	}
 
 	return a.encode(state)
@@ -216,14 +711,22 @@ func (a *authServer) decodeState(r *http.Request) (State, error) {
 
 	cookie, err := r.Cookie(a.stateCookieName)
 	if err != nil {
-		return state, err
+		return state, fmt.Errorf("%w: missing state cookie", ErrBadRequest)
 	}
 
 	if cookie.Value != r.FormValue("state") {
-		return state, errors.New("invalid state")
+		return state, fmt.Errorf("%w: invalid state", ErrBadRequest)
+	}
+
+	if err = a.decode(cookie.Value, &state); err != nil {
+		return state, NewStatusError(http.StatusBadRequest, err)
+	}
+
+	if time.Since(time.Unix(state.Timestamp, 0)) > stateLifetime {
+		return state, fmt.Errorf("%w: expired state", ErrBadRequest)
 	}
 
-	return state, a.decode(cookie.Value, &state)
+	return state, nil
 }
 
 func (a *authServer) encode(value interface{}) (string, error) {
@@ -252,13 +755,27 @@ func (a *authServer) decode(encoded string, value interface{}) error {
 	return json.Unmarshal(decoded, &value)
 }
 
+func matchRedirect(pattern string, redirectUrl *url.URL) bool {
+
+	allowed, err := url.Parse(pattern)
+	if err != nil || allowed.Scheme != redirectUrl.Scheme {
+		return false
+	}
+
+	if matched, err := path.Match(allowed.Host, redirectUrl.Host); err != nil || !matched {
+		return false
+	}
+
+	return matchPath(allowed.Path, redirectUrl.Path)
+}
+
 func (a *authServer) checkError(r *http.Request) error {
 
 	errType := r.FormValue("error")
 	errDesc := r.FormValue("error_description")
 
 	if errType != "" {
-		return errors.New(errType + " : " + errDesc)
+		return fmt.Errorf("%w: %v : %v", ErrBadRequest, errType, errDesc)
 	}
 
 	return nil
@@ -267,4 +784,8 @@ func (a *authServer) checkError(r *http.Request) error {
 type State struct {
 	RedirectUri string
 	Timestamp   int64
+	Nonce       string
+	Scopes      []string
+	AddAccount  bool
+	Remember    bool
 }
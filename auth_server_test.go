@@ -0,0 +1,365 @@
+package wx
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/sha512"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func encodeSegment(t *testing.T, value interface{}) string {
+	t.Helper()
+
+	data, err := json.Marshal(value)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString(data)
+}
+
+func unsignedToken(t *testing.T, claims map[string]interface{}) string {
+	return encodeSegment(t, map[string]string{"alg": "none"}) + "." + encodeSegment(t, claims) + "."
+}
+
+func rsaToken(t *testing.T, key *rsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	signed := encodeSegment(t, map[string]string{"alg": "RS256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	signature, err := rsa.SignPKCS1v15(rand.Reader, key, crypto.SHA256, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func ecToken(t *testing.T, key *ecdsa.PrivateKey, kid string, claims map[string]interface{}) string {
+	t.Helper()
+
+	signed := encodeSegment(t, map[string]string{"alg": "ES256", "kid": kid}) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func jwksServer(t *testing.T, rsaKey *rsa.PrivateKey, ecKey *ecdsa.PrivateKey) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		json.NewEncoder(w).Encode(map[string]interface{}{"keys": []map[string]string{
+			{
+				"kty": "RSA",
+				"kid": "rsa",
+				"use": "sig",
+				"n":   base64.RawURLEncoding.EncodeToString(rsaKey.N.Bytes()),
+				"e":   base64.RawURLEncoding.EncodeToString(big.NewInt(int64(rsaKey.E)).Bytes()),
+			},
+			{
+				"kty": "EC",
+				"kid": "ec",
+				"crv": "P-256",
+				"x":   base64.RawURLEncoding.EncodeToString(ecKey.X.FillBytes(make([]byte, 32))),
+				"y":   base64.RawURLEncoding.EncodeToString(ecKey.Y.FillBytes(make([]byte, 32))),
+			},
+		}})
+	}))
+
+	t.Cleanup(server.Close)
+	return server
+}
+
+func TestIdentityRequiresVerifiedCookie(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := jwksServer(t, rsaKey, ecKey)
+	keyring := NewKeyring([]byte("cookie-key"))
+
+	jwksOpt := WithJWKS(jwks.URL, "https://idp.example.com", "client", time.Hour)
+
+	claims := map[string]interface{}{"iss": "https://idp.example.com", "aud": "client", "sub": "alice", "roles": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()}
+	expired := map[string]interface{}{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}
+
+	with := func(changes map[string]interface{}) map[string]interface{} {
+		changed := map[string]interface{}{}
+		for name, value := range claims {
+			changed[name] = value
+		}
+		for name, value := range changes {
+			changed[name] = value
+		}
+		return changed
+	}
+
+	es384 := func(claims map[string]interface{}) string {
+		signed := encodeSegment(t, map[string]string{"alg": "ES384", "kid": "ec"}) + "." + encodeSegment(t, claims)
+		digest := sha512.Sum384([]byte(signed))
+
+		r, s, err := ecdsa.Sign(rand.Reader, ecKey, digest[:])
+		if err != nil {
+			t.Fatal(err)
+		}
+
+		signature := make([]byte, 64)
+		r.FillBytes(signature[:32])
+		s.FillBytes(signature[32:])
+
+		return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+	}
+
+	sealed := func(value string) string {
+		sealed, err := keyring.Seal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sealed
+	}
+
+	tampered := rsaToken(t, rsaKey, "rsa", claims)
+	tampered = tampered[:len(tampered)-4] + "AAAA"
+
+	tests := []struct {
+		name    string
+		opts    []authOpt
+		cookie  string
+		subject string
+	}{
+		{"forged cookie without verification", nil, "Bearer " + unsignedToken(t, claims), ""},
+		{"unverified sessions opt in", []authOpt{WithUnverifiedSessions()}, "Bearer " + unsignedToken(t, claims), "alice"},
+		{"forged cookie with keyring", []authOpt{WithCookieKeyring(keyring)}, "Bearer " + unsignedToken(t, claims), ""},
+		{"sealed cookie with keyring", []authOpt{WithCookieKeyring(keyring)}, sealed("Bearer " + unsignedToken(t, claims)), "alice"},
+		{"sealed expired cookie", []authOpt{WithCookieKeyring(keyring)}, sealed("Bearer " + unsignedToken(t, expired)), ""},
+		{"rs256 token", []authOpt{jwksOpt}, "Bearer " + rsaToken(t, rsaKey, "rsa", claims), "alice"},
+		{"es256 token", []authOpt{jwksOpt}, "Bearer " + ecToken(t, ecKey, "ec", claims), "alice"},
+		{"tampered signature", []authOpt{jwksOpt}, "Bearer " + tampered, ""},
+		{"signed by unknown key", []authOpt{jwksOpt}, "Bearer " + rsaToken(t, otherKey, "rsa", claims), ""},
+		{"unknown kid", []authOpt{jwksOpt}, "Bearer " + rsaToken(t, rsaKey, "missing", claims), ""},
+		{"alg none", []authOpt{jwksOpt}, "Bearer " + unsignedToken(t, claims), ""},
+		{"alg mismatch", []authOpt{jwksOpt}, "Bearer " + ecToken(t, ecKey, "rsa", claims), ""},
+		{"alg does not match curve", []authOpt{jwksOpt}, "Bearer " + es384(claims), ""},
+		{"wrong issuer", []authOpt{jwksOpt}, "Bearer " + rsaToken(t, rsaKey, "rsa", with(map[string]interface{}{"iss": "https://other.example.com"})), ""},
+		{"missing issuer", []authOpt{jwksOpt}, "Bearer " + rsaToken(t, rsaKey, "rsa", with(map[string]interface{}{"iss": nil})), ""},
+		{"wrong audience", []authOpt{jwksOpt}, "Bearer " + rsaToken(t, rsaKey, "rsa", with(map[string]interface{}{"aud": "other-app"})), ""},
+		{"audience list", []authOpt{jwksOpt}, "Bearer " + rsaToken(t, rsaKey, "rsa", with(map[string]interface{}{"aud": []string{"other-app", "client"}})), "alice"},
+		{"issued to other client", []authOpt{jwksOpt, WithOAuthConfig(oauth2Config())}, "Bearer " + rsaToken(t, rsaKey, "rsa", with(map[string]interface{}{"azp": "other-app"})), ""},
+		{"issued to this client", []authOpt{jwksOpt, WithOAuthConfig(oauth2Config())}, "Bearer " + rsaToken(t, rsaKey, "rsa", with(map[string]interface{}{"azp": "client"})), "alice"},
+		{"unconfigured issuer", []authOpt{WithJWKS(jwks.URL, "", "client", time.Hour)}, "Bearer " + rsaToken(t, rsaKey, "rsa", with(map[string]interface{}{"iss": ""})), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			a := NewAuthServer(nopLogger{}, test.opts...).(*authServer)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.AddCookie(&http.Cookie{Name: "auth", Value: test.cookie})
+
+			identity, err := a.identity(r)
+
+			if test.subject == "" {
+				if err == nil {
+					t.Fatalf("expected error, got identity %v", identity.Subject)
+				}
+				if StatusCode(err) != http.StatusUnauthorized {
+					t.Fatalf("expected 401, got %v", err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error : %v", err)
+			}
+
+			if identity.Subject != test.subject {
+				t.Fatalf("expected subject %v, got %v", test.subject, identity.Subject)
+			}
+		})
+	}
+}
+
+func TestRequireRole(t *testing.T) {
+
+	keyring := NewKeyring([]byte("cookie-key"))
+	a := NewAuthServer(nopLogger{}, WithCookieKeyring(keyring)).(*authServer)
+
+	exp := time.Now().Add(time.Hour).Unix()
+	admin := "Bearer " + unsignedToken(t, map[string]interface{}{"sub": "alice", "roles": []string{"admin"}, "exp": exp})
+	user := "Bearer " + unsignedToken(t, map[string]interface{}{"sub": "bob", "roles": []string{"user"}, "exp": exp})
+
+	seal := func(value string) string {
+		sealed, err := keyring.Seal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sealed
+	}
+
+	tests := []struct {
+		name   string
+		role   string
+		cookie string
+		status int
+	}{
+		{"admin with role", "admin", seal(admin), http.StatusOK},
+		{"user without role", "admin", seal(user), http.StatusForbidden},
+		{"forged admin cookie", "admin", admin, http.StatusUnauthorized},
+		{"no cookie", "admin", "", http.StatusUnauthorized},
+		{"empty role denies admin", "", seal(admin), http.StatusForbidden},
+		{"empty role denies anyone", "", seal(user), http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			handler := a.RequireRole(test.role)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest(http.MethodGet, "/admin/", nil)
+			r.Header.Set("Accept", "application/json")
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth", Value: test.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			handler.ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+		})
+	}
+}
+
+func TestVerifyRequiresVerifiedIdentity(t *testing.T) {
+
+	keyring := NewKeyring([]byte("cookie-key"))
+	a := NewAuthServer(nopLogger{}, WithCookieKeyring(keyring)).(*authServer)
+
+	token := "Bearer " + unsignedToken(t, map[string]interface{}{"sub": "alice", "roles": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()})
+
+	sealed, err := keyring.Seal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		query  string
+		cookie string
+		status int
+		user   string
+	}{
+		{"sealed cookie", "", sealed, http.StatusAccepted, "alice"},
+		{"sealed cookie with role", "?role=admin", sealed, http.StatusAccepted, "alice"},
+		{"sealed cookie without role", "?role=owner", sealed, http.StatusForbidden, ""},
+		{"forged cookie", "", token, http.StatusUnauthorized, ""},
+		{"forged cookie with role", "?role=admin", token, http.StatusUnauthorized, ""},
+		{"no cookie", "", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, "/auth/verify"+test.query, nil)
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth", Value: test.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			a.Verify(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if user := w.Header().Get("X-Auth-Request-User"); user != test.user {
+				t.Fatalf("expected user %q, got %q", test.user, user)
+			}
+		})
+	}
+}
+
+func TestForwardAuthRequiresVerifiedIdentity(t *testing.T) {
+
+	keyring := NewKeyring([]byte("cookie-key"))
+	a := NewAuthServer(nopLogger{}, WithCookieKeyring(keyring), WithLoginPath("/auth/login")).(*authServer)
+
+	token := "Bearer " + unsignedToken(t, map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()})
+
+	sealed, err := keyring.Seal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		accept string
+		cookie string
+		status int
+		user   string
+	}{
+		{"sealed cookie", "", sealed, http.StatusOK, "alice"},
+		{"forged cookie from browser", "text/html", token, http.StatusTemporaryRedirect, ""},
+		{"forged cookie from api", "application/json", token, http.StatusUnauthorized, ""},
+		{"no cookie from api", "application/json", "", http.StatusUnauthorized, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, "/auth/forward", nil)
+			r.Header.Set("X-Forwarded-Uri", "/private")
+			if test.accept != "" {
+				r.Header.Set("Accept", test.accept)
+			}
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth", Value: test.cookie})
+			}
+
+			w := httptest.NewRecorder()
+			a.ForwardAuth(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if user := w.Header().Get("X-Forwarded-User"); user != test.user {
+				t.Fatalf("expected user %q, got %q", test.user, user)
+			}
+		})
+	}
+}
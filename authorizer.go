@@ -0,0 +1,78 @@
+package wx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type AuthorizationInput struct {
+	Method  string                 `json:"method"`
+	Path    string                 `json:"path"`
+	Headers http.Header            `json:"headers"`
+	Subject string                 `json:"subject,omitempty"`
+	Roles   []string               `json:"roles,omitempty"`
+	Claims  map[string]interface{} `json:"claims,omitempty"`
+}
+
+type Authorizer interface {
+	Authorize(ctx context.Context, input AuthorizationInput) (bool, error)
+}
+
+func NewAuthorizationInput(r *http.Request) AuthorizationInput {
+	headers := r.Header.Clone()
+	headers.Del("Cookie")
+	headers.Del("Authorization")
+
+	input := AuthorizationInput{
+		Method:  r.Method,
+		Path:    r.URL.Path,
+		Headers: headers,
+	}
+
+	if identity, ok := UserFromContext(r.Context()); ok {
+		input.Subject = identity.Subject
+		input.Roles = identity.Roles
+		input.Claims = identity.Claims
+	}
+
+	return input
+}
+
+func NewAuthorizer(logger Logger, authPath string, authorizer Authorizer) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			if strings.HasPrefix(r.URL.Path, authPath+"/") {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			allowed, err := authorizer.Authorize(r.Context(), NewAuthorizationInput(r))
+			if err != nil {
+				if !errors.As(err, new(*StatusError)) {
+					err = NewStatusError(http.StatusServiceUnavailable, err)
+				}
+				RenderError(w, r, err)
+				logger.Errorf("authorize : %v", err)
+				return
+			}
+
+			if allowed {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			Audit(r, AuditAuthorizationDenied, map[string]string{"path": r.URL.Path, "reason": "policy"})
+
+			if _, authenticated := UserFromContext(r.Context()); !authenticated {
+				ChallengeLogin(w, r, authPath+"/login", fmt.Errorf("%w: policy requires login", errMissingCredentials))
+				return
+			}
+
+			RenderError(w, r, fmt.Errorf("%w: denied by policy", ErrForbidden))
+		})
+	}
+}
@@ -0,0 +1,66 @@
+package wx
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+func NewOPAAuthorizer(client *http.Client, baseURL string, policy string) *opaAuthorizer {
+	return &opaAuthorizer{
+		Client: client,
+		url:    strings.TrimRight(baseURL, "/") + "/v1/data/" + strings.Trim(strings.ReplaceAll(policy, ".", "/"), "/"),
+	}
+}
+
+type opaAuthorizer struct {
+	*http.Client
+	url string
+}
+
+func (o *opaAuthorizer) Authorize(ctx context.Context, input AuthorizationInput) (bool, error) {
+
+	body, err := json.Marshal(map[string]interface{}{"input": input})
+	if err != nil {
+		return false, fmt.Errorf("marshal input : %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, o.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := o.Client.Do(req)
+	if err != nil {
+		return false, fmt.Errorf("query opa : %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("query opa : status %d", resp.StatusCode)
+	}
+
+	var decision struct {
+		Result interface{} `json:"result"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&decision); err != nil {
+		return false, fmt.Errorf("decode decision : %w", err)
+	}
+
+	switch result := decision.Result.(type) {
+	case bool:
+		return result, nil
+	case map[string]interface{}:
+		allow, _ := result["allow"].(bool)
+		return allow, nil
+	}
+
+	return false, nil
+}
@@ -0,0 +1,138 @@
+package wx
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+)
+
+type BufferFilter func(statusCode int, header http.Header) bool
+
+const maxBufferedResponse = 16 << 20
+
+type bufferedWriterOpt func(*bufferedWriter)
+
+func WithBufferLimit(limit int) bufferedWriterOpt {
+	return func(self *bufferedWriter) {
+		self.limit = limit
+	}
+}
+
+func NewBufferedWriter(w http.ResponseWriter, filter BufferFilter, opts ...bufferedWriterOpt) *bufferedWriter {
+	writer := &bufferedWriter{
+		ResponseWriter: w,
+		BufferFilter:   filter,
+		bytes:          bytes.NewBuffer([]byte{}),
+		limit:          maxBufferedResponse,
+	}
+
+	for _, opt := range opts {
+		opt(writer)
+	}
+
+	return writer
+}
+
+func informational(statusCode int) bool {
+	return statusCode >= 100 && statusCode < 200 && statusCode != http.StatusSwitchingProtocols
+}
+
+type bufferedWriter struct {
+	http.ResponseWriter
+	BufferFilter
+
+	bytes       *bytes.Buffer
+	statusCode  int
+	wroteHeader bool
+	buffering   bool
+	limit       int
+}
+
+func (self *bufferedWriter) WriteHeader(statusCode int) {
+	if self.wroteHeader {
+		return
+	}
+
+	if informational(statusCode) {
+		self.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	self.wroteHeader = true
+	self.statusCode = statusCode
+
+	if self.BufferFilter(statusCode, self.ResponseWriter.Header()) {
+		self.buffering = true
+		return
+	}
+
+	self.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (self *bufferedWriter) Write(bytes []byte) (int, error) {
+	if !self.wroteHeader {
+		if self.ResponseWriter.Header().Get("Content-Type") == "" {
+			self.ResponseWriter.Header().Set("Content-Type", http.DetectContentType(bytes))
+		}
+		self.WriteHeader(http.StatusOK)
+	}
+
+	if self.buffering && self.limit > 0 && self.bytes.Len()+len(bytes) > self.limit {
+		if err := self.overflow(); err != nil {
+			return 0, err
+		}
+	}
+
+	if self.buffering {
+		return self.bytes.Write(bytes)
+	}
+
+	return self.ResponseWriter.Write(bytes)
+}
+
+func (self *bufferedWriter) overflow() error {
+	self.buffering = false
+	self.ResponseWriter.WriteHeader(self.statusCode)
+
+	_, err := self.ResponseWriter.Write(self.bytes.Bytes())
+	self.bytes = bytes.NewBuffer([]byte{})
+	return err
+}
+
+func (self *bufferedWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !self.wroteHeader || self.buffering {
+		return copyBody(writerOnly{self}, r)
+	}
+
+	return copyBody(self.ResponseWriter, r)
+}
+
+func (self *bufferedWriter) Flush() {
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok && !self.buffering {
+		flusher.Flush()
+	}
+}
+
+func (self *bufferedWriter) Unwrap() http.ResponseWriter {
+	return self.ResponseWriter
+}
+
+func (self *bufferedWriter) Buffering() bool {
+	return self.buffering
+}
+
+func (self *bufferedWriter) StatusCode() int {
+	return self.statusCode
+}
+
+func (self *bufferedWriter) Bytes() []byte {
+	return self.bytes.Bytes()
+}
+
+func (self *bufferedWriter) Commit(statusCode int, body []byte) error {
+	self.ResponseWriter.Header().Del("Content-Length")
+	self.ResponseWriter.WriteHeader(statusCode)
+
+	_, err := self.ResponseWriter.Write(body)
+	return err
+}
@@ -0,0 +1,57 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestWriterWrappersUnwrap(t *testing.T) {
+
+	tests := []struct {
+		name string
+		wrap func(w http.ResponseWriter) http.ResponseWriter
+	}{
+		{"buffered writer", func(w http.ResponseWriter) http.ResponseWriter {
+			return NewBufferedWriter(w, func(int, http.Header) bool { return true })
+		}},
+		{"cache control writer", func(w http.ResponseWriter) http.ResponseWriter {
+			return NewCacheControlWriter(w, time.Minute)
+		}},
+		{"counting writer", func(w http.ResponseWriter) http.ResponseWriter {
+			return NewCountingWriter(w)
+		}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			errs := make(chan error, 2)
+
+			server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				controller := http.NewResponseController(test.wrap(w))
+				errs <- controller.SetWriteDeadline(time.Now().Add(time.Minute))
+
+				conn, _, err := controller.Hijack()
+				errs <- err
+				if err == nil {
+					conn.Close()
+				}
+			}))
+			defer server.Close()
+
+			if resp, err := http.Get(server.URL); err == nil {
+				resp.Body.Close()
+			}
+
+			if err := <-errs; err != nil {
+				t.Fatalf("set write deadline : %v", err)
+			}
+
+			if err := <-errs; err != nil {
+				t.Fatalf("hijack : %v", err)
+			}
+		})
+	}
+}
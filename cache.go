@@ -3,12 +3,22 @@ package wx
 import (
 	"bytes"
 	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/gob"
+	"encoding/hex"
 	"errors"
 	"fmt"
+	"io"
 	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
 	"time"
 
 	"github.com/golang/groupcache"
+	"github.com/golang/groupcache/lru"
 )
 
 type contextKey string
@@ -16,88 +26,542 @@ type contextKey string
 const (
 	contextKeyUrl     contextKey = "url"
 	contextKeyHeaders contextKey = "headers"
+	contextKeyMiss    contextKey = "miss"
+	contextKeyTee     contextKey = "tee"
 )
 
-func NewProxyCache(logger Logger, ttl time.Duration, getter groupcache.Getter) *proxyCache {
-	return &proxyCache{
-		Logger:   logger,
-		Duration: ttl,
-		Getter:   getter,
+const (
+	staleEntries      = 1024
+	maxCachedResponse = 32 << 20
+	cachePoolPath     = "/_groupcache/"
+	cachePeerHeader   = "X-Wx-Peer-Signature"
+	cachePeerSkew     = time.Minute
+)
+
+var errResponseTooLarge = errors.New("response too large")
+
+type cacheOpt func(*proxyCache)
+
+func WithSurrogateKeysForwarded(forwarded bool) cacheOpt {
+	return func(c *proxyCache) {
+		c.forwardSurrogateKeys = forwarded
+	}
+}
+
+func WithStaleIfError(window time.Duration) cacheOpt {
+	return func(c *proxyCache) {
+		c.staleIfError = window
+	}
+}
+
+func WithStreamingFill(enabled bool) cacheOpt {
+	return func(c *proxyCache) {
+		c.streamingFill = enabled
+	}
+}
+
+func WithLargeObjectStore(store ObjectStore) cacheOpt {
+	return func(c *proxyCache) {
+		c.largeObjects = store
+	}
+}
+
+func WithCacheBypass(handler http.Handler) cacheOpt {
+	return func(c *proxyCache) {
+		c.bypass = handler
+	}
+}
+
+func NewProxyCache(logger Logger, ttl time.Duration, getter groupcache.Getter, opts ...cacheOpt) *proxyCache {
+	cache := &proxyCache{
+		Logger:      logger,
+		Duration:    ttl,
+		Getter:      getter,
+		generations: map[string]int{},
+		surrogates:  map[string]map[string]bool{},
+		stale:       lru.New(staleEntries),
+	}
+
+	for _, opt := range opts {
+		opt(cache)
+	}
+
+	if len(cache.bypassRules) > 0 && cache.bypass == nil {
+		logger.Errorf("cache bypass rules configured without a bypass handler")
 	}
+
+	return cache
 }
 
 type proxyCache struct {
 	Logger
 	groupcache.Getter
 	time.Duration
+
+	mutex                sync.Mutex
+	epoch                int
+	generations          map[string]int
+	surrogates           map[string]map[string]bool
+	forwardSurrogateKeys bool
+	stale                *lru.Cache
+	staleIfError         time.Duration
+	streamingFill        bool
+	largeObjects         ObjectStore
+	bypass               http.Handler
+	bypassRules          []CacheBypassRule
 }
 
 func (c *proxyCache) ServeHTTP(w http.ResponseWriter, r *http.Request) {
 
-	url := r.URL.String()
+	if (r.Method != http.MethodGet && r.Method != http.MethodHead) || r.ContentLength != 0 {
+		c.serveBypass(w, r)
+		return
+	}
+
+	if c.bypass != nil && c.bypassed(r) {
+		c.serveBypass(w, r)
+		return
+	}
+
+	c.serve(w, r, r.URL.String(), c.Duration)
+}
+
+func (c *proxyCache) serveBypass(w http.ResponseWriter, r *http.Request) {
+
+	MetricsFromContext(r.Context()).Counter("cache_requests_total", 1, map[string]string{"status": "BYPASS"})
+
+	if c.bypass == nil {
+		w.Header().Set("Allow", "GET, HEAD")
+		RenderError(w, r, NewStatusError(http.StatusMethodNotAllowed, fmt.Errorf("uncacheable request [%v %v]", r.Method, r.URL)))
+		return
+	}
+
+	w.Header().Set("X-Cache", "BYPASS")
+	c.bypass.ServeHTTP(w, r)
+}
+
+func (c *proxyCache) serve(w http.ResponseWriter, r *http.Request, url string, ttl time.Duration) {
 
 	ctx := r.Context()
 	ctx = context.WithValue(ctx, contextKeyUrl, url)
 	ctx = context.WithValue(ctx, contextKeyHeaders, r.Header)
 
-	key := fmt.Sprintf("[%v]%v", time.Now().Round(c.Duration), url)
+	miss := false
+	ctx = context.WithValue(ctx, contextKeyMiss, &miss)
+
+	var tee *cacheTee
+	if c.streamingFill {
+		tee = &cacheTee{ResponseWriter: w, start: func(header http.Header, statusCode int) {
+			MetricsFromContext(r.Context()).Counter("cache_requests_total", 1, map[string]string{"status": "MISS"})
+			c.writeHeader(w, url, cacheableHeader(header), time.Now(), "MISS")
+			w.WriteHeader(statusCode)
+		}}
+		ctx = context.WithValue(ctx, contextKeyTee, tee)
+	}
+
+	generation := c.generation(url)
+	key := cacheKey(url, generation, ttl)
+
+	var objectKey string
+	if c.largeObjects != nil {
+		objectKey = largeObjectKey(generation, url)
+
+		if c.serveLargeObject(w, r, url, objectKey, "HIT") {
+			return
+		}
+
+		ctx = context.WithValue(ctx, contextKeyLargeObject, &largeObject{
+			ctx:   r.Context(),
+			store: c.largeObjects,
+			key:   objectKey,
+			ttl:   ttl,
+		})
+	}
 
 	c.Logger.Infof("fetching key : %v", key)
 
 	var data []byte
 	if err := c.Getter.Get(ctx, key, groupcache.AllocatingByteSliceSink(&data)); err != nil {
-		c.serveError(w, err)
+		if tee != nil && tee.started {
+			if !errors.Is(err, errLargeObjectStored) {
+				c.Logger.Errorf("streamed fill failed : %v : %v", key, err)
+			}
+			return
+		}
+
+		if errors.Is(err, errLargeObjectStored) && c.serveLargeObject(w, r, url, objectKey, "MISS") {
+			return
+		}
+
+		if stale, ok := c.staleEntry(url, err); ok {
+			c.Logger.Errorf("serving stale key : %v : %v", key, err)
+			ReportError(r.Context(), err, map[string]string{"component": "cache", "url": url})
+			w.Header().Set("Warning", `111 - "Revalidation Failed"`)
+			c.serveEntry(w, r, url, stale, "STALE")
+			return
+		}
+
+		MetricsFromContext(r.Context()).Counter("cache_requests_total", 1, map[string]string{"status": "ERROR"})
+		c.serveError(w, r, err)
 		return
 	}
 
 	c.Logger.Infof("found key : %v, size : %d bytes", key, len(data))
 
+	var entry cacheEntry
+	if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+		c.serveError(w, r, fmt.Errorf("decode entry [%v] : %w", key, err))
+		return
+	}
+
+	c.storeStale(url, entry)
+
+	if tee != nil && tee.started {
+		c.recordSurrogateKeys(url, entry.Header)
+		return
+	}
+
+	if miss {
+		c.serveEntry(w, r, url, entry, "MISS")
+	} else {
+		c.serveEntry(w, r, url, entry, "HIT")
+	}
+}
+
+func (c *proxyCache) serveEntry(w http.ResponseWriter, r *http.Request, url string, entry cacheEntry, status string) {
+
+	MetricsFromContext(r.Context()).Counter("cache_requests_total", 1, map[string]string{"status": status})
+
+	c.writeHeader(w, url, entry.Header, entry.Stored, status)
+
 	w.WriteHeader(http.StatusOK)
-	w.Write(data)
+	w.Write(entry.Body)
 }
 
-func (c *proxyCache) serveError(w http.ResponseWriter, err error) {
-	c.Logger.Error(err)
+func (c *proxyCache) writeHeader(w http.ResponseWriter, url string, header http.Header, stored time.Time, status string) {
 
-	var httpError *HttpError
-	if errors.As(err, &httpError) {
-		http.Error(w, err.Error(), httpError.Status())
-	} else {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+	for h, val := range header {
+		for _, v := range val {
+			w.Header().Add(h, v)
+		}
 	}
+
+	c.recordSurrogateKeys(url, header)
+
+	if !c.forwardSurrogateKeys {
+		w.Header().Del("Surrogate-Key")
+		w.Header().Del("Cache-Tag")
+	}
+
+	if w.Header().Get("Date") == "" {
+		w.Header().Set("Date", stored.UTC().Format(http.TimeFormat))
+	}
+
+	w.Header().Set("Age", strconv.Itoa(int(time.Since(stored).Seconds())))
+	w.Header().Set("X-Cache", status)
+}
+
+func (c *proxyCache) storeStale(url string, entry cacheEntry) {
+	if c.staleIfError <= 0 {
+		return
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.stale.Add(url, entry)
+}
+
+func (c *proxyCache) staleEntry(url string, err error) (cacheEntry, bool) {
+	if c.staleIfError <= 0 {
+		return cacheEntry{}, false
+	}
+
+	if StatusCode(err) < 500 {
+		return cacheEntry{}, false
+	}
+
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	value, ok := c.stale.Get(url)
+	if !ok {
+		return cacheEntry{}, false
+	}
+
+	entry := value.(cacheEntry)
+	if time.Since(entry.Stored) > c.Duration+c.staleIfError {
+		c.stale.Remove(url)
+		return cacheEntry{}, false
+	}
+
+	return entry, true
+}
+
+func (c *proxyCache) Purge(url string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.generations[url]++
+	c.stale.Remove(url)
+	c.Logger.Infof("purged key : %v", url)
+}
+
+func (c *proxyCache) PurgeSurrogateKey(surrogateKey string) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for url := range c.surrogates[surrogateKey] {
+		c.generations[url]++
+		c.stale.Remove(url)
+	}
+
+	delete(c.surrogates, surrogateKey)
+	c.Logger.Infof("purged surrogate key : %v", surrogateKey)
+}
+
+func (c *proxyCache) PurgeAll() {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	c.epoch++
+	c.generations = map[string]int{}
+	c.surrogates = map[string]map[string]bool{}
+	c.stale.Clear()
+	c.Logger.Info("purged all keys")
+}
+
+func (c *proxyCache) recordSurrogateKeys(url string, header http.Header) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	for _, surrogateKey := range surrogateKeys(header) {
+		urls, ok := c.surrogates[surrogateKey]
+		if !ok {
+			urls = map[string]bool{}
+			c.surrogates[surrogateKey] = urls
+		}
+		urls[url] = true
+	}
+}
+
+func (c *proxyCache) generation(url string) string {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	return fmt.Sprintf("%d.%d", c.epoch, c.generations[url])
+}
+
+func (c *proxyCache) Refresh(ctx context.Context, url string, header http.Header) error {
+
+	c.mutex.Lock()
+	epoch, current := c.epoch, c.generations[url]
+	c.mutex.Unlock()
+
+	generation := fmt.Sprintf("%d.%d", epoch, current+1)
+	key := cacheKey(url, generation, c.Duration)
+
+	if header == nil {
+		header = http.Header{}
+	}
+
+	ctx = context.WithValue(ctx, contextKeyUrl, url)
+	ctx = context.WithValue(ctx, contextKeyHeaders, header)
+
+	if c.largeObjects != nil {
+		ctx = context.WithValue(ctx, contextKeyLargeObject, &largeObject{
+			ctx:   ctx,
+			store: c.largeObjects,
+			key:   largeObjectKey(generation, url),
+			ttl:   c.Duration,
+		})
+	}
+
+	var data []byte
+	if err := c.Getter.Get(ctx, key, groupcache.AllocatingByteSliceSink(&data)); err != nil && !errors.Is(err, errLargeObjectStored) {
+		return fmt.Errorf("fill [%v] : %w", key, err)
+	}
+
+	var entry cacheEntry
+	if len(data) > 0 {
+		if err := gob.NewDecoder(bytes.NewReader(data)).Decode(&entry); err != nil {
+			return fmt.Errorf("decode entry [%v] : %w", key, err)
+		}
+	}
+
+	c.mutex.Lock()
+	if c.epoch == epoch && c.generations[url] == current {
+		c.generations[url] = current + 1
+	}
+	c.mutex.Unlock()
+
+	if entry.Header != nil {
+		c.storeStale(url, entry)
+		c.recordSurrogateKeys(url, entry.Header)
+	}
+
+	c.Logger.Infof("refreshed key : %v", key)
+	return nil
+}
+
+func cacheKey(url string, generation string, ttl time.Duration) string {
+	return fmt.Sprintf("[%v][%v]%v", time.Now().Round(ttl), generation, url)
+}
+
+func (c *proxyCache) serveError(w http.ResponseWriter, r *http.Request, err error) {
+	c.Logger.Error(err)
+	RenderError(w, r, err)
 }
 
-func NewGroupCache(handler http.Handler) groupcache.Getter {
-	getter := NewCacheGetter(handler)
+func NewGroupCache(handler http.Handler, opts ...cacheGetterOpt) groupcache.Getter {
+	getter := NewCacheGetter(handler, opts...)
 	return groupcache.NewGroup("cache", 64<<20, getter) //64MB
 }
 
+func NewCachePool(self string, secret []byte, peers ...string) (*cachePool, error) {
+
+	if len(secret) == 0 {
+		return nil, errors.New("cache pool : peer secret is empty")
+	}
+
+	pool := &cachePool{
+		HTTPPool: groupcache.NewHTTPPoolOpts(self, &groupcache.HTTPPoolOptions{BasePath: cachePoolPath}),
+		secret:   secret,
+	}
+
+	pool.Transport = func(ctx context.Context) http.RoundTripper {
+		return &peerTransport{RoundTripper: http.DefaultTransport, pool: pool}
+	}
+
+	pool.Set(append([]string{self}, peers...)...)
+	return pool, nil
+}
+
+type cachePool struct {
+	*groupcache.HTTPPool
+	secret []byte
+}
+
+func (p *cachePool) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	timestamp, signature, _ := strings.Cut(r.Header.Get(cachePeerHeader), ".")
+
+	issued, err := strconv.ParseInt(timestamp, 10, 64)
+	if err != nil || time.Since(time.Unix(issued, 0)).Abs() > cachePeerSkew {
+		RenderError(w, r, fmt.Errorf("%w: missing or expired peer signature", ErrUnauthorized))
+		return
+	}
+
+	if !hmac.Equal([]byte(signature), []byte(p.sign(timestamp, r.URL.EscapedPath()))) {
+		RenderError(w, r, fmt.Errorf("%w: invalid peer signature", ErrUnauthorized))
+		return
+	}
+
+	p.HTTPPool.ServeHTTP(w, r)
+}
+
+func (p *cachePool) sign(timestamp string, path string) string {
+	mac := hmac.New(sha256.New, p.secret)
+	mac.Write([]byte(timestamp + "." + path))
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+type peerTransport struct {
+	http.RoundTripper
+	pool *cachePool
+}
+
+func (t *peerTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req = req.Clone(req.Context())
+	req.Header.Set(cachePeerHeader, timestamp+"."+t.pool.sign(timestamp, req.URL.EscapedPath()))
+
+	return t.RoundTripper.RoundTrip(req)
+}
+
 type cacheWriter struct {
 	groupcache.Sink
 
 	header     http.Header
 	bytes      *bytes.Buffer
 	statusCode int
+	limit      int
+	overflow   bool
+	tee        *cacheTee
+	large      *largeObject
+	spill      *largeObjectSpill
 }
 
-func NewCacheGetter(handler http.Handler) *cacheGetter {
-	return &cacheGetter{
-		Handler: handler,
+type cacheGetterOpt func(*cacheGetter)
+
+func WithMaxResponseSize(size int) cacheGetterOpt {
+	return func(c *cacheGetter) {
+		c.maxResponseSize = size
+	}
+}
+
+func WithMaxConcurrentFills(fills int, timeout time.Duration) cacheGetterOpt {
+	return func(c *cacheGetter) {
+		c.fills = nil
+		if fills > 0 {
+			c.fills = make(chan struct{}, fills)
+		}
+		c.fillTimeout = timeout
+	}
+}
+
+func NewCacheGetter(handler http.Handler, opts ...cacheGetterOpt) *cacheGetter {
+	getter := &cacheGetter{
+		Handler:         handler,
+		maxResponseSize: maxCachedResponse,
+	}
+
+	for _, opt := range opts {
+		opt(getter)
 	}
+
+	return getter
 }
 
 type cacheGetter struct {
 	http.Handler
+
+	fills           chan struct{}
+	fillTimeout     time.Duration
+	maxResponseSize int
 }
 
 func (c *cacheGetter) Get(ctx context.Context, key string, dest groupcache.Sink) error {
 
-	req, err := c.createRequest(ctx)
+	req, err := c.createRequest(ctx, key)
 	if err != nil {
 		return fmt.Errorf("create request [%v] : %w", key, err)
 	}
 
+	if miss, ok := ctx.Value(contextKeyMiss).(*bool); ok {
+		*miss = true
+	}
+
+	release, err := c.acquire(ctx)
+	if err != nil {
+		return fmt.Errorf("acquire fill [%v] : %w", key, err)
+	}
+
+	defer release()
+
 	writer := NewCacheWriter(dest)
+	writer.limit = c.maxResponseSize
+
+	if tee, ok := ctx.Value(contextKeyTee).(*cacheTee); ok {
+		writer.tee = tee
+	}
+
+	if large, ok := ctx.Value(contextKeyLargeObject).(*largeObject); ok {
+		writer.large = large
+	}
+
 	c.Handler.ServeHTTP(writer, req)
 
 	if err = writer.WriteCache(); err != nil {
@@ -107,16 +571,65 @@ func (c *cacheGetter) Get(ctx context.Context, key string, dest groupcache.Sink)
 	return nil
 }
 
-func (c *cacheGetter) createRequest(ctx context.Context) (*http.Request, error) {
+func (c *cacheGetter) acquire(ctx context.Context) (func(), error) {
+	if c.fills == nil {
+		return func() {}, nil
+	}
+
+	metrics := MetricsFromContext(ctx)
+	start := time.Now()
+
+	var timeout <-chan time.Time
+	if c.fillTimeout > 0 {
+		timer := time.NewTimer(c.fillTimeout)
+		defer timer.Stop()
+		timeout = timer.C
+	}
+
+	select {
+	case c.fills <- struct{}{}:
+	case <-timeout:
+		return nil, fmt.Errorf("%w: timed out waiting for origin fill", ErrServiceUnavailable)
+	case <-ctx.Done():
+		return nil, ctx.Err()
+	}
+
+	metrics.Histogram("cache_fill_wait_seconds", time.Since(start).Seconds(), nil)
+	metrics.Gauge("cache_fills_in_flight", float64(len(c.fills)), nil)
+
+	return func() {
+		<-c.fills
+		metrics.Gauge("cache_fills_in_flight", float64(len(c.fills)), nil)
+	}, nil
+}
+
+func (c *cacheGetter) createRequest(ctx context.Context, key string) (*http.Request, error) {
 
 	url, ok := ctx.Value(contextKeyUrl).(string)
 	if !ok {
-		return nil, fmt.Errorf("url missing from context")
+		if url, ok = keyUrl(key); !ok {
+			return nil, fmt.Errorf("url missing from context")
+		}
+	}
+
+	if !relativeUrl(url) {
+		return nil, fmt.Errorf("%w: cache url must be a relative reference", ErrBadRequest)
 	}
 
 	headers, ok := ctx.Value(contextKeyHeaders).(http.Header)
 	if !ok {
-		return nil, fmt.Errorf("headers missing from context")
+		headers = http.Header{}
+	}
+
+	if endpoint, body, ok := graphQLKeyRequest(url); ok {
+		req, err := http.NewRequest("POST", endpoint, bytes.NewReader(body))
+		if err != nil {
+			return nil, fmt.Errorf("request [%v] : %w", endpoint, err)
+		}
+
+		req.Header = headers.Clone()
+		req.Header.Set("Content-Type", "application/json")
+		return req, nil
 	}
 
 	req, err := http.NewRequest("GET", url, nil)
@@ -128,6 +641,22 @@ func (c *cacheGetter) createRequest(ctx context.Context) (*http.Request, error)
 	return req, nil
 }
 
+func relativeUrl(rawUrl string) bool {
+	parsed, err := url.Parse(rawUrl)
+	return err == nil && !parsed.IsAbs() && parsed.Host == ""
+}
+
+func keyUrl(key string) (string, bool) {
+	for i := 0; i < 2; i++ {
+		_, rest, found := strings.Cut(key, "]")
+		if !found {
+			return "", false
+		}
+		key = rest
+	}
+	return key, key != ""
+}
+
 func NewCacheWriter(sink groupcache.Sink) *cacheWriter {
 	return &cacheWriter{
 		Sink:   sink,
@@ -141,33 +670,156 @@ func (c *cacheWriter) Header() http.Header {
 }
 
 func (c *cacheWriter) Write(bytes []byte) (int, error) {
+	if c.statusCode == 0 {
+		c.WriteHeader(http.StatusOK)
+	}
+
+	teeing := c.tee != nil && c.tee.started
+	if teeing {
+		c.tee.write(bytes)
+	}
+
+	if !c.overflow && c.limit > 0 && c.bytes.Len()+len(bytes) > c.limit {
+		c.overflow = true
+
+		if c.large != nil && c.statusCode < 400 {
+			if spill, err := c.large.spill(c.bytes.Bytes()); err == nil {
+				c.spill = spill
+				c.bytes.Reset()
+			}
+		}
+	}
+
+	if c.spill != nil {
+		if _, err := c.spill.Write(bytes); err != nil {
+			c.spill.abort()
+			c.spill = nil
+		}
+		return len(bytes), nil
+	}
+
+	if c.overflow {
+		if teeing {
+			return len(bytes), nil
+		}
+		return 0, errResponseTooLarge
+	}
+
 	return c.bytes.Write(bytes)
 }
 
+func (c *cacheWriter) ReadFrom(r io.Reader) (int64, error) {
+	if c.tee != nil || c.large != nil {
+		return copyBody(writerOnly{c}, r)
+	}
+
+	if c.limit <= 0 {
+		return c.bytes.ReadFrom(r)
+	}
+
+	n, err := c.bytes.ReadFrom(io.LimitReader(r, int64(c.limit-c.bytes.Len())+1))
+	if c.bytes.Len() > c.limit {
+		c.overflow = true
+		c.bytes.Truncate(c.limit)
+		return n, errResponseTooLarge
+	}
+	return n, err
+}
+
 func (c *cacheWriter) WriteHeader(statusCode int) {
+	if c.statusCode != 0 || informational(statusCode) {
+		return
+	}
+
 	c.statusCode = statusCode
+
+	if c.tee != nil && statusCode < 400 {
+		c.tee.begin(c.header, statusCode)
+	}
+}
+
+type cacheTee struct {
+	http.ResponseWriter
+
+	start   func(header http.Header, statusCode int)
+	started bool
+	failed  bool
+}
+
+func (t *cacheTee) begin(header http.Header, statusCode int) {
+	t.started = true
+	t.start(header, statusCode)
+}
+
+func (t *cacheTee) write(bytes []byte) {
+	if t.failed {
+		return
+	}
+
+	if _, err := t.ResponseWriter.Write(bytes); err != nil {
+		t.failed = true
+		return
+	}
+
+	if flusher, ok := t.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
 }
 
 func (c *cacheWriter) WriteCache() error {
 	if c.statusCode >= 400 {
-		return NewHttpError(c.statusCode)
-	} else {
-		return c.Sink.SetBytes(c.bytes.Bytes())
+		return NewStatusError(c.statusCode, errors.New("origin error"))
+	}
+
+	if c.spill != nil {
+		if err := c.spill.finish(cacheableHeader(c.header)); err != nil {
+			return NewStatusError(http.StatusBadGateway, fmt.Errorf("store large object : %w", err))
+		}
+		return errLargeObjectStored
+	}
+
+	if c.overflow {
+		return NewStatusError(http.StatusBadGateway, fmt.Errorf("%w : limit %d bytes", errResponseTooLarge, c.limit))
+	}
+
+	entry := cacheEntry{
+		Header: cacheableHeader(c.header),
+		Body:   c.bytes.Bytes(),
+		Stored: time.Now(),
 	}
-}
 
-func NewHttpError(statusCode int) *HttpError {
-	return &HttpError{statusCode}
+	var data bytes.Buffer
+	if err := gob.NewEncoder(&data).Encode(entry); err != nil {
+		return fmt.Errorf("encode entry : %w", err)
+	}
+
+	recordSinkSize(data.Len())
+
+	return c.Sink.SetBytes(data.Bytes())
 }
 
-type HttpError struct {
-	statusCode int
+type cacheEntry struct {
+	Header http.Header
+	Body   []byte
+	Stored time.Time
 }
 
-func (e *HttpError) Error() string {
-	return fmt.Sprintf("http error : %v", e.statusCode)
+func cacheableHeader(header http.Header) http.Header {
+	cacheable := header.Clone()
+	for _, h := range []string{"Set-Cookie", "Connection", "Keep-Alive", "Transfer-Encoding", "Content-Length"} {
+		cacheable.Del(h)
+	}
+	return cacheable
 }
 
-func (e *HttpError) Status() int {
-	return e.statusCode
+func surrogateKeys(header http.Header) []string {
+	keys := strings.Fields(header.Get("Surrogate-Key"))
+
+	for _, tag := range strings.Split(header.Get("Cache-Tag"), ",") {
+		if tag = strings.TrimSpace(tag); tag != "" {
+			keys = append(keys, tag)
+		}
+	}
+
+	return keys
 }
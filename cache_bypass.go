@@ -0,0 +1,55 @@
+package wx
+
+import (
+	"net/http"
+	"slices"
+)
+
+type CacheBypassRule struct {
+	Path   string
+	Roles  []string
+	Claims map[string]string
+}
+
+func WithCacheBypassRules(rules ...CacheBypassRule) cacheOpt {
+	return func(c *proxyCache) {
+		c.bypassRules = append(c.bypassRules, rules...)
+	}
+}
+
+func (c *proxyCache) bypassed(r *http.Request) bool {
+
+	identity, ok := UserFromContext(r.Context())
+	if !ok {
+		return false
+	}
+
+	for _, rule := range c.bypassRules {
+		if rule.Path != "" && !matchPath(rule.Path, r.URL.Path) {
+			continue
+		}
+
+		if rule.matches(identity) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (rule CacheBypassRule) matches(identity *Identity) bool {
+
+	for _, role := range rule.Roles {
+		if identity.HasRole(role) {
+			return true
+		}
+	}
+
+	for name, value := range rule.Claims {
+		if slices.Contains(claimValues(identity.Claims, name), value) {
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,181 @@
+package wx
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+type cacheControlOpt func(*cacheControlConfig)
+
+func WithCacheControlEnabled(enabled bool) cacheControlOpt {
+	return func(c *cacheControlConfig) {
+		c.enabled = enabled
+	}
+}
+
+func WithCacheControlPolicy(policy CacheControlPolicy) cacheControlOpt {
+	return func(c *cacheControlConfig) {
+		c.policies = append(c.policies, policy)
+	}
+}
+
+type cacheControlConfig struct {
+	enabled  bool
+	policies []CacheControlPolicy
+}
+
+type CacheControlPolicy struct {
+	Path           string
+	ContentType    string
+	MaxAge         time.Duration
+	SharedMaxAge   time.Duration
+	Public         bool
+	NoStore        bool
+	MustRevalidate bool
+	NoTransform    bool
+}
+
+func (p CacheControlPolicy) Matches(path string, contentType string) bool {
+	if p.Path != "" && !matchPath(p.Path, path) {
+		return false
+	}
+
+	if p.ContentType != "" && !strings.HasPrefix(contentType, p.ContentType) {
+		return false
+	}
+
+	return true
+}
+
+func (p CacheControlPolicy) String() string {
+	if p.NoStore {
+		return "no-store"
+	}
+
+	directives := []string{fmt.Sprintf("max-age=%v", int(p.MaxAge.Seconds()))}
+
+	if p.Public {
+		directives = append(directives, "public")
+	} else {
+		directives = append(directives, "private")
+	}
+
+	if p.Public && p.SharedMaxAge > 0 {
+		directives = append(directives, fmt.Sprintf("s-maxage=%v", int(p.SharedMaxAge.Seconds())))
+	}
+
+	if p.MustRevalidate {
+		directives = append(directives, "must-revalidate")
+	}
+
+	if p.NoTransform {
+		directives = append(directives, "no-transform")
+	}
+
+	return strings.Join(directives, ", ")
+}
+
+func NewWithCacheControl(logger Logger, ttl time.Duration, handler http.Handler, opts ...cacheControlOpt) http.Handler {
+
+	config := &cacheControlConfig{
+		enabled: true,
+	}
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	if !config.enabled {
+		return handler
+	}
+
+	policies := append(config.policies, CacheControlPolicy{MaxAge: ttl})
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		writer := &cacheControlWriter{
+			ResponseWriter: w,
+			path:           r.URL.Path,
+			policies:       policies,
+		}
+		handler.ServeHTTP(writer, r)
+	})
+}
+
+func NewCacheControlWriter(w http.ResponseWriter, ttl time.Duration) *cacheControlWriter {
+	return &cacheControlWriter{
+		ResponseWriter: w,
+		policies:       []CacheControlPolicy{{MaxAge: ttl}},
+	}
+}
+
+type cacheControlWriter struct {
+	http.ResponseWriter
+
+	path        string
+	policies    []CacheControlPolicy
+	wroteHeader bool
+}
+
+func (self *cacheControlWriter) WriteHeader(statusCode int) {
+	if informational(statusCode) {
+		self.ResponseWriter.WriteHeader(statusCode)
+		return
+	}
+
+	if !self.wroteHeader && statusCode == http.StatusOK {
+		self.applyPolicy()
+	}
+	self.wroteHeader = true
+	self.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (self *cacheControlWriter) Write(bytes []byte) (int, error) {
+	if !self.wroteHeader {
+		self.WriteHeader(http.StatusOK)
+	}
+	return self.ResponseWriter.Write(bytes)
+}
+
+func (self *cacheControlWriter) ReadFrom(r io.Reader) (int64, error) {
+	if !self.wroteHeader {
+		self.WriteHeader(http.StatusOK)
+	}
+	return copyBody(self.ResponseWriter, r)
+}
+
+func (self *cacheControlWriter) Flush() {
+	if flusher, ok := self.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (self *cacheControlWriter) Unwrap() http.ResponseWriter {
+	return self.ResponseWriter
+}
+
+func (self *cacheControlWriter) applyPolicy() {
+	header := self.ResponseWriter.Header()
+	if header.Get("Cache-Control") != "" {
+		return
+	}
+
+	for _, policy := range self.policies {
+		if policy.Matches(self.path, header.Get("Content-Type")) {
+			header.Set("Cache-Control", policy.String())
+			return
+		}
+	}
+}
+
+func matchPath(pattern string, value string) bool {
+	if prefix, found := strings.CutSuffix(pattern, "/**"); found {
+		return value == prefix || strings.HasPrefix(value, prefix+"/")
+	}
+
+	matched, err := path.Match(pattern, value)
+	return err == nil && matched
+}
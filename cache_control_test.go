@@ -0,0 +1,30 @@
+package wx
+
+import (
+	"testing"
+	"time"
+)
+
+func TestCacheControlPolicyString(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		policy CacheControlPolicy
+		value  string
+	}{
+		{"private", CacheControlPolicy{MaxAge: time.Minute}, "max-age=60, private"},
+		{"public", CacheControlPolicy{MaxAge: time.Minute, Public: true}, "max-age=60, public"},
+		{"public shared max age", CacheControlPolicy{MaxAge: time.Minute, SharedMaxAge: time.Hour, Public: true}, "max-age=60, public, s-maxage=3600"},
+		{"private shared max age", CacheControlPolicy{MaxAge: time.Minute, SharedMaxAge: time.Hour}, "max-age=60, private"},
+		{"no store", CacheControlPolicy{MaxAge: time.Minute, SharedMaxAge: time.Hour, NoStore: true}, "no-store"},
+		{"revalidate", CacheControlPolicy{MaxAge: time.Minute, Public: true, MustRevalidate: true, NoTransform: true}, "max-age=60, public, must-revalidate, no-transform"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if value := test.policy.String(); value != test.value {
+				t.Fatalf("expected %q, got %q", test.value, value)
+			}
+		})
+	}
+}
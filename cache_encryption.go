@@ -0,0 +1,213 @@
+package wx
+
+import (
+	"context"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+)
+
+const (
+	encryptedChunkSize  = 64 << 10
+	encryptedPrefixSize = 8
+)
+
+var errTruncated = errors.New("encrypted object truncated")
+
+func NewEncryptedObjectStore(store ObjectStore, keyring *keyring) *encryptedObjectStore {
+	return &encryptedObjectStore{
+		ObjectStore: store,
+		keyring:     keyring,
+	}
+}
+
+type encryptedObjectStore struct {
+	ObjectStore
+	keyring *keyring
+}
+
+func (e *encryptedObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+
+	body, err := e.ObjectStore.Get(ctx, key)
+	if err != nil {
+		return nil, err
+	}
+
+	prefix := make([]byte, encryptedPrefixSize)
+	if _, err := io.ReadFull(body, prefix); err != nil {
+		body.Close()
+		return nil, fmt.Errorf("read prefix [%v] : %w", key, err)
+	}
+
+	return &decryptingReader{ReadCloser: body, key: key, prefix: prefix, aeads: e.keyring.all()}, nil
+}
+
+func (e *encryptedObjectStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+
+	aead, err := e.keyring.current()
+	if err != nil {
+		return err
+	}
+
+	prefix := make([]byte, encryptedPrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return fmt.Errorf("prefix : %w", err)
+	}
+
+	reader, writer := io.Pipe()
+
+	go func() {
+		writer.CloseWithError(encryptChunks(writer, body, aead, key, prefix))
+	}()
+
+	err = e.ObjectStore.Put(ctx, key, reader, encryptedSize(size, aead.Overhead()))
+	reader.CloseWithError(err)
+	return err
+}
+
+func encryptedSize(size int64, overhead int) int64 {
+	if size < 0 {
+		return size
+	}
+
+	chunks := max((size+encryptedChunkSize-1)/encryptedChunkSize, 1)
+	return encryptedPrefixSize + size + chunks*int64(4+overhead)
+}
+
+func (e *encryptedObjectStore) bodyHash() (hash.Hash, error) {
+	return e.keyring.mac()
+}
+
+func encryptChunks(w io.Writer, r io.Reader, aead cipher.AEAD, key string, prefix []byte) error {
+
+	if _, err := w.Write(prefix); err != nil {
+		return err
+	}
+
+	chunk := make([]byte, encryptedChunkSize)
+	next := make([]byte, encryptedChunkSize)
+	sealed := make([]byte, 0, encryptedChunkSize+aead.Overhead())
+
+	n, err := io.ReadFull(r, chunk)
+	if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+		return err
+	}
+
+	for counter := uint32(0); ; counter++ {
+		final := n < encryptedChunkSize
+
+		var m int
+		if !final {
+			m, err = io.ReadFull(r, next)
+			if err != nil && err != io.EOF && err != io.ErrUnexpectedEOF {
+				return err
+			}
+			final = m == 0
+		}
+
+		sealed = aead.Seal(sealed[:0], chunkNonce(aead, prefix, counter), chunk[:n], chunkAAD(key, counter, final))
+
+		if err := binary.Write(w, binary.BigEndian, uint32(len(sealed))); err != nil {
+			return err
+		}
+
+		if _, err := w.Write(sealed); err != nil {
+			return err
+		}
+
+		if final {
+			return nil
+		}
+
+		chunk, next, n = next, chunk, m
+	}
+}
+
+func chunkNonce(aead cipher.AEAD, prefix []byte, counter uint32) []byte {
+	nonce := make([]byte, aead.NonceSize())
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[len(nonce)-4:], counter)
+	return nonce
+}
+
+func chunkAAD(key string, counter uint32, final bool) []byte {
+	aad := binary.BigEndian.AppendUint32([]byte(key), counter)
+	if final {
+		return append(aad, 1)
+	}
+	return append(aad, 0)
+}
+
+type decryptingReader struct {
+	io.ReadCloser
+
+	key     string
+	prefix  []byte
+	aeads   []cipher.AEAD
+	aead    cipher.AEAD
+	counter uint32
+	buf     []byte
+	done    bool
+}
+
+func (d *decryptingReader) Read(p []byte) (int, error) {
+
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+
+		if err := d.next(); err != nil {
+			return 0, err
+		}
+	}
+
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
+
+func (d *decryptingReader) next() error {
+
+	var size uint32
+	if err := binary.Read(d.ReadCloser, binary.BigEndian, &size); err != nil {
+		if errors.Is(err, io.EOF) {
+			return errTruncated
+		}
+		return err
+	}
+
+	if size > encryptedChunkSize+64 {
+		return fmt.Errorf("encrypted chunk too large : %d", size)
+	}
+
+	sealed := make([]byte, size)
+	if _, err := io.ReadFull(d.ReadCloser, sealed); err != nil {
+		return errTruncated
+	}
+
+	aeads := d.aeads
+	if d.aead != nil {
+		aeads = []cipher.AEAD{d.aead}
+	}
+
+	for _, aead := range aeads {
+		nonce := chunkNonce(aead, d.prefix, d.counter)
+
+		for _, final := range []bool{false, true} {
+			if plain, err := aead.Open(nil, nonce, sealed, chunkAAD(d.key, d.counter, final)); err == nil {
+				d.aead = aead
+				d.buf = plain
+				d.done = final
+				d.counter++
+				return nil
+			}
+		}
+	}
+
+	return errors.New("decrypt chunk : no matching key")
+}
@@ -0,0 +1,211 @@
+package wx
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"sync"
+	"testing"
+)
+
+type memoryObjectStore struct {
+	mutex   sync.Mutex
+	objects map[string][]byte
+}
+
+func (m *memoryObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	object, ok := m.objects[key]
+	if !ok {
+		return nil, fmt.Errorf("object [%v] not found", key)
+	}
+
+	return io.NopCloser(bytes.NewReader(object)), nil
+}
+
+func (m *memoryObjectStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+
+	object, err := io.ReadAll(body)
+	if err != nil {
+		return err
+	}
+
+	if size >= 0 && int64(len(object)) != size {
+		return fmt.Errorf("expected %d bytes, got %d", size, len(object))
+	}
+
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.objects[key] = object
+	return nil
+}
+
+func encryptedChunks(t *testing.T, object []byte) [][]byte {
+	t.Helper()
+
+	chunks := [][]byte{}
+	for rest := object[encryptedPrefixSize:]; len(rest) > 0; {
+		size := 4 + int(binary.BigEndian.Uint32(rest))
+		chunks = append(chunks, rest[:size])
+		rest = rest[size:]
+	}
+
+	return chunks
+}
+
+func TestEncryptedObjectStore(t *testing.T) {
+
+	ring := NewKeyring([]byte("cache-key"))
+
+	plain := make([]byte, 3*encryptedChunkSize+100)
+	if _, err := rand.Read(plain); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		size   int
+		tamper func(t *testing.T, objects map[string][]byte)
+		fails  bool
+	}{
+		{"empty", 0, nil, false},
+		{"single byte", 1, nil, false},
+		{"one chunk", encryptedChunkSize, nil, false},
+		{"chunk and a byte", encryptedChunkSize + 1, nil, false},
+		{"several chunks", len(plain), nil, false},
+		{"final chunk dropped", len(plain), func(t *testing.T, objects map[string][]byte) {
+			chunks := encryptedChunks(t, objects["a"])
+			objects["a"] = objects["a"][:len(objects["a"])-len(chunks[len(chunks)-1])]
+		}, true},
+		{"chunks reordered", len(plain), func(t *testing.T, objects map[string][]byte) {
+			chunks := encryptedChunks(t, objects["a"])
+			reordered := append([]byte{}, objects["a"][:encryptedPrefixSize]...)
+			reordered = append(append(reordered, chunks[1]...), chunks[0]...)
+			for _, chunk := range chunks[2:] {
+				reordered = append(reordered, chunk...)
+			}
+			objects["a"] = reordered
+		}, true},
+		{"objects swapped", len(plain), func(t *testing.T, objects map[string][]byte) {
+			objects["a"], objects["b"] = objects["b"], objects["a"]
+		}, true},
+		{"chunk from other object", len(plain), func(t *testing.T, objects map[string][]byte) {
+			a, b := encryptedChunks(t, objects["a"]), encryptedChunks(t, objects["b"])
+			spliced := append([]byte{}, objects["a"][:encryptedPrefixSize]...)
+			spliced = append(spliced, b[0]...)
+			for _, chunk := range a[1:] {
+				spliced = append(spliced, chunk...)
+			}
+			objects["a"] = spliced
+		}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			backend := &memoryObjectStore{objects: map[string][]byte{}}
+			store := NewEncryptedObjectStore(backend, ring)
+
+			for _, key := range []string{"a", "b"} {
+				if err := store.Put(context.Background(), key, bytes.NewReader(plain[:test.size]), int64(test.size)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			if bytes.Contains(backend.objects["a"], plain[:min(test.size, 64)]) && test.size >= 16 {
+				t.Fatal("expected ciphertext, found plaintext")
+			}
+
+			if test.tamper != nil {
+				test.tamper(t, backend.objects)
+			}
+
+			body, err := store.Get(context.Background(), "a")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			read, err := io.ReadAll(body)
+			body.Close()
+
+			if test.fails {
+				if err == nil {
+					t.Fatalf("expected error, read %d bytes", len(read))
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error : %v", err)
+			}
+
+			if !bytes.Equal(read, plain[:test.size]) {
+				t.Fatalf("expected %d bytes round trip, got %d", test.size, len(read))
+			}
+		})
+	}
+}
+
+func TestEncryptedObjectStoreTruncation(t *testing.T) {
+
+	backend := &memoryObjectStore{objects: map[string][]byte{}}
+	store := NewEncryptedObjectStore(backend, NewKeyring([]byte("cache-key")))
+
+	if err := store.Put(context.Background(), "a", bytes.NewReader(make([]byte, 2*encryptedChunkSize)), 2*encryptedChunkSize); err != nil {
+		t.Fatal(err)
+	}
+
+	chunks := encryptedChunks(t, backend.objects["a"])
+	backend.objects["a"] = backend.objects["a"][:encryptedPrefixSize+len(chunks[0])]
+
+	body, err := store.Get(context.Background(), "a")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	defer body.Close()
+
+	if _, err := io.ReadAll(body); !errors.Is(err, errTruncated) {
+		t.Fatalf("expected truncation error, got %v", err)
+	}
+}
+
+func TestBodyHashIsKeyed(t *testing.T) {
+
+	content := []byte("large response body")
+	plain := sha256.Sum256(content)
+
+	tests := []struct {
+		name  string
+		store ObjectStore
+		keyed bool
+	}{
+		{"plain store", &memoryObjectStore{}, false},
+		{"encrypted store", NewEncryptedObjectStore(&memoryObjectStore{}, NewKeyring([]byte("cache-key"))), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			hash, err := bodyHash(test.store)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			hash.Write(content)
+
+			if keyed := hex.EncodeToString(hash.Sum(nil)) != hex.EncodeToString(plain[:]); keyed != test.keyed {
+				t.Fatalf("expected keyed %v, got %v", test.keyed, keyed)
+			}
+		})
+	}
+}
@@ -0,0 +1,171 @@
+package wx
+
+import (
+	"context"
+	"expvar"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+var sinkStats struct {
+	count atomic.Int64
+	bytes atomic.Int64
+	max   atomic.Int64
+}
+
+func recordSinkSize(size int) {
+	sinkStats.count.Add(1)
+	sinkStats.bytes.Add(int64(size))
+
+	for {
+		current := sinkStats.max.Load()
+		if int64(size) <= current || sinkStats.max.CompareAndSwap(current, int64(size)) {
+			return
+		}
+	}
+}
+
+type CacheSinkStats struct {
+	Count int64 `json:"count"`
+	Bytes int64 `json:"bytes"`
+	Max   int64 `json:"max"`
+}
+
+type CacheGroupStats struct {
+	Name           string                `json:"name"`
+	Gets           int64                 `json:"gets"`
+	CacheHits      int64                 `json:"cache_hits"`
+	PeerLoads      int64                 `json:"peer_loads"`
+	PeerErrors     int64                 `json:"peer_errors"`
+	Loads          int64                 `json:"loads"`
+	LoadsDeduped   int64                 `json:"loads_deduped"`
+	LocalLoads     int64                 `json:"local_loads"`
+	LocalLoadErrs  int64                 `json:"local_load_errs"`
+	ServerRequests int64                 `json:"server_requests"`
+	Main           groupcache.CacheStats `json:"main"`
+	Hot            groupcache.CacheStats `json:"hot"`
+}
+
+type CacheStatsSnapshot struct {
+	Time   time.Time         `json:"time"`
+	Groups []CacheGroupStats `json:"groups"`
+	Sinks  CacheSinkStats    `json:"sinks"`
+}
+
+func NewCacheStats(metrics Metrics, groups ...*groupcache.Group) *cacheStats {
+	stats := &cacheStats{
+		Metrics:  metrics,
+		groups:   groups,
+		previous: map[string]CacheGroupStats{},
+	}
+
+	if expvar.Get("groupcache") == nil {
+		expvar.Publish("groupcache", expvar.Func(func() interface{} {
+			return stats.Snapshot()
+		}))
+	}
+
+	return stats
+}
+
+type cacheStats struct {
+	Metrics
+
+	groups        []*groupcache.Group
+	mutex         sync.Mutex
+	previous      map[string]CacheGroupStats
+	previousSinks CacheSinkStats
+}
+
+func (s *cacheStats) Snapshot() CacheStatsSnapshot {
+
+	snapshot := CacheStatsSnapshot{
+		Time:   time.Now().UTC(),
+		Groups: []CacheGroupStats{},
+		Sinks: CacheSinkStats{
+			Count: sinkStats.count.Load(),
+			Bytes: sinkStats.bytes.Load(),
+			Max:   sinkStats.max.Load(),
+		},
+	}
+
+	for _, group := range s.groups {
+		snapshot.Groups = append(snapshot.Groups, CacheGroupStats{
+			Name:           group.Name(),
+			Gets:           group.Stats.Gets.Get(),
+			CacheHits:      group.Stats.CacheHits.Get(),
+			PeerLoads:      group.Stats.PeerLoads.Get(),
+			PeerErrors:     group.Stats.PeerErrors.Get(),
+			Loads:          group.Stats.Loads.Get(),
+			LoadsDeduped:   group.Stats.LoadsDeduped.Get(),
+			LocalLoads:     group.Stats.LocalLoads.Get(),
+			LocalLoadErrs:  group.Stats.LocalLoadErrs.Get(),
+			ServerRequests: group.Stats.ServerRequests.Get(),
+			Main:           group.CacheStats(groupcache.MainCache),
+			Hot:            group.CacheStats(groupcache.HotCache),
+		})
+	}
+
+	return snapshot
+}
+
+func (s *cacheStats) Run(ctx context.Context, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			s.Report()
+		}
+	}
+}
+
+func (s *cacheStats) Report() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	snapshot := s.Snapshot()
+
+	s.Metrics.Counter("groupcache_sink_writes_total", float64(snapshot.Sinks.Count-s.previousSinks.Count), nil)
+	s.Metrics.Counter("groupcache_sink_bytes_total", float64(snapshot.Sinks.Bytes-s.previousSinks.Bytes), nil)
+	s.Metrics.Gauge("groupcache_sink_bytes_max", float64(snapshot.Sinks.Max), nil)
+	s.previousSinks = snapshot.Sinks
+
+	for _, group := range snapshot.Groups {
+		previous := s.previous[group.Name]
+		s.previous[group.Name] = group
+
+		tags := map[string]string{"group": group.Name}
+
+		s.Metrics.Counter("groupcache_gets_total", float64(group.Gets-previous.Gets), tags)
+		s.Metrics.Counter("groupcache_hits_total", float64(group.CacheHits-previous.CacheHits), tags)
+		s.Metrics.Counter("groupcache_peer_loads_total", float64(group.PeerLoads-previous.PeerLoads), tags)
+		s.Metrics.Counter("groupcache_peer_errors_total", float64(group.PeerErrors-previous.PeerErrors), tags)
+		s.Metrics.Counter("groupcache_local_loads_total", float64(group.LocalLoads-previous.LocalLoads), tags)
+		s.Metrics.Counter("groupcache_local_load_errors_total", float64(group.LocalLoadErrs-previous.LocalLoadErrs), tags)
+		s.Metrics.Counter("groupcache_server_requests_total", float64(group.ServerRequests-previous.ServerRequests), tags)
+
+		caches := []struct {
+			name              string
+			current, previous groupcache.CacheStats
+		}{
+			{"main", group.Main, previous.Main},
+			{"hot", group.Hot, previous.Hot},
+		}
+
+		for _, cache := range caches {
+			tags := map[string]string{"group": group.Name, "cache": cache.name}
+
+			s.Metrics.Gauge("groupcache_bytes", float64(cache.current.Bytes), tags)
+			s.Metrics.Gauge("groupcache_items", float64(cache.current.Items), tags)
+			s.Metrics.Counter("groupcache_evictions_total", float64(cache.current.Evictions-cache.previous.Evictions), tags)
+		}
+	}
+}
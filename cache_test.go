@@ -0,0 +1,206 @@
+package wx
+
+import (
+	"context"
+	"encoding/base64"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+func TestCacheKeyUrls(t *testing.T) {
+
+	getter := NewCacheGetter(http.NotFoundHandler())
+	graphql := graphQLKeyMarker + base64.RawURLEncoding.EncodeToString([]byte(`{"query":"{ a }"}`))
+
+	tests := []struct {
+		name    string
+		key     string
+		url     string
+		target  string
+		invalid bool
+	}{
+		{"relative key", "[t][0.0]/api/items?page=2", "", "/api/items?page=2", false},
+		{"relative context url", "[t][0.0]/ignored", "/api/items", "/api/items", false},
+		{"graphql key", "[t][0.0]/graphql" + graphql, "", "/graphql", false},
+		{"absolute key", "[t][0.0]http://169.254.169.254/latest/meta-data", "", "", true},
+		{"scheme relative key", "[t][0.0]//internal.example.com/admin", "", "", true},
+		{"absolute graphql key", "[t][0.0]https://internal.example.com/graphql" + graphql, "", "", true},
+		{"absolute context url", "[t][0.0]/ignored", "http://internal.example.com/", "", true},
+		{"malformed key", "no brackets", "", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			ctx := context.Background()
+			if test.url != "" {
+				ctx = context.WithValue(ctx, contextKeyUrl, test.url)
+			}
+
+			req, err := getter.createRequest(ctx, test.key)
+
+			if test.invalid {
+				if err == nil {
+					t.Fatalf("expected error, got request for %v", req.URL)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error : %v", err)
+			}
+
+			if req.URL.String() != test.target {
+				t.Fatalf("expected %v, got %v", test.target, req.URL)
+			}
+		})
+	}
+}
+
+func TestCachePoolPeerAuth(t *testing.T) {
+
+	if _, err := NewCachePool("http://self", nil); err == nil {
+		t.Fatal("expected error for empty peer secret")
+	}
+
+	pool, err := NewCachePool("http://self", []byte("peer-secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	groupcache.NewGroup("cache_pool_test", 1<<20, groupcache.GetterFunc(func(ctx context.Context, key string, dest groupcache.Sink) error {
+		return dest.SetString("value for " + key)
+	}))
+
+	server := httptest.NewServer(pool)
+	defer server.Close()
+
+	other := &cachePool{secret: []byte("other-secret")}
+	path := cachePoolPath + "cache_pool_test/key"
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name      string
+		transport http.RoundTripper
+		header    string
+		status    int
+	}{
+		{"signed by peer", &peerTransport{RoundTripper: http.DefaultTransport, pool: pool}, "", http.StatusOK},
+		{"unsigned", http.DefaultTransport, "", http.StatusUnauthorized},
+		{"signed with other secret", &peerTransport{RoundTripper: http.DefaultTransport, pool: other}, "", http.StatusUnauthorized},
+		{"expired signature", http.DefaultTransport, stale + "." + pool.sign(stale, path), http.StatusUnauthorized},
+		{"malformed signature", http.DefaultTransport, "signature", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			req, err := http.NewRequest(http.MethodGet, server.URL+path, nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.header != "" {
+				req.Header.Set(cachePeerHeader, test.header)
+			}
+
+			resp, err := (&http.Client{Transport: test.transport}).Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			defer resp.Body.Close()
+			io.Copy(io.Discard, resp.Body)
+
+			if resp.StatusCode != test.status {
+				t.Fatalf("expected %v, got %v", test.status, resp.StatusCode)
+			}
+		})
+	}
+}
+
+func TestCacheBypassesRequestBodies(t *testing.T) {
+
+	bypass := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		w.Write(data)
+	})
+
+	tests := []struct {
+		name   string
+		method string
+		body   string
+		bypass http.Handler
+		status int
+	}{
+		{"post with bypass", http.MethodPost, "upload", bypass, http.StatusOK},
+		{"get with body", http.MethodGet, "upload", bypass, http.StatusOK},
+		{"put without bypass", http.MethodPut, "upload", nil, http.StatusMethodNotAllowed},
+		{"get with body without bypass", http.MethodGet, "upload", nil, http.StatusMethodNotAllowed},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			opts := []cacheOpt{}
+			if test.bypass != nil {
+				opts = append(opts, WithCacheBypass(test.bypass))
+			}
+
+			cache := NewProxyCache(nopLogger{}, time.Minute, nil, opts...)
+
+			w := httptest.NewRecorder()
+			cache.ServeHTTP(w, httptest.NewRequest(test.method, "/upload", strings.NewReader(test.body)))
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if test.status == http.StatusOK && (w.Header().Get("X-Cache") != "BYPASS" || w.Body.String() != test.body) {
+				t.Fatalf("expected bypassed upload, got %v %q", w.Header().Get("X-Cache"), w.Body.String())
+			}
+		})
+	}
+}
+
+func TestCacheGetterConcurrentFills(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		fills   int
+		limited bool
+	}{
+		{"negative fills unlimited", -1, false},
+		{"zero fills unlimited", 0, false},
+		{"one fill", 1, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			getter := NewCacheGetter(http.NotFoundHandler(), WithMaxConcurrentFills(test.fills, 10*time.Millisecond))
+
+			release, err := getter.acquire(context.Background())
+			if err != nil {
+				t.Fatalf("expected first fill, got %v", err)
+			}
+			defer release()
+
+			second, err := getter.acquire(context.Background())
+			if limited := err != nil; limited != test.limited {
+				t.Fatalf("expected limited %v, got %v", test.limited, err)
+			}
+
+			if err == nil {
+				second()
+			}
+		})
+	}
+}
@@ -0,0 +1,99 @@
+package casbinauthz
+
+import (
+	"context"
+	"fmt"
+	"path/filepath"
+
+	"github.com/casbin/casbin/v2"
+	"github.com/casbin/casbin/v2/persist"
+	"github.com/fsnotify/fsnotify"
+
+	"github.com/reverted/wx"
+)
+
+func NewAuthorizer(modelPath string, policyPath string) (*casbinAuthorizer, error) {
+
+	enforcer, err := casbin.NewSyncedEnforcer(modelPath, policyPath)
+	if err != nil {
+		return nil, fmt.Errorf("new enforcer : %w", err)
+	}
+
+	return &casbinAuthorizer{
+		SyncedEnforcer: enforcer,
+		policyPath:     policyPath,
+	}, nil
+}
+
+func NewAdapterAuthorizer(modelPath string, adapter persist.Adapter) (*casbinAuthorizer, error) {
+
+	enforcer, err := casbin.NewSyncedEnforcer(modelPath, adapter)
+	if err != nil {
+		return nil, fmt.Errorf("new enforcer : %w", err)
+	}
+
+	return &casbinAuthorizer{
+		SyncedEnforcer: enforcer,
+	}, nil
+}
+
+type casbinAuthorizer struct {
+	*casbin.SyncedEnforcer
+	policyPath string
+}
+
+func (c *casbinAuthorizer) Authorize(ctx context.Context, input wx.AuthorizationInput) (bool, error) {
+
+	for _, subject := range append([]string{input.Subject}, input.Roles...) {
+		allowed, err := c.SyncedEnforcer.Enforce(subject, input.Path, input.Method)
+		if err != nil {
+			return false, fmt.Errorf("enforce [%v] : %w", subject, err)
+		}
+
+		if allowed {
+			return true, nil
+		}
+	}
+
+	return false, nil
+}
+
+func (c *casbinAuthorizer) Watch(ctx context.Context, logger wx.Logger) error {
+
+	if c.policyPath == "" {
+		return fmt.Errorf("watch : no policy file")
+	}
+
+	watcher, err := fsnotify.NewWatcher()
+	if err != nil {
+		return fmt.Errorf("new watcher : %w", err)
+	}
+
+	defer watcher.Close()
+
+	if err := watcher.Add(filepath.Dir(c.policyPath)); err != nil {
+		return fmt.Errorf("watch [%s] : %w", c.policyPath, err)
+	}
+
+	for {
+		select {
+		case event := <-watcher.Events:
+			if filepath.Clean(event.Name) != filepath.Clean(c.policyPath) || event.Has(fsnotify.Remove) {
+				continue
+			}
+
+			if err := c.SyncedEnforcer.LoadPolicy(); err != nil {
+				logger.Errorf("reload policy [%s] : %v", c.policyPath, err)
+				continue
+			}
+
+			logger.Debug("reloaded policy : ", c.policyPath)
+
+		case err := <-watcher.Errors:
+			logger.Errorf("watch [%s] : %v", c.policyPath, err)
+
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
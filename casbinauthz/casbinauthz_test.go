@@ -0,0 +1,72 @@
+package casbinauthz
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+
+	"github.com/reverted/wx"
+)
+
+const testModel = `[request_definition]
+r = sub, obj, act
+
+[policy_definition]
+p = sub, obj, act
+
+[policy_effect]
+e = some(where (p.eft == allow))
+
+[matchers]
+m = r.sub == p.sub && keyMatch(r.obj, p.obj) && r.act == p.act
+`
+
+const testPolicy = `p, admin, /admin/*, GET
+p, alice, /reports/*, GET
+`
+
+func TestAuthorize(t *testing.T) {
+
+	dir := t.TempDir()
+	modelPath := filepath.Join(dir, "model.conf")
+	policyPath := filepath.Join(dir, "policy.csv")
+
+	if err := os.WriteFile(modelPath, []byte(testModel), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	if err := os.WriteFile(policyPath, []byte(testPolicy), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	authorizer, err := NewAuthorizer(modelPath, policyPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		input   wx.AuthorizationInput
+		allowed bool
+	}{
+		{"subject policy", wx.AuthorizationInput{Subject: "alice", Path: "/reports/q1", Method: "GET"}, true},
+		{"role policy", wx.AuthorizationInput{Subject: "bob", Roles: []string{"viewer", "admin"}, Path: "/admin/users", Method: "GET"}, true},
+		{"wrong method", wx.AuthorizationInput{Subject: "alice", Path: "/reports/q1", Method: "POST"}, false},
+		{"no matching policy", wx.AuthorizationInput{Subject: "bob", Roles: []string{"viewer"}, Path: "/admin/users", Method: "GET"}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			allowed, err := authorizer.Authorize(context.Background(), test.input)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if allowed != test.allowed {
+				t.Fatalf("expected %v, got %v", test.allowed, allowed)
+			}
+		})
+	}
+}
@@ -0,0 +1,133 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"text/template"
+)
+
+type MissingClaim int
+
+const (
+	MissingClaimOmit MissingClaim = iota
+	MissingClaimEmpty
+	MissingClaimReject
+)
+
+type ClaimHeader struct {
+	Name     string
+	Template string
+	Path     string
+	Missing  MissingClaim
+}
+
+var claimHeaderFuncs = template.FuncMap{
+	"join": func(value interface{}, sep string) string {
+		return strings.Join(stringValues(value), sep)
+	},
+	"first": func(value interface{}) string {
+		if values := stringValues(value); len(values) > 0 {
+			return values[0]
+		}
+		return ""
+	},
+	"lower": strings.ToLower,
+	"upper": strings.ToUpper,
+}
+
+type compiledClaimHeader struct {
+	ClaimHeader
+	template *template.Template
+}
+
+func NewClaimHeaders(headers ...ClaimHeader) (Modifier, error) {
+
+	compiled := []compiledClaimHeader{}
+
+	for _, header := range headers {
+		tmpl, err := template.New(header.Name).Funcs(claimHeaderFuncs).Option("missingkey=error").Parse(header.Template)
+		if err != nil {
+			return nil, fmt.Errorf("parse header template [%v] : %w", header.Name, err)
+		}
+
+		compiled = append(compiled, compiledClaimHeader{header, tmpl})
+	}
+
+	return func(r *http.Request) error {
+
+		path := r.URL.Path
+		if route, ok := RouteFromContext(r.Context()); ok {
+			path = route.Path
+		}
+
+		identity, authenticated := UserFromContext(r.Context())
+
+		for _, header := range compiled {
+			r.Header.Del(header.Name)
+
+			if header.Path != "" && !matchPath(header.Path, path) {
+				continue
+			}
+
+			if !authenticated {
+				if header.Missing == MissingClaimReject {
+					return errMissingCredentials
+				}
+				continue
+			}
+
+			value, err := header.render(identity)
+			if err != nil {
+				switch header.Missing {
+				case MissingClaimEmpty:
+					r.Header.Set(header.Name, "")
+				case MissingClaimReject:
+					return fmt.Errorf("%w: header %v : %v", ErrForbidden, header.Name, err)
+				}
+				continue
+			}
+
+			r.Header.Set(header.Name, value)
+		}
+
+		return nil
+	}, nil
+}
+
+func (h compiledClaimHeader) render(identity *Identity) (string, error) {
+
+	data := map[string]interface{}{
+		"claims":  identity.Claims,
+		"subject": identity.Subject,
+		"email":   identity.Email,
+		"roles":   identity.Roles,
+		"scopes":  identity.Scopes,
+	}
+
+	var value strings.Builder
+	if err := h.template.Execute(&value, data); err != nil {
+		return "", err
+	}
+
+	return strings.NewReplacer("\r", " ", "\n", " ").Replace(value.String()), nil
+}
+
+func stringValues(value interface{}) []string {
+	switch v := value.(type) {
+	case nil:
+		return nil
+	case string:
+		return strings.Fields(v)
+	case []string:
+		return v
+	case []interface{}:
+		values := []string{}
+		for _, item := range v {
+			values = append(values, fmt.Sprint(item))
+		}
+		return values
+	default:
+		return []string{fmt.Sprint(v)}
+	}
+}
@@ -0,0 +1,82 @@
+package wx
+
+import (
+	"context"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const contextKeyClientIP contextKey = "client_ip"
+
+func NewWithClientIP(trustedProxies int, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if ip, ok := resolveClientIP(r, trustedProxies); ok {
+			r = r.WithContext(context.WithValue(r.Context(), contextKeyClientIP, ip))
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func ClientIP(r *http.Request) (netip.Addr, bool) {
+	if ip, ok := r.Context().Value(contextKeyClientIP).(netip.Addr); ok {
+		return ip, true
+	}
+
+	return resolveClientIP(r, 0)
+}
+
+func resolveClientIP(r *http.Request, trustedProxies int) (netip.Addr, bool) {
+
+	host, _, err := net.SplitHostPort(r.RemoteAddr)
+	if err != nil {
+		host = r.RemoteAddr
+	}
+
+	if trustedProxies > 0 {
+		forwarded := []string{}
+		for _, value := range r.Header.Values("X-Forwarded-For") {
+			for _, addr := range strings.Split(value, ",") {
+				forwarded = append(forwarded, strings.TrimSpace(addr))
+			}
+		}
+
+		if len(forwarded) >= trustedProxies {
+			host = forwarded[len(forwarded)-trustedProxies]
+		}
+	}
+
+	ip, err := netip.ParseAddr(host)
+	if err != nil {
+		return netip.Addr{}, false
+	}
+
+	return ip.Unmap(), true
+}
+
+func ParsePrefixes(cidrs ...string) ([]netip.Prefix, error) {
+	prefixes := []netip.Prefix{}
+
+	for _, cidr := range cidrs {
+		if !strings.Contains(cidr, "/") {
+			ip, err := netip.ParseAddr(cidr)
+			if err != nil {
+				return nil, err
+			}
+
+			prefixes = append(prefixes, netip.PrefixFrom(ip.Unmap(), ip.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(cidr)
+		if err != nil {
+			return nil, err
+		}
+
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
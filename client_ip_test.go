@@ -0,0 +1,54 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestResolveClientIP(t *testing.T) {
+
+	tests := []struct {
+		name           string
+		remoteAddr     string
+		forwarded      []string
+		trustedProxies int
+		ip             string
+	}{
+		{"remote addr", "203.0.113.7:1234", nil, 0, "203.0.113.7"},
+		{"forwarded ignored without trusted proxies", "203.0.113.7:1234", []string{"198.51.100.1"}, 0, "203.0.113.7"},
+		{"one trusted proxy", "10.0.0.1:1234", []string{"198.51.100.1"}, 1, "198.51.100.1"},
+		{"spoofed entry before trusted proxy", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.1"}, 1, "198.51.100.1"},
+		{"two trusted proxies", "10.0.0.2:1234", []string{"1.2.3.4, 198.51.100.1, 10.0.0.1"}, 2, "198.51.100.1"},
+		{"multiple headers", "10.0.0.2:1234", []string{"1.2.3.4, 198.51.100.1", "10.0.0.1"}, 2, "198.51.100.1"},
+		{"fewer entries than trusted proxies", "203.0.113.7:1234", []string{"1.2.3.4"}, 2, "203.0.113.7"},
+		{"no forwarded header", "203.0.113.7:1234", nil, 1, "203.0.113.7"},
+		{"mapped ipv4", "[::ffff:203.0.113.7]:1234", nil, 0, "203.0.113.7"},
+		{"ipv6", "[2001:db8::1]:1234", nil, 0, "2001:db8::1"},
+		{"invalid forwarded entry", "10.0.0.1:1234", []string{"not-an-ip"}, 1, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			for _, value := range test.forwarded {
+				r.Header.Add("X-Forwarded-For", value)
+			}
+
+			ip, ok := resolveClientIP(r, test.trustedProxies)
+
+			if test.ip == "" {
+				if ok {
+					t.Fatalf("expected no ip, got %v", ip)
+				}
+				return
+			}
+
+			if !ok || ip.String() != test.ip {
+				t.Fatalf("expected %v, got %v", test.ip, ip)
+			}
+		})
+	}
+}
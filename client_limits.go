@@ -0,0 +1,146 @@
+package wx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+)
+
+const contextKeyClientSlot contextKey = "client_slot"
+
+type ClientLimits struct {
+	MaxInflightPerIP   int
+	MaxInflightPerUser int
+	MaxStreamsPerIP    int
+	MaxStreamsPerUser  int
+}
+
+func NewClientLimiter(logger Logger, config ClientLimits) *clientLimiter {
+	return &clientLimiter{
+		Logger:   logger,
+		config:   config,
+		inflight: map[string]int{},
+		streams:  map[string]int{},
+	}
+}
+
+type clientLimiter struct {
+	Logger
+
+	config   ClientLimits
+	mutex    sync.Mutex
+	inflight map[string]int
+	streams  map[string]int
+}
+
+type clientSlot struct {
+	limiter *clientLimiter
+	keys    []clientKey
+	once    sync.Once
+}
+
+type clientKey struct {
+	kind string
+	key  string
+}
+
+func (k clientKey) String() string {
+	return k.kind + ":" + k.key
+}
+
+func (s *clientSlot) release() {
+	s.once.Do(func() {
+		s.limiter.release(s.limiter.inflight, s.keys)
+	})
+}
+
+func (l *clientLimiter) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		keys, err := l.acquire(r, l.inflight, "inflight requests", l.config.MaxInflightPerIP, l.config.MaxInflightPerUser)
+		if err != nil {
+			l.Logger.Debug("client limit : ", r.URL.Path, " : ", err)
+			w.Header().Set("Retry-After", "1")
+			RenderError(w, r, err)
+			return
+		}
+
+		slot := &clientSlot{limiter: l, keys: keys}
+		defer slot.release()
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyClientSlot, slot)))
+	})
+}
+
+func (l *clientLimiter) keys(r *http.Request) []clientKey {
+
+	keys := []clientKey{}
+
+	if ip, ok := ClientIP(r); ok {
+		keys = append(keys, clientKey{"ip", ip.String()})
+	}
+
+	if identity, ok := UserFromContext(r.Context()); ok && identity.Subject != "" {
+		keys = append(keys, clientKey{"user", identity.Subject})
+	}
+
+	return keys
+}
+
+func (l *clientLimiter) acquire(r *http.Request, counts map[string]int, kind string, perIP int, perUser int) ([]clientKey, error) {
+
+	keys := l.keys(r)
+
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, key := range keys {
+		limit := perIP
+		if key.kind == "user" {
+			limit = perUser
+		}
+
+		if limit > 0 && counts[key.String()] >= limit {
+			MetricsFromContext(r.Context()).Counter("client_limited_total", 1, map[string]string{"kind": strings.Fields(kind)[0], "client": key.kind})
+			return nil, fmt.Errorf("%w: too many %v (limit %d per %v)", ErrTooManyRequests, kind, limit, key.kind)
+		}
+	}
+
+	for _, key := range keys {
+		counts[key.String()]++
+	}
+
+	return keys, nil
+}
+
+func (l *clientLimiter) release(counts map[string]int, keys []clientKey) {
+	l.mutex.Lock()
+	defer l.mutex.Unlock()
+
+	for _, key := range keys {
+		if counts[key.String()]--; counts[key.String()] <= 0 {
+			delete(counts, key.String())
+		}
+	}
+}
+
+func admitClientStream(r *http.Request) (func(), error) {
+
+	slot, ok := r.Context().Value(contextKeyClientSlot).(*clientSlot)
+	if !ok {
+		return func() {}, nil
+	}
+
+	l := slot.limiter
+
+	keys, err := l.acquire(r, l.streams, "streams", l.config.MaxStreamsPerIP, l.config.MaxStreamsPerUser)
+	if err != nil {
+		return nil, err
+	}
+
+	slot.release()
+
+	return func() { l.release(l.streams, keys) }, nil
+}
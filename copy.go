@@ -0,0 +1,26 @@
+package wx
+
+import (
+	"io"
+	"sync"
+)
+
+const copyBufferSize = 32 << 10
+
+var copyBuffers = sync.Pool{
+	New: func() interface{} {
+		buf := make([]byte, copyBufferSize)
+		return &buf
+	},
+}
+
+func copyBody(w io.Writer, r io.Reader) (int64, error) {
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+
+	return io.CopyBuffer(w, r, *buf)
+}
+
+type writerOnly struct {
+	io.Writer
+}
@@ -0,0 +1,105 @@
+package cronrefresh
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/robfig/cron/v3"
+
+	"github.com/reverted/wx"
+)
+
+type Job struct {
+	URL      string
+	Schedule string
+	Header   http.Header
+	Timeout  time.Duration
+}
+
+type Refresher interface {
+	Refresh(ctx context.Context, url string, header http.Header) error
+}
+
+func NewScheduler(logger wx.Logger, refresher Refresher, jobs ...Job) (*scheduler, error) {
+
+	scheduled := []scheduledRefresh{}
+
+	for _, job := range jobs {
+		schedule, err := cron.ParseStandard(job.Schedule)
+		if err != nil {
+			return nil, fmt.Errorf("parse schedule [%v] : %w", job.URL, err)
+		}
+
+		if job.Timeout <= 0 {
+			job.Timeout = time.Minute
+		}
+
+		scheduled = append(scheduled, scheduledRefresh{job, schedule})
+	}
+
+	return &scheduler{
+		Logger:    logger,
+		Refresher: refresher,
+		jobs:      scheduled,
+	}, nil
+}
+
+type scheduledRefresh struct {
+	Job
+	schedule cron.Schedule
+}
+
+type scheduler struct {
+	wx.Logger
+	Refresher
+	jobs []scheduledRefresh
+}
+
+func (s *scheduler) Run(ctx context.Context) {
+
+	var wg sync.WaitGroup
+
+	for _, job := range s.jobs {
+		wg.Add(1)
+		go func(job scheduledRefresh) {
+			defer wg.Done()
+			s.run(ctx, job)
+		}(job)
+	}
+
+	wg.Wait()
+}
+
+func (s *scheduler) run(ctx context.Context, job scheduledRefresh) {
+
+	for {
+		timer := time.NewTimer(time.Until(job.schedule.Next(time.Now())))
+
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+
+		s.refresh(ctx, job)
+	}
+}
+
+func (s *scheduler) refresh(ctx context.Context, job scheduledRefresh) {
+
+	ctx, cancel := context.WithTimeout(ctx, job.Timeout)
+	defer cancel()
+
+	start := time.Now()
+
+	if err := s.Refresher.Refresh(ctx, job.URL, job.Header); err != nil {
+		s.Logger.Errorf("refresh [%v] : %v", job.URL, err)
+		return
+	}
+
+	s.Logger.Infof("refreshed [%v] in %v", job.URL, time.Since(start))
+}
@@ -0,0 +1,116 @@
+package cronrefresh
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"sync"
+	"testing"
+	"time"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Error(...interface{})          {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+func (nopLogger) Info(...interface{})           {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Debug(...interface{})          {}
+
+type recordingRefresher struct {
+	mutex sync.Mutex
+	urls  []string
+	err   error
+}
+
+func (r *recordingRefresher) Refresh(ctx context.Context, url string, header http.Header) error {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	if _, ok := ctx.Deadline(); !ok {
+		return errors.New("no deadline")
+	}
+
+	r.urls = append(r.urls, url)
+	return r.err
+}
+
+func (r *recordingRefresher) refreshed() int {
+	r.mutex.Lock()
+	defer r.mutex.Unlock()
+
+	return len(r.urls)
+}
+
+func TestNewSchedulerRejectsInvalidSchedule(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		schedule string
+		fails    bool
+	}{
+		{"standard schedule", "*/5 * * * *", false},
+		{"descriptor", "@hourly", false},
+		{"interval", "@every 1m", false},
+		{"seconds field", "0 */5 * * * *", true},
+		{"empty schedule", "", true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			_, err := NewScheduler(nopLogger{}, &recordingRefresher{}, Job{URL: "/a", Schedule: test.schedule})
+			if (err != nil) != test.fails {
+				t.Fatalf("expected error %v, got %v", test.fails, err)
+			}
+		})
+	}
+}
+
+func TestSchedulerRefreshesUntilCancelled(t *testing.T) {
+
+	tests := []struct {
+		name string
+		err  error
+	}{
+		{"successful refresh", nil},
+		{"failed refresh", errors.New("upstream unavailable")},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			refresher := &recordingRefresher{err: test.err}
+
+			scheduler, err := NewScheduler(nopLogger{}, refresher, Job{URL: "/a", Schedule: "@every 1s"})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			ctx, cancel := context.WithCancel(context.Background())
+
+			done := make(chan struct{})
+			go func() {
+				scheduler.Run(ctx)
+				close(done)
+			}()
+
+			deadline := time.Now().Add(5 * time.Second)
+			for refresher.refreshed() < 1 && time.Now().Before(deadline) {
+				time.Sleep(5 * time.Millisecond)
+			}
+
+			cancel()
+
+			select {
+			case <-done:
+			case <-time.After(5 * time.Second):
+				t.Fatal("expected run to return after cancel")
+			}
+
+			if refresher.refreshed() < 1 {
+				t.Fatalf("expected a refresh, got %v", refresher.refreshed())
+			}
+		})
+	}
+}
@@ -0,0 +1,47 @@
+package wx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/base64"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+const (
+	contextKeyCSPNonce  contextKey = "csp_nonce"
+	cspNoncePlaceholder            = "{nonce}"
+)
+
+func NewWithCSPNonce(policy string, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		nonce := newCSPNonce()
+
+		w.Header().Set("Content-Security-Policy", strings.ReplaceAll(policy, cspNoncePlaceholder, nonce))
+
+		ctx := context.WithValue(r.Context(), contextKeyCSPNonce, nonce)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func CSPNonce(ctx context.Context) string {
+	nonce, _ := ctx.Value(contextKeyCSPNonce).(string)
+	return nonce
+}
+
+func CSPFuncs(r *http.Request) template.FuncMap {
+	nonce := CSPNonce(r.Context())
+
+	return template.FuncMap{
+		"cspNonce": func() string {
+			return nonce
+		},
+	}
+}
+
+func newCSPNonce() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return base64.StdEncoding.EncodeToString(bytes)
+}
@@ -0,0 +1,29 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+func verifySameOrigin(r *http.Request) error {
+
+	if site := r.Header.Get("Sec-Fetch-Site"); site != "" {
+		if site != "same-origin" {
+			return fmt.Errorf("%w: cross-site request (%v)", ErrForbidden, site)
+		}
+		return nil
+	}
+
+	origin := r.Header.Get("Origin")
+	if origin == "" {
+		return fmt.Errorf("%w: missing origin", ErrForbidden)
+	}
+
+	originUrl, err := url.Parse(origin)
+	if err != nil || originUrl.Host != r.Host {
+		return fmt.Errorf("%w: cross-origin request (%v)", ErrForbidden, origin)
+	}
+
+	return nil
+}
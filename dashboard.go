@@ -0,0 +1,270 @@
+package wx
+
+import (
+	"context"
+	"encoding/json"
+	"html/template"
+	"maps"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+var dashboardTemplate = template.Must(template.New("dashboard").Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>wx dashboard</title>
+<style nonce="{{ .Nonce }}">
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+.tiles { display: flex; flex-wrap: wrap; gap: 1em; }
+.tile { border: 1px solid #ddd; border-radius: 4px; padding: 1em; min-width: 10em; }
+.tile .value { font-size: 1.8em; }
+.tile .label { color: #666; }
+table { border-collapse: collapse; margin-top: 1.5em; }
+td, th { border-bottom: 1px solid #eee; padding: 0.3em 1em 0.3em 0; text-align: left; }
+.ok { color: #2a7a2a; }
+.fail { color: #b22; }
+</style>
+</head>
+<body>
+<h1>wx dashboard</h1>
+<div class="tiles" id="tiles"></div>
+<h2>Health</h2>
+<table id="health"></table>
+<h2>Gauges</h2>
+<table id="gauges"></table>
+<script nonce="{{ .Nonce }}">
+var previous = null;
+
+function sum(series, name, match) {
+	return series.filter(function (s) {
+		return s.name === name && (!match || match(s.tags || {}));
+	}).reduce(function (total, s) { return total + s.value; }, 0);
+}
+
+function rate(data, name, match) {
+	if (!previous) { return 0; }
+	var seconds = (Date.parse(data.time) - Date.parse(previous.time)) / 1000;
+	if (seconds <= 0) { return 0; }
+	return Math.max(0, sum(data.counters, name, match) - sum(previous.counters, name, match)) / seconds;
+}
+
+function row(cells, className) {
+	var tr = document.createElement("tr");
+	cells.forEach(function (cell) {
+		var td = document.createElement("td");
+		td.textContent = cell;
+		tr.appendChild(td);
+	});
+	if (className) { tr.className = className; }
+	return tr;
+}
+
+function render(data) {
+	var hits = sum(data.counters, "cache_requests_total", function (t) { return t.status === "HIT" || t.status === "STALE"; });
+	var lookups = sum(data.counters, "cache_requests_total", function (t) { return t.status !== "BYPASS"; });
+
+	var tiles = [
+		["upstream", data.health.upstream || "-"],
+		["requests / s", rate(data, "http_requests_total").toFixed(1)],
+		["5xx / s", rate(data, "http_requests_total", function (t) { return /^5/.test(t.status); }).toFixed(1)],
+		["in flight", sum(data.gauges, "http_requests_in_flight")],
+		["cache hit ratio", lookups ? (100 * hits / lookups).toFixed(1) + "%" : "-"],
+		["active streams", sum(data.gauges, "streams_active")],
+		["upgraded connections", sum(data.gauges, "upgrade_connections")],
+		["logins / s", rate(data, "auth_events_total", function (t) { return t.type === "login"; }).toFixed(2)],
+		["shed / s", rate(data, "requests_shed_total").toFixed(1)]
+	];
+
+	var container = document.getElementById("tiles");
+	container.replaceChildren();
+	tiles.forEach(function (tile) {
+		var div = document.createElement("div");
+		div.className = "tile";
+		div.innerHTML = "<div class=value></div><div class=label></div>";
+		div.firstChild.textContent = tile[1];
+		div.lastChild.textContent = tile[0];
+		if (tile[0] === "upstream" && tile[1] !== "-") { div.className += tile[1] === "ok" ? " ok" : " fail"; }
+		container.appendChild(div);
+	});
+
+	var health = document.getElementById("health");
+	health.replaceChildren();
+	Object.keys(data.health).sort().forEach(function (name) {
+		var status = data.health[name];
+		health.appendChild(row([name, status], status === "ok" ? "ok" : "fail"));
+	});
+
+	var gauges = document.getElementById("gauges");
+	gauges.replaceChildren();
+	data.gauges.forEach(function (g) {
+		var tags = Object.keys(g.tags || {}).sort().map(function (k) { return k + "=" + g.tags[k]; }).join(" ");
+		gauges.appendChild(row([g.name, tags, g.value]));
+	});
+
+	previous = data;
+}
+
+function poll() {
+	fetch("{{ .DataPath }}", { credentials: "same-origin" })
+		.then(function (resp) { return resp.json(); })
+		.then(render)
+		.catch(function () {})
+		.finally(function () { setTimeout(poll, 2000); });
+}
+
+poll();
+</script>
+</body>
+</html>
+`))
+
+type MetricSample struct {
+	Name  string            `json:"name"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	Value float64           `json:"value"`
+}
+
+type HistogramSample struct {
+	Name  string            `json:"name"`
+	Tags  map[string]string `json:"tags,omitempty"`
+	Count int64             `json:"count"`
+	Sum   float64           `json:"sum"`
+}
+
+type MetricsSnapshot struct {
+	Time       time.Time         `json:"time"`
+	Counters   []MetricSample    `json:"counters"`
+	Gauges     []MetricSample    `json:"gauges"`
+	Histograms []HistogramSample `json:"histograms"`
+}
+
+func NewDashboardMetrics() *dashboardMetrics {
+	return &dashboardMetrics{
+		counters:   map[string]*MetricSample{},
+		gauges:     map[string]*MetricSample{},
+		histograms: map[string]*HistogramSample{},
+	}
+}
+
+type dashboardMetrics struct {
+	mutex      sync.Mutex
+	counters   map[string]*MetricSample
+	gauges     map[string]*MetricSample
+	histograms map[string]*HistogramSample
+}
+
+func (m *dashboardMetrics) Counter(name string, value float64, tags map[string]string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := metricKey(name, tags)
+	if _, found := m.counters[key]; !found {
+		m.counters[key] = &MetricSample{Name: name, Tags: maps.Clone(tags)}
+	}
+	m.counters[key].Value += value
+}
+
+func (m *dashboardMetrics) Gauge(name string, value float64, tags map[string]string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	m.gauges[metricKey(name, tags)] = &MetricSample{Name: name, Tags: maps.Clone(tags), Value: value}
+}
+
+func (m *dashboardMetrics) Histogram(name string, value float64, tags map[string]string) {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	key := metricKey(name, tags)
+	if _, found := m.histograms[key]; !found {
+		m.histograms[key] = &HistogramSample{Name: name, Tags: maps.Clone(tags)}
+	}
+	m.histograms[key].Count++
+	m.histograms[key].Sum += value
+}
+
+func (m *dashboardMetrics) Snapshot() MetricsSnapshot {
+	m.mutex.Lock()
+	defer m.mutex.Unlock()
+
+	snapshot := MetricsSnapshot{
+		Time:       time.Now().UTC(),
+		Counters:   []MetricSample{},
+		Gauges:     []MetricSample{},
+		Histograms: []HistogramSample{},
+	}
+
+	for _, key := range sortedKeys(m.counters) {
+		snapshot.Counters = append(snapshot.Counters, *m.counters[key])
+	}
+
+	for _, key := range sortedKeys(m.gauges) {
+		snapshot.Gauges = append(snapshot.Gauges, *m.gauges[key])
+	}
+
+	for _, key := range sortedKeys(m.histograms) {
+		snapshot.Histograms = append(snapshot.Histograms, *m.histograms[key])
+	}
+
+	return snapshot
+}
+
+func metricKey(name string, tags map[string]string) string {
+
+	pairs := []string{}
+	for k, v := range tags {
+		pairs = append(pairs, k+"="+v)
+	}
+
+	sort.Strings(pairs)
+	return name + "{" + strings.Join(pairs, ",") + "}"
+}
+
+func sortedKeys[V any](values map[string]V) []string {
+
+	keys := make([]string, 0, len(values))
+	for key := range values {
+		keys = append(keys, key)
+	}
+
+	sort.Strings(keys)
+	return keys
+}
+
+func WithDashboard(metrics *dashboardMetrics) adminOpt {
+	return func(a *adminServer) {
+		a.dashboard = metrics
+	}
+}
+
+func (a *adminServer) Dashboard(w http.ResponseWriter, r *http.Request) {
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("Cache-Control", "no-store")
+
+	dashboardTemplate.Execute(w, struct {
+		Nonce    string
+		DataPath string
+	}{CSPNonce(r.Context()), "/admin/dashboard/data"})
+}
+
+func (a *adminServer) DashboardData(w http.ResponseWriter, r *http.Request) {
+
+	ctx, cancel := context.WithTimeout(r.Context(), 5*time.Second)
+	defer cancel()
+
+	_, health := runHealthChecks(ctx, a.HealthChecks)
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+
+	json.NewEncoder(w).Encode(struct {
+		MetricsSnapshot
+		Health map[string]string `json:"health"`
+	}{a.dashboard.Snapshot(), health})
+}
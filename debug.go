@@ -0,0 +1,18 @@
+package wx
+
+import (
+	"expvar"
+	"net/http"
+	"net/http/pprof"
+)
+
+func NewDebugServer() http.Handler {
+	server := http.NewServeMux()
+	server.HandleFunc("/debug/pprof/", pprof.Index)
+	server.HandleFunc("/debug/pprof/cmdline", pprof.Cmdline)
+	server.HandleFunc("/debug/pprof/profile", pprof.Profile)
+	server.HandleFunc("/debug/pprof/symbol", pprof.Symbol)
+	server.HandleFunc("/debug/pprof/trace", pprof.Trace)
+	server.Handle("/debug/vars", expvar.Handler())
+	return server
+}
@@ -0,0 +1,133 @@
+package wx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"html/template"
+	"net/http"
+	"strings"
+)
+
+const contextKeyErrorRenderer contextKey = "error_renderer"
+
+var defaultErrorTemplate = template.Must(template.New("error").Parse(`<!DOCTYPE html>
+<html>
+<head><title>{{.Status}} {{.Title}}</title></head>
+<body>
+<h1>{{.Status}} {{.Title}}</h1>
+{{if .Detail}}<p>{{.Detail}}</p>{{end}}
+{{if .LoginURL}}<p><a href="{{.LoginURL}}">Log in</a></p>{{end}}
+{{if .RequestID}}<p><small>Request ID: {{.RequestID}}</small></p>{{end}}
+</body>
+</html>
+`))
+
+var defaultErrorRenderer = NewErrorRenderer()
+
+type errorRendererOpt func(*errorRenderer)
+
+func WithErrorDetails(enabled bool) errorRendererOpt {
+	return func(e *errorRenderer) {
+		e.details = enabled
+	}
+}
+
+func WithErrorTemplate(tmpl *template.Template) errorRendererOpt {
+	return func(e *errorRenderer) {
+		e.template = tmpl
+	}
+}
+
+func NewErrorRenderer(opts ...errorRendererOpt) *errorRenderer {
+	renderer := &errorRenderer{
+		template: defaultErrorTemplate,
+	}
+
+	for _, opt := range opts {
+		opt(renderer)
+	}
+
+	return renderer
+}
+
+type errorRenderer struct {
+	template *template.Template
+	details  bool
+}
+
+type Problem struct {
+	Type      string `json:"type"`
+	Title     string `json:"title"`
+	Status    int    `json:"status"`
+	Detail    string `json:"detail,omitempty"`
+	Instance  string `json:"instance,omitempty"`
+	RequestID string `json:"request_id,omitempty"`
+	LoginURL  string `json:"login_url,omitempty"`
+}
+
+func (e *errorRenderer) Render(w http.ResponseWriter, r *http.Request, err error) {
+
+	status := StatusCode(err)
+
+	problem := Problem{
+		Type:      "about:blank",
+		Title:     http.StatusText(status),
+		Status:    status,
+		Instance:  r.URL.Path,
+		RequestID: RequestID(r.Context()),
+	}
+
+	if e.details {
+		problem.Detail = err.Error()
+	}
+
+	var loginRequired *LoginRequiredError
+	if errors.As(err, &loginRequired) {
+		problem.LoginURL = loginRequired.LoginURL
+	}
+
+	w.Header().Del("Content-Length")
+	w.Header().Set("X-Content-Type-Options", "nosniff")
+
+	if prefersHTML(r) {
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.WriteHeader(status)
+		e.template.Execute(w, problem)
+		return
+	}
+
+	w.Header().Set("Content-Type", "application/problem+json")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(problem)
+}
+
+func (e *errorRenderer) Handler(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKeyErrorRenderer, e)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func RenderError(w http.ResponseWriter, r *http.Request, err error) {
+	renderer, ok := r.Context().Value(contextKeyErrorRenderer).(*errorRenderer)
+	if !ok {
+		renderer = defaultErrorRenderer
+	}
+
+	ReportError(r.Context(), err, map[string]string{"method": r.Method, "path": r.URL.Path})
+
+	renderer.Render(w, r, err)
+}
+
+func prefersHTML(r *http.Request) bool {
+	accept := r.Header.Get("Accept")
+
+	html := strings.Index(accept, "text/html")
+	if html < 0 {
+		return false
+	}
+
+	json := strings.Index(accept, "json")
+	return json < 0 || html < json
+}
@@ -0,0 +1,49 @@
+package wx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+)
+
+const contextKeyErrorReporter contextKey = "error_reporter"
+
+type ErrorReporter func(ctx context.Context, err error, tags map[string]string)
+
+func NewWithErrorReporter(reporter ErrorReporter, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKeyErrorReporter, reporter)
+
+		defer func() {
+			if v := recover(); v != nil {
+				if v != http.ErrAbortHandler {
+					ReportError(ctx, NewStatusError(http.StatusInternalServerError, fmt.Errorf("panic : %v", v)), map[string]string{"method": r.Method, "path": r.URL.Path})
+				}
+				panic(v)
+			}
+		}()
+
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func ReportError(ctx context.Context, err error, tags map[string]string) {
+	if StatusCode(err) < http.StatusInternalServerError {
+		return
+	}
+
+	reporter, ok := ctx.Value(contextKeyErrorReporter).(ErrorReporter)
+	if !ok {
+		return
+	}
+
+	if tags == nil {
+		tags = map[string]string{}
+	}
+
+	if requestID := RequestID(ctx); requestID != "" {
+		tags["request_id"] = requestID
+	}
+
+	reporter(ctx, err, tags)
+}
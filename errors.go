@@ -0,0 +1,66 @@
+package wx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+)
+
+var (
+	ErrBadRequest         = NewStatusError(http.StatusBadRequest, nil)
+	ErrUnauthorized       = NewStatusError(http.StatusUnauthorized, nil)
+	ErrForbidden          = NewStatusError(http.StatusForbidden, nil)
+	ErrTooManyRequests    = NewStatusError(http.StatusTooManyRequests, nil)
+	ErrBadGateway         = NewStatusError(http.StatusBadGateway, nil)
+	ErrServiceUnavailable = NewStatusError(http.StatusServiceUnavailable, nil)
+)
+
+func NewStatusError(statusCode int, err error) *StatusError {
+	return &StatusError{
+		StatusCode: statusCode,
+		Message:    http.StatusText(statusCode),
+		Err:        err,
+	}
+}
+
+type StatusError struct {
+	StatusCode int
+	Message    string
+	Err        error
+}
+
+func (e *StatusError) Error() string {
+	if e.Err == nil {
+		return fmt.Sprintf("status %d: %v", e.StatusCode, e.Message)
+	}
+	return fmt.Sprintf("status %d: %v: %v", e.StatusCode, e.Message, e.Err)
+}
+
+func (e *StatusError) Unwrap() error {
+	return e.Err
+}
+
+func (e *StatusError) Is(target error) bool {
+	t, ok := target.(*StatusError)
+	return ok && t.Err == nil && t.StatusCode == e.StatusCode
+}
+
+func (e *StatusError) Status() int {
+	return e.StatusCode
+}
+
+func StatusCode(err error) int {
+	var statusError *StatusError
+	if errors.As(err, &statusError) {
+		return statusError.StatusCode
+	}
+	return http.StatusInternalServerError
+}
+
+// Deprecated: use StatusError.
+type HttpError = StatusError
+
+// Deprecated: use NewStatusError.
+func NewHttpError(statusCode int) *HttpError {
+	return NewStatusError(statusCode, nil)
+}
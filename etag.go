@@ -0,0 +1,74 @@
+package wx
+
+import (
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func NewWithETag(logger Logger, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		writer := NewBufferedWriter(w, bufferETag)
+		handler.ServeHTTP(writer, r)
+
+		if !writer.Buffering() {
+			return
+		}
+
+		header := w.Header()
+
+		etag := header.Get("ETag")
+		if etag == "" {
+			sum := sha256.Sum256(writer.Bytes())
+			etag = fmt.Sprintf(`W/"%x"`, sum[:16])
+			header.Set("ETag", etag)
+		}
+
+		if notModified(r, etag, header.Get("Last-Modified")) {
+			header.Del("Content-Type")
+			header.Del("Content-Length")
+			w.WriteHeader(http.StatusNotModified)
+			return
+		}
+
+		if err := writer.Commit(writer.StatusCode(), writer.Bytes()); err != nil {
+			logger.Errorf("write etag response : %v", err)
+		}
+	})
+}
+
+func bufferETag(statusCode int, header http.Header) bool {
+	return statusCode == http.StatusOK && header.Get("Content-Type") != "text/event-stream"
+}
+
+func notModified(r *http.Request, etag string, lastModified string) bool {
+
+	if match := r.Header.Get("If-None-Match"); match != "" {
+		for _, candidate := range strings.Split(match, ",") {
+			candidate = strings.TrimSpace(candidate)
+			if candidate == "*" || strings.TrimPrefix(candidate, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	if err != nil {
+		return false
+	}
+
+	modified, err := http.ParseTime(lastModified)
+	if err != nil {
+		return false
+	}
+
+	return !modified.Truncate(time.Second).After(since)
+}
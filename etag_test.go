@@ -0,0 +1,81 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestETag(t *testing.T) {
+
+	modified := time.Date(2024, 1, 2, 3, 4, 5, 0, time.UTC).Format(http.TimeFormat)
+
+	handler := NewWithETag(nopLogger{}, http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain")
+		if r.URL.Path == "/modified" {
+			w.Header().Set("Last-Modified", modified)
+		}
+		if r.Method != http.MethodHead {
+			w.Write([]byte("hello"))
+		}
+	}))
+
+	serve := func(method string, path string, headers map[string]string) *httptest.ResponseRecorder {
+		r := httptest.NewRequest(method, path, nil)
+		for name, value := range headers {
+			r.Header.Set(name, value)
+		}
+
+		w := httptest.NewRecorder()
+		handler.ServeHTTP(w, r)
+		return w
+	}
+
+	etag := serve(http.MethodGet, "/hello", nil).Header().Get("ETag")
+	if etag == "" {
+		t.Fatal("expected etag")
+	}
+
+	tests := []struct {
+		name         string
+		method       string
+		path         string
+		headers      map[string]string
+		status       int
+		validate     bool
+		lastModified string
+	}{
+		{"get", http.MethodGet, "/hello", nil, http.StatusOK, true, ""},
+		{"head", http.MethodHead, "/hello", nil, http.StatusOK, false, ""},
+		{"if none match", http.MethodGet, "/hello", map[string]string{"If-None-Match": etag}, http.StatusNotModified, true, ""},
+		{"if none match other", http.MethodGet, "/hello", map[string]string{"If-None-Match": `W/"other"`}, http.StatusOK, true, ""},
+		{"if modified since without last modified", http.MethodGet, "/hello", map[string]string{"If-Modified-Since": modified}, http.StatusOK, true, ""},
+		{"upstream last modified", http.MethodGet, "/modified", nil, http.StatusOK, true, modified},
+		{"if modified since", http.MethodGet, "/modified", map[string]string{"If-Modified-Since": modified}, http.StatusNotModified, true, modified},
+		{"modified after if modified since", http.MethodGet, "/modified", map[string]string{"If-Modified-Since": time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC).Format(http.TimeFormat)}, http.StatusOK, true, modified},
+		{"head if none match", http.MethodHead, "/hello", map[string]string{"If-None-Match": etag}, http.StatusOK, false, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			w := serve(test.method, test.path, test.headers)
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if test.validate && w.Header().Get("ETag") != etag {
+				t.Fatalf("expected etag %q, got %q", etag, w.Header().Get("ETag"))
+			}
+
+			if !test.validate && w.Header().Get("ETag") != "" {
+				t.Fatalf("expected no etag, got %q", w.Header().Get("ETag"))
+			}
+
+			if w.Header().Get("Last-Modified") != test.lastModified {
+				t.Fatalf("expected last modified %q, got %q", test.lastModified, w.Header().Get("Last-Modified"))
+			}
+		})
+	}
+}
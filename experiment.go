@@ -0,0 +1,91 @@
+package wx
+
+import (
+	"hash/fnv"
+	"net/http"
+	"net/url"
+)
+
+type Experiment struct {
+	Name    string
+	Path    string
+	Header  map[string]string
+	Cookie  map[string]string
+	Claim   map[string]string
+	Percent int
+	Target  *url.URL
+}
+
+func (e Experiment) Matches(r *http.Request) bool {
+	if e.Path != "" && !matchPath(e.Path, r.URL.Path) {
+		return false
+	}
+
+	for name, value := range e.Header {
+		if !matchValue(r.Header.Values(name), value) {
+			return false
+		}
+	}
+
+	for name, value := range e.Cookie {
+		cookie, err := r.Cookie(name)
+		if err != nil || !matchValue([]string{cookie.Value}, value) {
+			return false
+		}
+	}
+
+	if len(e.Claim) > 0 {
+		identity, ok := UserFromContext(r.Context())
+		if !ok {
+			return false
+		}
+
+		for name, value := range e.Claim {
+			if !matchValue(claimValues(identity.Claims, name), value) {
+				return false
+			}
+		}
+	}
+
+	return e.InBucket(r)
+}
+
+func (e Experiment) InBucket(r *http.Request) bool {
+	if e.Percent <= 0 || e.Percent >= 100 {
+		return true
+	}
+
+	identity, ok := UserFromContext(r.Context())
+	if !ok || identity.Subject == "" {
+		return false
+	}
+
+	hash := fnv.New32a()
+	hash.Write([]byte(e.Name + ":" + identity.Subject))
+
+	return int(hash.Sum32()%100) < e.Percent
+}
+
+func WithExperiments(experiments ...Experiment) proxyOpt {
+	return func(p *proxyServer) {
+		p.experiments = append(p.experiments, experiments...)
+	}
+}
+
+func (p *proxyServer) experiment(r *http.Request) (Experiment, bool) {
+	for _, experiment := range p.experiments {
+		if experiment.Matches(r) {
+			return experiment, true
+		}
+	}
+	return Experiment{}, false
+}
+
+func matchValue(values []string, value string) bool {
+	for _, v := range values {
+		if value == "" || v == value {
+			return true
+		}
+	}
+	return false
+}
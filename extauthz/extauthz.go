@@ -0,0 +1,113 @@
+package extauthz
+
+import (
+	"context"
+	"net/http"
+	"strings"
+
+	corev3 "github.com/envoyproxy/go-control-plane/envoy/config/core/v3"
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	typev3 "github.com/envoyproxy/go-control-plane/envoy/type/v3"
+	rpcstatus "google.golang.org/genproto/googleapis/rpc/status"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+
+	"github.com/reverted/wx"
+)
+
+type identityResolver interface {
+	Identity(r *http.Request) (*wx.Identity, error)
+}
+
+func Register(server *grpc.Server, logger wx.Logger, authServer wx.AuthServer) {
+	authv3.RegisterAuthorizationServer(server, NewServer(logger, authServer))
+}
+
+func NewServer(logger wx.Logger, authServer wx.AuthServer) *extAuthzServer {
+	return &extAuthzServer{
+		Logger:     logger,
+		AuthServer: authServer,
+	}
+}
+
+type extAuthzServer struct {
+	wx.Logger
+	wx.AuthServer
+}
+
+func (e *extAuthzServer) Check(ctx context.Context, check *authv3.CheckRequest) (*authv3.CheckResponse, error) {
+
+	resolver, ok := e.AuthServer.(identityResolver)
+	if !ok {
+		return extAuthzDenied(codes.Unimplemented, http.StatusInternalServerError, "auth server cannot resolve identities"), nil
+	}
+
+	attributes := check.GetAttributes().GetRequest().GetHttp()
+
+	r, err := http.NewRequestWithContext(ctx, attributes.GetMethod(), attributes.GetPath(), nil)
+	if err != nil {
+		return extAuthzDenied(codes.InvalidArgument, http.StatusBadRequest, err.Error()), nil
+	}
+
+	for h, v := range attributes.GetHeaders() {
+		r.Header.Set(h, v)
+	}
+	r.Host = attributes.GetHost()
+
+	identity, err := resolver.Identity(r)
+	if err != nil {
+		e.Logger.Debug(err)
+		return extAuthzDenied(codes.Unauthenticated, wx.StatusCode(err), err.Error(), wx.BearerChallenge("")), nil
+	}
+
+	if role := check.GetAttributes().GetContextExtensions()["role"]; role != "" && !identity.HasRole(role) {
+		e.Logger.Debug("missing role : ", role)
+		return extAuthzDenied(codes.PermissionDenied, http.StatusForbidden, "missing role "+role, wx.BearerChallenge("insufficient_scope", "scope", role)), nil
+	}
+
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(codes.OK)},
+		HttpResponse: &authv3.CheckResponse_OkResponse{
+			OkResponse: &authv3.OkHttpResponse{
+				Headers: extAuthzHeaders(map[string]string{
+					"x-auth-request-user":   identity.Subject,
+					"x-auth-request-email":  identity.Email,
+					"x-auth-request-groups": strings.Join(identity.Roles, ","),
+					"authorization":         identity.Token,
+				}),
+				HeadersToRemove: []string{"cookie"},
+			},
+		},
+	}, nil
+}
+
+func extAuthzDenied(code codes.Code, statusCode int, message string, challenge ...string) *authv3.CheckResponse {
+	headers := map[string]string{}
+	for _, c := range challenge {
+		headers["www-authenticate"] = c
+	}
+
+	return &authv3.CheckResponse{
+		Status: &rpcstatus.Status{Code: int32(code), Message: message},
+		HttpResponse: &authv3.CheckResponse_DeniedResponse{
+			DeniedResponse: &authv3.DeniedHttpResponse{
+				Status:  &typev3.HttpStatus{Code: typev3.StatusCode(statusCode)},
+				Headers: extAuthzHeaders(headers),
+			},
+		},
+	}
+}
+
+func extAuthzHeaders(headers map[string]string) []*corev3.HeaderValueOption {
+	options := []*corev3.HeaderValueOption{}
+	for k, v := range headers {
+		if v == "" {
+			continue
+		}
+		options = append(options, &corev3.HeaderValueOption{
+			Header:       &corev3.HeaderValue{Key: k, Value: v},
+			AppendAction: corev3.HeaderValueOption_OVERWRITE_IF_EXISTS_OR_ADD,
+		})
+	}
+	return options
+}
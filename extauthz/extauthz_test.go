@@ -0,0 +1,93 @@
+package extauthz
+
+import (
+	"context"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"testing"
+	"time"
+
+	authv3 "github.com/envoyproxy/go-control-plane/envoy/service/auth/v3"
+	"google.golang.org/grpc/codes"
+
+	"github.com/reverted/wx"
+)
+
+type nopLogger struct{}
+
+func (nopLogger) Error(...interface{})          {}
+func (nopLogger) Errorf(string, ...interface{}) {}
+func (nopLogger) Info(...interface{})           {}
+func (nopLogger) Infof(string, ...interface{})  {}
+func (nopLogger) Debug(...interface{})          {}
+
+func unsignedToken(t *testing.T, claims map[string]interface{}) string {
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return base64.RawURLEncoding.EncodeToString([]byte(`{"alg":"none"}`)) + "." + base64.RawURLEncoding.EncodeToString(payload) + "."
+}
+
+func TestCheckRequiresVerifiedIdentity(t *testing.T) {
+
+	keyring := wx.NewKeyring([]byte("cookie-key"))
+	server := NewServer(nopLogger{}, wx.NewAuthServer(nopLogger{}, wx.WithCookieKeyring(keyring)))
+
+	token := "Bearer " + unsignedToken(t, map[string]interface{}{"sub": "alice", "roles": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()})
+
+	sealed, err := keyring.Seal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		cookie string
+		role   string
+		code   codes.Code
+	}{
+		{"sealed cookie", sealed, "", codes.OK},
+		{"sealed cookie with role", sealed, "admin", codes.OK},
+		{"sealed cookie without role", sealed, "owner", codes.PermissionDenied},
+		{"forged cookie", token, "", codes.Unauthenticated},
+		{"forged cookie with role", token, "admin", codes.Unauthenticated},
+		{"no cookie", "", "", codes.Unauthenticated},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			headers := map[string]string{}
+			if test.cookie != "" {
+				headers["cookie"] = (&http.Cookie{Name: "auth", Value: test.cookie}).String()
+			}
+
+			check := &authv3.CheckRequest{
+				Attributes: &authv3.AttributeContext{
+					Request: &authv3.AttributeContext_Request{
+						Http: &authv3.AttributeContext_HttpRequest{
+							Method:  http.MethodGet,
+							Path:    "/private",
+							Host:    "wx.example.com",
+							Headers: headers,
+						},
+					},
+					ContextExtensions: map[string]string{"role": test.role},
+				},
+			}
+
+			resp, err := server.Check(context.Background(), check)
+			if err != nil {
+				t.Fatalf("unexpected error : %v", err)
+			}
+
+			if code := codes.Code(resp.GetStatus().GetCode()); code != test.code {
+				t.Fatalf("expected %v, got %v", test.code, code)
+			}
+		})
+	}
+}
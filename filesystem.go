@@ -0,0 +1,18 @@
+package wx
+
+import (
+	"io/fs"
+	"net/http"
+)
+
+func NewFileSystemFS(fs http.FileSystem) fs.FS {
+	return &fileSystemFS{fs}
+}
+
+type fileSystemFS struct {
+	http.FileSystem
+}
+
+func (f *fileSystemFS) Open(name string) (fs.File, error) {
+	return f.FileSystem.Open("/" + name)
+}
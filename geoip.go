@@ -0,0 +1,93 @@
+package wx
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const contextKeyCountry contextKey = "country"
+
+type GeoAction int
+
+const (
+	GeoBlock GeoAction = iota
+	GeoChallenge
+)
+
+type CountryLookup func(ip netip.Addr) (string, error)
+
+type GeoRule struct {
+	Path      string
+	Countries []string
+	Action    GeoAction
+}
+
+func (rule GeoRule) Matches(path string, country string) bool {
+	if rule.Path != "" && !matchPath(rule.Path, path) {
+		return false
+	}
+
+	for _, c := range rule.Countries {
+		if strings.EqualFold(c, country) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func NewGeoFilter(logger Logger, lookup CountryLookup, loginPath string, rules ...GeoRule) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			r.Header.Del("X-Country")
+
+			ip, ok := ClientIP(r)
+			if !ok {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			country, err := lookup(ip)
+			if err != nil {
+				logger.Errorf("geoip : %v", err)
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			r = r.WithContext(context.WithValue(r.Context(), contextKeyCountry, country))
+			r.Header.Set("X-Country", country)
+
+			for _, rule := range rules {
+				if !rule.Matches(r.URL.Path, country) {
+					continue
+				}
+
+				if _, authenticated := UserFromContext(r.Context()); rule.Action == GeoChallenge && authenticated {
+					continue
+				}
+
+				Audit(r, AuditAuthorizationDenied, map[string]string{"path": r.URL.Path, "reason": "country", "country": country})
+				logger.Infof("country denied : %v : %v", country, r.URL.Path)
+
+				if rule.Action == GeoChallenge {
+					ChallengeLogin(w, r, loginPath, fmt.Errorf("%w: country %v requires login", errMissingCredentials, country))
+					return
+				}
+
+				RenderError(w, r, fmt.Errorf("%w: country %v not permitted", ErrForbidden, country))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func Country(r *http.Request) (string, bool) {
+	country, ok := r.Context().Value(contextKeyCountry).(string)
+	return country, ok
+}
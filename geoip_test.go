@@ -0,0 +1,54 @@
+package wx
+
+import (
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+)
+
+func TestGeoFilterCountryHeader(t *testing.T) {
+
+	lookup := func(ip netip.Addr) (string, error) {
+		if ip.String() == "198.51.100.1" {
+			return "", errors.New("not found")
+		}
+		return "NZ", nil
+	}
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		inbound    string
+		country    string
+	}{
+		{"looked up country", "203.0.113.7:1234", "", "NZ"},
+		{"spoofed header replaced", "203.0.113.7:1234", "US", "NZ"},
+		{"spoofed header removed on lookup failure", "198.51.100.1:1234", "US", ""},
+		{"spoofed header removed without client ip", "pipe", "US", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var country string
+
+			handler := NewGeoFilter(nopLogger{}, lookup, "/auth/login")(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				country = r.Header.Get("X-Country")
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			if test.inbound != "" {
+				r.Header.Set("X-Country", test.inbound)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			if country != test.country {
+				t.Fatalf("expected %q, got %q", test.country, country)
+			}
+		})
+	}
+}
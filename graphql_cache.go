@@ -0,0 +1,240 @@
+package wx
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+const (
+	graphQLKeyMarker = "#graphql="
+	maxGraphQLBody   = 64 << 10
+)
+
+type GraphQLOperation struct {
+	Name string
+	TTL  time.Duration
+}
+
+type graphQLDefinition struct {
+	Kind string
+	Name string
+}
+
+type graphQLRequest struct {
+	OperationName string                 `json:"operationName,omitempty"`
+	Query         string                 `json:"query,omitempty"`
+	Variables     map[string]interface{} `json:"variables,omitempty"`
+	Extensions    map[string]interface{} `json:"extensions,omitempty"`
+}
+
+func NewGraphQLCache(cache *proxyCache, next http.Handler, operations ...GraphQLOperation) http.Handler {
+
+	ttls := map[string]time.Duration{}
+	for _, operation := range operations {
+		if operation.TTL > 0 {
+			ttls[operation.Name] = operation.TTL
+		} else {
+			ttls[operation.Name] = cache.Duration
+		}
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		if r.Method != http.MethodPost || r.Body == nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		body, err := io.ReadAll(io.LimitReader(r.Body, maxGraphQLBody+1))
+		if err != nil {
+			RenderError(w, r, NewStatusError(http.StatusBadRequest, fmt.Errorf("read body : %w", err)))
+			return
+		}
+
+		r.Body = struct {
+			io.Reader
+			io.Closer
+		}{io.MultiReader(bytes.NewReader(body), r.Body), r.Body}
+
+		if len(body) > maxGraphQLBody {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		var query graphQLRequest
+		if err := json.Unmarshal(body, &query); err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		operation, ok := graphQLOperation(query)
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		ttl, ok := ttls[operation]
+		if !ok {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		canonical, err := json.Marshal(query)
+		if err != nil {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		cache.serve(w, r, r.URL.String()+graphQLKeyMarker+base64.RawURLEncoding.EncodeToString(canonical), ttl)
+	})
+}
+
+func graphQLOperation(query graphQLRequest) (string, bool) {
+
+	if strings.TrimSpace(query.Query) == "" {
+		return query.OperationName, query.OperationName != ""
+	}
+
+	definitions, err := parseGraphQLDefinitions(query.Query)
+	if err != nil {
+		return "", false
+	}
+
+	var selected *graphQLDefinition
+	for i, definition := range definitions {
+		switch definition.Kind {
+		case "fragment":
+			continue
+		case "query":
+		default:
+			return "", false
+		}
+
+		if query.OperationName != "" && definition.Name != query.OperationName {
+			continue
+		}
+
+		if selected != nil {
+			return "", false
+		}
+
+		selected = &definitions[i]
+	}
+
+	if selected == nil {
+		return "", false
+	}
+
+	return selected.Name, true
+}
+
+func parseGraphQLDefinitions(document string) ([]graphQLDefinition, error) {
+
+	definitions := []graphQLDefinition{}
+	depth, open, named := 0, false, false
+
+	for i := 0; i < len(document); {
+		c := document[i]
+
+		switch {
+		case c == ' ' || c == '\t' || c == '\n' || c == '\r' || c == ',':
+			i++
+
+		case c == '#':
+			for i < len(document) && document[i] != '\n' && document[i] != '\r' {
+				i++
+			}
+
+		case strings.HasPrefix(document[i:], `"""`):
+			end := strings.Index(strings.ReplaceAll(document[i+3:], `\"""`, "xxxx"), `"""`)
+			if end < 0 {
+				return nil, errors.New("unterminated block string")
+			}
+			i += 3 + end + 3
+
+		case c == '"':
+			for i++; ; i++ {
+				if i >= len(document) || document[i] == '\n' || document[i] == '\r' {
+					return nil, errors.New("unterminated string")
+				}
+				if document[i] == '\\' {
+					i++
+					continue
+				}
+				if document[i] == '"' {
+					i++
+					break
+				}
+			}
+
+		case c == '{' || c == '(' || c == '[':
+			if depth == 0 && c == '{' {
+				if !open {
+					definitions = append(definitions, graphQLDefinition{Kind: "query"})
+				}
+				open = false
+			}
+			named = false
+			depth++
+			i++
+
+		case c == '}' || c == ')' || c == ']':
+			if depth--; depth < 0 {
+				return nil, errors.New("unbalanced document")
+			}
+			i++
+
+		case c == '_' || c >= 'A' && c <= 'Z' || c >= 'a' && c <= 'z':
+			start := i
+			for i < len(document) && (document[i] == '_' || document[i] >= '0' && document[i] <= '9' || document[i] >= 'A' && document[i] <= 'Z' || document[i] >= 'a' && document[i] <= 'z') {
+				i++
+			}
+
+			if depth > 0 {
+				continue
+			}
+
+			name := document[start:i]
+			switch {
+			case !open && (name == "query" || name == "mutation" || name == "subscription" || name == "fragment"):
+				definitions = append(definitions, graphQLDefinition{Kind: name})
+				open, named = true, true
+			case named:
+				definitions[len(definitions)-1].Name = name
+				named = false
+			}
+
+		default:
+			named = false
+			i++
+		}
+	}
+
+	if depth != 0 || open {
+		return nil, errors.New("unbalanced document")
+	}
+
+	return definitions, nil
+}
+
+func graphQLKeyRequest(url string) (string, []byte, bool) {
+
+	endpoint, encoded, found := strings.Cut(url, graphQLKeyMarker)
+	if !found {
+		return "", nil, false
+	}
+
+	body, err := base64.RawURLEncoding.DecodeString(encoded)
+	if err != nil {
+		return "", nil, false
+	}
+
+	return endpoint, body, true
+}
@@ -0,0 +1,43 @@
+package wx
+
+import (
+	"testing"
+)
+
+func TestGraphQLOperation(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		request   graphQLRequest
+		operation string
+		cacheable bool
+	}{
+		{"named query", graphQLRequest{Query: "query Products { products { id } }"}, "Products", true},
+		{"anonymous query", graphQLRequest{Query: "{ products { id } }"}, "", true},
+		{"leading comment", graphQLRequest{Query: "# mutation Hidden\nquery Products { products { id } }"}, "Products", true},
+		{"comment before mutation", graphQLRequest{Query: "# cached\nmutation Delete { delete(id: 1) }"}, "", false},
+		{"description before mutation", graphQLRequest{Query: "\"\"\"query\"\"\" mutation Delete { delete(id: 1) }"}, "", false},
+		{"operation name selects query", graphQLRequest{OperationName: "Products", Query: "query Users { users { id } } query Products { products { id } }"}, "Products", true},
+		{"operation name not found", graphQLRequest{OperationName: "Orders", Query: "query Products { products { id } }"}, "", false},
+		{"operation name ignores document name", graphQLRequest{OperationName: "Products", Query: "query Users { users { id } }"}, "", false},
+		{"ambiguous without operation name", graphQLRequest{Query: "query Users { users { id } } query Products { products { id } }"}, "", false},
+		{"mutation alongside query", graphQLRequest{OperationName: "Products", Query: "query Products { products { id } } mutation Delete { delete(id: 1) }"}, "", false},
+		{"subscription", graphQLRequest{Query: "subscription Updates { updates { id } }"}, "", false},
+		{"fragments", graphQLRequest{Query: "query Products { products { ...Fields } } fragment Fields on Product { id name }"}, "Products", true},
+		{"variables and directives", graphQLRequest{Query: "query Products($first: Int = 10, $filter: Filter = {mutation: \"x\"}) @cached { products(first: $first) { id } }"}, "Products", true},
+		{"field named mutation", graphQLRequest{Query: "query Audit { mutation { id } }"}, "Audit", true},
+		{"unterminated string", graphQLRequest{Query: "query Products { products(name: \"x) { id } }"}, "", false},
+		{"unbalanced", graphQLRequest{Query: "query Products { products { id }"}, "", false},
+		{"persisted query", graphQLRequest{OperationName: "Products", Extensions: map[string]interface{}{"persistedQuery": map[string]interface{}{"version": 1}}}, "Products", true},
+		{"persisted query without name", graphQLRequest{}, "", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			operation, cacheable := graphQLOperation(test.request)
+			if cacheable != test.cacheable || operation != test.operation {
+				t.Fatalf("expected %q %v, got %q %v", test.operation, test.cacheable, operation, cacheable)
+			}
+		})
+	}
+}
@@ -0,0 +1,114 @@
+package wx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func WithIDTokenJWKS(url string, issuer string, clientID string, refresh time.Duration) authOpt {
+	return func(a *authServer) {
+		a.idTokens = NewJWKSVerifier(http.DefaultClient, url, issuer, clientID, refresh)
+	}
+}
+
+func (a *authServer) claimsCookieName(name string) string {
+	return name + "_claims"
+}
+
+func (a *authServer) setClaims(ctx context.Context, w http.ResponseWriter, name string, token *oauth2.Token, expiry time.Time) error {
+
+	idToken, _ := token.Extra("id_token").(string)
+
+	if a.keyring == nil || idToken == "" {
+		http.SetCookie(w, &http.Cookie{
+			Name:   a.claimsCookieName(name),
+			Path:   "/",
+			MaxAge: -1,
+		})
+		return nil
+	}
+
+	claims, err := a.verifyIDToken(ctx, idToken)
+	if err != nil {
+		return err
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return NewStatusError(http.StatusInternalServerError, fmt.Errorf("encode claims : %w", err))
+	}
+
+	sealed, err := a.keyring.Seal(string(payload))
+	if err != nil {
+		return NewStatusError(http.StatusInternalServerError, fmt.Errorf("seal claims : %w", err))
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.claimsCookieName(name),
+		Value:    sealed,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+	})
+
+	return nil
+}
+
+func (a *authServer) verifyIDToken(ctx context.Context, idToken string) (map[string]interface{}, error) {
+
+	var claims map[string]interface{}
+	var err error
+
+	if a.idTokens != nil {
+		claims, err = a.idTokens.Verify(ctx, idToken)
+	} else {
+		// The id_token came straight from the token endpoint over TLS, so without keys
+		// its contents are trusted but it must still have been issued to this client.
+		claims, err = a.claims(idToken)
+		if err == nil && a.Config.ClientID != "" && !slices.Contains(claimStrings(claims["aud"]), a.Config.ClientID) {
+			err = fmt.Errorf("%w: id token not issued to %q", ErrUnauthorized, a.Config.ClientID)
+		}
+	}
+
+	if err != nil {
+		return nil, err
+	}
+
+	if azp, ok := claims["azp"].(string); ok && a.Config.ClientID != "" && azp != a.Config.ClientID {
+		return nil, fmt.Errorf("%w: id token issued to %q", ErrUnauthorized, azp)
+	}
+
+	return claims, nil
+}
+
+func (a *authServer) sealedClaims(r *http.Request, name string) (map[string]interface{}, bool) {
+
+	if a.keyring == nil {
+		return nil, false
+	}
+
+	cookie, err := r.Cookie(a.claimsCookieName(name))
+	if err != nil {
+		return nil, false
+	}
+
+	value, err := a.keyring.Open(cookie.Value)
+	if err != nil {
+		a.Logger.Debug("open claims : ", err)
+		return nil, false
+	}
+
+	var claims map[string]interface{}
+	if err := json.Unmarshal([]byte(value), &claims); err != nil {
+		a.Logger.Debug("decode claims : ", err)
+		return nil, false
+	}
+
+	return claims, true
+}
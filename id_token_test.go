@@ -0,0 +1,142 @@
+package wx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestCallbackSealsIDTokenClaims(t *testing.T) {
+
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	otherKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := jwksServer(t, rsaKey, ecKey)
+	ring := NewKeyring([]byte("cookie-key"))
+
+	claims := func(overrides map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"sub": "alice",
+			"iss": "https://idp.example.com",
+			"aud": "client",
+			"exp": time.Now().Add(time.Hour).Unix(),
+		}
+		for key, value := range overrides {
+			claims[key] = value
+		}
+		return claims
+	}
+
+	verifier := WithIDTokenJWKS(jwks.URL, "https://idp.example.com", "client", time.Hour)
+
+	tests := []struct {
+		name    string
+		opts    []authOpt
+		idToken string
+		status  int
+		subject string
+	}{
+		{"signed id token", []authOpt{verifier}, rsaToken(t, rsaKey, "rsa", claims(nil)), http.StatusTemporaryRedirect, "alice"},
+		{"id token signed by unknown key", []authOpt{verifier}, rsaToken(t, otherKey, "rsa", claims(nil)), http.StatusUnauthorized, ""},
+		{"id token for other client", []authOpt{verifier}, rsaToken(t, rsaKey, "rsa", claims(map[string]interface{}{"aud": "other-app"})), http.StatusUnauthorized, ""},
+		{"id token from other issuer", []authOpt{verifier}, rsaToken(t, rsaKey, "rsa", claims(map[string]interface{}{"iss": "https://other.example.com"})), http.StatusUnauthorized, ""},
+		{"id token authorized for other party", []authOpt{verifier}, rsaToken(t, rsaKey, "rsa", claims(map[string]interface{}{"azp": "other-app"})), http.StatusUnauthorized, ""},
+		{"unsigned id token without verifier", nil, unsignedToken(t, claims(nil)), http.StatusTemporaryRedirect, "alice"},
+		{"unsigned id token for other client", nil, unsignedToken(t, claims(map[string]interface{}{"aud": "other-app"})), http.StatusUnauthorized, ""},
+		{"missing id token", []authOpt{verifier}, "", http.StatusTemporaryRedirect, ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				response := map[string]interface{}{
+					"access_token": "opaque-access-token",
+					"token_type":   "Bearer",
+					"expires_in":   3600,
+				}
+				if test.idToken != "" {
+					response["id_token"] = test.idToken
+				}
+				w.Header().Set("Content-Type", "application/json")
+				json.NewEncoder(w).Encode(response)
+			}))
+			defer idp.Close()
+
+			config := oauth2Config()
+			config.Endpoint = oauth2.Endpoint{AuthURL: idp.URL + "/authorize", TokenURL: idp.URL + "/token"}
+
+			opts := append([]authOpt{WithOAuthConfig(config), WithCookieKeyring(ring)}, test.opts...)
+			a := NewAuthServer(nopLogger{}, opts...).(*authServer)
+
+			login := httptest.NewRecorder()
+			a.Login(login, httptest.NewRequest(http.MethodGet, "https://wx.example.com/auth/login?redirect_uri=/home", nil))
+
+			location, err := url.Parse(login.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			callback := httptest.NewRequest(http.MethodGet, "https://wx.example.com/auth/callback?"+url.Values{"code": {"code"}, "state": {location.Query().Get("state")}}.Encode(), nil)
+			for _, cookie := range login.Result().Cookies() {
+				callback.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			a.Callback(w, callback)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v : %v", test.status, w.Code, w.Body.String())
+			}
+
+			if test.status != http.StatusTemporaryRedirect {
+				return
+			}
+
+			r := httptest.NewRequest(http.MethodGet, "https://wx.example.com/", nil)
+			for _, cookie := range w.Result().Cookies() {
+				if cookie.MaxAge >= 0 {
+					r.AddCookie(cookie)
+				}
+			}
+
+			identity, err := a.identity(r)
+
+			if test.subject == "" {
+				if err == nil {
+					t.Fatalf("expected opaque session to be rejected, got %v", identity.Subject)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error : %v", err)
+			}
+
+			if identity.Subject != test.subject || identity.Token != "Bearer opaque-access-token" {
+				t.Fatalf("expected %v with access token, got %v %v", test.subject, identity.Subject, identity.Token)
+			}
+		})
+	}
+}
@@ -0,0 +1,87 @@
+package wx
+
+import (
+	"context"
+	"strings"
+	"time"
+)
+
+const contextKeyIdentity contextKey = "identity"
+
+type Identity struct {
+	Subject  string
+	Email    string
+	Roles    []string
+	Scopes   []string
+	Claims   map[string]interface{}
+	Expiry   time.Time
+	AuthTime time.Time
+	Token    string
+	Actor    string
+}
+
+func NewIdentity(token string, claims map[string]interface{}, roleClaim string) *Identity {
+	identity := &Identity{
+		Roles:  claimValues(claims, roleClaim),
+		Claims: claims,
+		Token:  token,
+	}
+
+	identity.Subject, _ = claims["sub"].(string)
+	identity.Email, _ = claims["email"].(string)
+
+	if exp, ok := claims["exp"].(float64); ok {
+		identity.Expiry = time.Unix(int64(exp), 0)
+	}
+
+	return identity
+}
+
+func (i *Identity) Expired() bool {
+	return !i.Expiry.IsZero() && i.Expiry.Before(time.Now())
+}
+
+func (i *Identity) HasRole(role string) bool {
+	for _, r := range i.Roles {
+		if r == role {
+			return true
+		}
+	}
+	return false
+}
+
+func ContextWithUser(ctx context.Context, identity *Identity) context.Context {
+	return context.WithValue(ctx, contextKeyIdentity, identity)
+}
+
+func UserFromContext(ctx context.Context) (*Identity, bool) {
+	identity, ok := ctx.Value(contextKeyIdentity).(*Identity)
+	return identity, ok
+}
+
+func claimValues(claims map[string]interface{}, name string) []string {
+
+	var claim interface{} = claims
+	for _, part := range strings.Split(name, ".") {
+		values, ok := claim.(map[string]interface{})
+		if !ok {
+			return nil
+		}
+		claim = values[part]
+	}
+
+	switch t := claim.(type) {
+	case string:
+		return strings.FieldsFunc(t, func(r rune) bool { return r == ' ' || r == ',' })
+	case []interface{}:
+		values := []string{}
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+
+	return nil
+}
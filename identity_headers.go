@@ -0,0 +1,349 @@
+package wx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"net/netip"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+type IdentityHeaderStyle string
+
+const (
+	IdentityHeadersOAuth2Proxy IdentityHeaderStyle = "oauth2-proxy"
+	IdentityHeadersALB         IdentityHeaderStyle = "alb"
+)
+
+var identityHeaderNames = map[IdentityHeaderStyle][]string{
+	IdentityHeadersOAuth2Proxy: {
+		"X-Auth-Request-User",
+		"X-Auth-Request-Email",
+		"X-Auth-Request-Groups",
+		"X-Auth-Request-Preferred-Username",
+		"X-Auth-Request-Access-Token",
+		"X-Forwarded-User",
+		"X-Forwarded-Email",
+		"X-Forwarded-Groups",
+		"X-Forwarded-Preferred-Username",
+		"X-Forwarded-Access-Token",
+	},
+	IdentityHeadersALB: {
+		"X-Amzn-Oidc-Identity",
+		"X-Amzn-Oidc-Accesstoken",
+		"X-Amzn-Oidc-Data",
+	},
+}
+
+func WithIdentityHeaders(styles ...IdentityHeaderStyle) authOpt {
+	return func(a *authServer) {
+		a.identityHeaders = append(a.identityHeaders, styles...)
+	}
+}
+
+var albRegion = regexp.MustCompile(`^[a-z0-9-]+$`)
+
+func NewALBVerifier(client *http.Client, region string, signer string, sources []netip.Prefix) (*albVerifier, error) {
+
+	if !albRegion.MatchString(region) {
+		return nil, fmt.Errorf("alb identity : invalid region %q", region)
+	}
+
+	if signer == "" {
+		return nil, errors.New("alb identity : signer arn is empty")
+	}
+
+	if len(sources) == 0 {
+		return nil, errors.New("alb identity : no trusted sources")
+	}
+
+	return &albVerifier{
+		Client:  client,
+		keysUrl: fmt.Sprintf("https://public-keys.auth.elb.%s.amazonaws.com/", region),
+		signer:  signer,
+		sources: sources,
+		keys:    map[string]*ecdsa.PublicKey{},
+	}, nil
+}
+
+func WithALBIdentity(verifier *albVerifier) authOpt {
+	return func(a *authServer) {
+		a.alb = verifier
+	}
+}
+
+func NewALBSigner(signer string, key *ecdsa.PrivateKey) (*albSigner, error) {
+
+	if signer == "" {
+		return nil, errors.New("alb signer : signer arn is empty")
+	}
+
+	if key == nil || key.Curve != elliptic.P256() {
+		return nil, errors.New("alb signer : key must use P-256")
+	}
+
+	return &albSigner{
+		signer: signer,
+		kid:    keyID(&key.PublicKey),
+		key:    key,
+	}, nil
+}
+
+func WithALBSigner(signer *albSigner) authOpt {
+	return func(a *authServer) {
+		a.albSigner = signer
+	}
+}
+
+type albSigner struct {
+	signer string
+	kid    string
+	key    *ecdsa.PrivateKey
+}
+
+func (s *albSigner) Sign(identity *Identity) (string, error) {
+
+	claims := map[string]interface{}{}
+	for k, v := range identity.Claims {
+		claims[k] = v
+	}
+
+	claims["sub"] = identity.Subject
+	if identity.Email != "" {
+		claims["email"] = identity.Email
+	}
+
+	header := map[string]interface{}{"alg": "ES256", "typ": "JWT", "kid": s.kid, "signer": s.signer}
+
+	if !identity.Expiry.IsZero() {
+		claims["exp"] = identity.Expiry.Unix()
+		header["exp"] = identity.Expiry.Unix()
+	}
+
+	return signES256(s.key, header, claims)
+}
+
+func (a *authServer) setIdentityHeaders(r *http.Request) {
+
+	for _, style := range a.identityHeaders {
+		for _, name := range identityHeaderNames[style] {
+			r.Header.Del(name)
+		}
+	}
+
+	if len(a.identityHeaders) == 0 {
+		return
+	}
+
+	identity, ok := UserFromContext(r.Context())
+	if !ok {
+		var err error
+		if identity, err = a.identity(r); err != nil {
+			return
+		}
+	}
+
+	token := strings.TrimPrefix(identity.Token, "Bearer ")
+	username, _ := identity.Claims["preferred_username"].(string)
+	groups := strings.Join(identity.Roles, ",")
+
+	for _, style := range a.identityHeaders {
+		switch style {
+		case IdentityHeadersOAuth2Proxy:
+			for _, prefix := range []string{"X-Auth-Request-", "X-Forwarded-"} {
+				r.Header.Set(prefix+"User", identity.Subject)
+				r.Header.Set(prefix+"Email", identity.Email)
+				r.Header.Set(prefix+"Groups", groups)
+				r.Header.Set(prefix+"Access-Token", token)
+				if username != "" {
+					r.Header.Set(prefix+"Preferred-Username", username)
+				}
+			}
+
+		case IdentityHeadersALB:
+			r.Header.Set("X-Amzn-Oidc-Identity", identity.Subject)
+			r.Header.Set("X-Amzn-Oidc-Accesstoken", token)
+
+			if a.albSigner == nil {
+				continue
+			}
+
+			data, err := a.albSigner.Sign(identity)
+			if err != nil {
+				a.Logger.Errorf("alb identity headers : %v", err)
+				continue
+			}
+
+			r.Header.Set("X-Amzn-Oidc-Data", data)
+		}
+	}
+}
+
+func (a *authServer) albIdentity(r *http.Request) (*Identity, bool) {
+
+	data := r.Header.Get("X-Amzn-Oidc-Data")
+	if a.alb == nil || data == "" {
+		return nil, false
+	}
+
+	if ip, ok := resolveClientIP(r, 0); !ok || !containsIP(a.alb.sources, ip) {
+		a.Logger.Infof("ignoring alb identity from untrusted source : %v", r.RemoteAddr)
+		return nil, false
+	}
+
+	claims, err := a.alb.Verify(r.Context(), data)
+	if err != nil {
+		a.Logger.Infof("alb identity : %v", err)
+		return nil, false
+	}
+
+	identity := NewIdentity("Bearer "+r.Header.Get("X-Amzn-Oidc-Accesstoken"), claims, a.roleClaim)
+	if identity.Expired() {
+		return nil, false
+	}
+
+	return identity, true
+}
+
+type albVerifier struct {
+	*http.Client
+	keysUrl string
+	signer  string
+	sources []netip.Prefix
+
+	mutex sync.Mutex
+	keys  map[string]*ecdsa.PublicKey
+}
+
+func (v *albVerifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, errors.New("malformed token")
+	}
+
+	var header struct {
+		Alg    string `json:"alg"`
+		Kid    string `json:"kid"`
+		Signer string `json:"signer"`
+	}
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("decode header : %w", err)
+	}
+
+	if header.Alg != "ES256" {
+		return nil, fmt.Errorf("unsupported alg [%v]", header.Alg)
+	}
+
+	if header.Signer != v.signer {
+		return nil, fmt.Errorf("unexpected signer [%v]", header.Signer)
+	}
+
+	key, err := v.key(ctx, header.Kid)
+	if err != nil {
+		return nil, err
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(parts[2], "="))
+	if err != nil || len(signature) != 64 {
+		return nil, errors.New("malformed signature")
+	}
+
+	digest := sha256.Sum256([]byte(parts[0] + "." + parts[1]))
+	rs, ss := new(big.Int).SetBytes(signature[:32]), new(big.Int).SetBytes(signature[32:])
+
+	if !ecdsa.Verify(key, digest[:], rs, ss) {
+		return nil, errors.New("invalid signature")
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("decode claims : %w", err)
+	}
+
+	return claims, nil
+}
+
+func (v *albVerifier) key(ctx context.Context, kid string) (*ecdsa.PublicKey, error) {
+
+	if kid == "" || strings.ContainsAny(kid, "/?#") {
+		return nil, fmt.Errorf("invalid kid [%v]", kid)
+	}
+
+	v.mutex.Lock()
+	key, ok := v.keys[kid]
+	v.mutex.Unlock()
+
+	if ok {
+		return key, nil
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, 5*time.Second)
+	defer cancel()
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.keysUrl+kid, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("fetch key [%v] : %w", kid, err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("fetch key [%v] : status %d", kid, resp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(resp.Body, 8<<10))
+	if err != nil {
+		return nil, fmt.Errorf("read key [%v] : %w", kid, err)
+	}
+
+	block, _ := pem.Decode(body)
+	if block == nil {
+		return nil, fmt.Errorf("decode key [%v] : no pem block", kid)
+	}
+
+	parsed, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parse key [%v] : %w", kid, err)
+	}
+
+	key, ok = parsed.(*ecdsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("parse key [%v] : not an ecdsa key", kid)
+	}
+
+	v.mutex.Lock()
+	v.keys[kid] = key
+	v.mutex.Unlock()
+
+	return key, nil
+}
+
+func decodeSegment(segment string, value interface{}) error {
+
+	data, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(segment, "="))
+	if err != nil {
+		return err
+	}
+
+	return json.Unmarshal(data, value)
+}
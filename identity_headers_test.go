@@ -0,0 +1,264 @@
+package wx
+
+import (
+	"context"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/pem"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"strings"
+	"testing"
+	"time"
+)
+
+const albSignerArn = "arn:aws:elasticloadbalancing:eu-west-1:123456789012:loadbalancer/app/wx/1234567890abcdef"
+
+func albToken(t *testing.T, key *ecdsa.PrivateKey, header map[string]string, claims map[string]interface{}) string {
+	t.Helper()
+
+	signed := encodeSegment(t, header) + "." + encodeSegment(t, claims)
+	digest := sha256.Sum256([]byte(signed))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature)
+}
+
+func TestNewALBVerifier(t *testing.T) {
+
+	sources := []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+
+	tests := []struct {
+		name    string
+		region  string
+		signer  string
+		sources []netip.Prefix
+		valid   bool
+	}{
+		{"valid", "eu-west-1", albSignerArn, sources, true},
+		{"empty signer", "eu-west-1", "", sources, false},
+		{"no sources", "eu-west-1", albSignerArn, nil, false},
+		{"empty region", "", albSignerArn, sources, false},
+		{"region with host", "evil.example.com/", albSignerArn, sources, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			_, err := NewALBVerifier(http.DefaultClient, test.region, test.signer, test.sources)
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestALBIdentity(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	other, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/") != "kid" {
+			http.NotFound(w, r)
+			return
+		}
+		pem.Encode(w, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}))
+	defer keys.Close()
+
+	verifier, err := NewALBVerifier(keys.Client(), "eu-west-1", albSignerArn, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.keysUrl = keys.URL + "/"
+
+	a := NewAuthServer(nopLogger{}, WithALBIdentity(verifier)).(*authServer)
+
+	header := map[string]string{"alg": "ES256", "kid": "kid", "signer": albSignerArn}
+	claims := map[string]interface{}{"sub": "alice", "exp": time.Now().Add(time.Hour).Unix()}
+
+	valid := albToken(t, key, header, claims)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		data       string
+		subject    string
+	}{
+		{"trusted source", "10.1.2.3:1234", valid, "alice"},
+		{"untrusted source", "203.0.113.7:1234", valid, ""},
+		{"other signer", "10.1.2.3:1234", albToken(t, key, map[string]string{"alg": "ES256", "kid": "kid", "signer": "arn:other"}, claims), ""},
+		{"missing signer", "10.1.2.3:1234", albToken(t, key, map[string]string{"alg": "ES256", "kid": "kid"}, claims), ""},
+		{"signed by other key", "10.1.2.3:1234", albToken(t, other, header, claims), ""},
+		{"unknown kid", "10.1.2.3:1234", albToken(t, key, map[string]string{"alg": "ES256", "kid": "missing", "signer": albSignerArn}, claims), ""},
+		{"path in kid", "10.1.2.3:1234", albToken(t, key, map[string]string{"alg": "ES256", "kid": "../kid", "signer": albSignerArn}, claims), ""},
+		{"alg none", "10.1.2.3:1234", encodeSegment(t, map[string]string{"alg": "none", "kid": "kid", "signer": albSignerArn}) + "." + encodeSegment(t, claims) + ".", ""},
+		{"expired", "10.1.2.3:1234", albToken(t, key, header, map[string]interface{}{"sub": "alice", "exp": time.Now().Add(-time.Hour).Unix()}), ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.RemoteAddr = test.remoteAddr
+			r.Header.Set("X-Amzn-Oidc-Data", test.data)
+
+			identity, ok := a.albIdentity(r)
+
+			if test.subject == "" {
+				if ok {
+					t.Fatalf("expected no identity, got %v", identity.Subject)
+				}
+				return
+			}
+
+			if !ok || identity.Subject != test.subject {
+				t.Fatalf("expected subject %v, got %v", test.subject, identity)
+			}
+		})
+	}
+}
+
+func TestALBIdentityHeaders(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	der, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	keys := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if strings.TrimPrefix(r.URL.Path, "/") != keyID(&key.PublicKey) {
+			http.NotFound(w, r)
+			return
+		}
+		pem.Encode(w, &pem.Block{Type: "PUBLIC KEY", Bytes: der})
+	}))
+	defer keys.Close()
+
+	verifier, err := NewALBVerifier(keys.Client(), "eu-west-1", albSignerArn, []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	verifier.keysUrl = keys.URL + "/"
+
+	signer, err := NewALBSigner(albSignerArn, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	identity := &Identity{
+		Subject: "alice",
+		Email:   "alice@example.com",
+		Claims:  map[string]interface{}{"sub": "alice", "name": "Alice"},
+		Expiry:  time.Now().Add(time.Hour),
+		Token:   "Bearer access-token",
+	}
+
+	tests := []struct {
+		name   string
+		opts   []authOpt
+		signed bool
+	}{
+		{"without signer", []authOpt{WithIdentityHeaders(IdentityHeadersALB)}, false},
+		{"with signer", []authOpt{WithIdentityHeaders(IdentityHeadersALB), WithALBSigner(signer)}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			a := NewAuthServer(nopLogger{}, test.opts...).(*authServer)
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			r.Header.Set("X-Amzn-Oidc-Data", "forged")
+			r = r.WithContext(ContextWithUser(r.Context(), identity))
+
+			a.setIdentityHeaders(r)
+
+			if token := r.Header.Get("X-Amzn-Oidc-Accesstoken"); token != "access-token" {
+				t.Fatalf("expected access token, got %q", token)
+			}
+
+			data := r.Header.Get("X-Amzn-Oidc-Data")
+			if !test.signed {
+				if data != "" {
+					t.Fatalf("expected no data header, got %q", data)
+				}
+				return
+			}
+
+			claims, err := verifier.Verify(context.Background(), data)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if claims["sub"] != "alice" || claims["email"] != "alice@example.com" || claims["name"] != "Alice" {
+				t.Fatalf("unexpected claims %v", claims)
+			}
+		})
+	}
+}
+
+func TestNewALBSigner(t *testing.T) {
+
+	p256, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	p384, err := ecdsa.GenerateKey(elliptic.P384(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		signer string
+		key    *ecdsa.PrivateKey
+		valid  bool
+	}{
+		{"valid", albSignerArn, p256, true},
+		{"empty signer", "", p256, false},
+		{"missing key", albSignerArn, nil, false},
+		{"wrong curve", albSignerArn, p384, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			_, err := NewALBSigner(test.signer, test.key)
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
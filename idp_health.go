@@ -0,0 +1,141 @@
+package wx
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+func NewIdPHealthCheck(logger Logger, client *http.Client, tokenURL string, jwksURL string, interval time.Duration, maxAge time.Duration) *idpHealth {
+	return &idpHealth{
+		Logger:   logger,
+		Client:   client,
+		tokenURL: tokenURL,
+		jwksURL:  jwksURL,
+		interval: interval,
+		maxAge:   maxAge,
+	}
+}
+
+type idpHealth struct {
+	Logger
+	*http.Client
+
+	tokenURL string
+	jwksURL  string
+	interval time.Duration
+	maxAge   time.Duration
+
+	mutex     sync.Mutex
+	checked   time.Time
+	lastError error
+	keysError error
+	keysFresh time.Time
+}
+
+func (h *idpHealth) Check(ctx context.Context) error {
+	h.mutex.Lock()
+	defer h.mutex.Unlock()
+
+	if h.checked.IsZero() || time.Since(h.checked) >= h.interval {
+		h.probe(ctx)
+		h.checked = time.Now()
+	}
+
+	if h.lastError != nil {
+		return h.lastError
+	}
+
+	if h.keysError == nil {
+		return nil
+	}
+
+	if h.keysFresh.IsZero() {
+		return h.keysError
+	}
+
+	if h.maxAge > 0 && time.Since(h.keysFresh) <= h.maxAge {
+		return nil
+	}
+
+	return fmt.Errorf("jwks stale : last refreshed %v : %w", h.keysFresh.Format(time.RFC3339), h.keysError)
+}
+
+func (h *idpHealth) probe(ctx context.Context) {
+
+	if h.lastError = h.probeTokenEndpoint(ctx); h.lastError != nil {
+		h.Logger.Errorf("idp health : %v", h.lastError)
+	}
+
+	if h.jwksURL == "" {
+		return
+	}
+
+	if h.keysError = h.probeJWKS(ctx); h.keysError != nil {
+		h.Logger.Errorf("idp health : %v", h.keysError)
+		return
+	}
+
+	h.keysFresh = time.Now()
+}
+
+func (h *idpHealth) probeTokenEndpoint(ctx context.Context) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, h.tokenURL, strings.NewReader(""))
+	if err != nil {
+		return fmt.Errorf("token endpoint : new request : %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("token endpoint : %w", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return fmt.Errorf("token endpoint : status %d", resp.StatusCode)
+	}
+
+	return nil
+}
+
+func (h *idpHealth) probeJWKS(ctx context.Context) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.jwksURL, nil)
+	if err != nil {
+		return fmt.Errorf("jwks : new request : %w", err)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("jwks : %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("jwks : status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []json.RawMessage `json:"keys"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return fmt.Errorf("jwks : decode : %w", err)
+	}
+
+	if len(jwks.Keys) == 0 {
+		return errors.New("jwks : no keys")
+	}
+
+	return nil
+}
@@ -0,0 +1,51 @@
+package wx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestIdPHealthKeysStaleness(t *testing.T) {
+
+	tests := []struct {
+		name      string
+		jwks      int
+		refreshed time.Duration
+		maxAge    time.Duration
+		healthy   bool
+	}{
+		{"keys served", http.StatusOK, 0, time.Hour, true},
+		{"never refreshed", http.StatusBadGateway, 0, time.Hour, false},
+		{"refreshed within max age", http.StatusBadGateway, time.Minute, time.Hour, true},
+		{"refreshed beyond max age", http.StatusBadGateway, 2 * time.Hour, time.Hour, false},
+		{"no max age", http.StatusBadGateway, time.Minute, 0, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			idp := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Path == "/jwks" {
+					w.WriteHeader(test.jwks)
+					w.Write([]byte(`{"keys": [{"kty": "RSA"}]}`))
+					return
+				}
+				w.WriteHeader(http.StatusBadRequest)
+			}))
+			defer idp.Close()
+
+			health := NewIdPHealthCheck(nopLogger{}, idp.Client(), idp.URL+"/token", idp.URL+"/jwks", time.Hour, test.maxAge)
+			if test.refreshed > 0 {
+				health.keysFresh = time.Now().Add(-test.refreshed)
+			}
+
+			err := health.Check(context.Background())
+			if healthy := err == nil; healthy != test.healthy {
+				t.Fatalf("expected healthy %v, got %v", test.healthy, err)
+			}
+		})
+	}
+}
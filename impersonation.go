@@ -0,0 +1,212 @@
+package wx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"slices"
+	"strings"
+	"time"
+)
+
+const (
+	AuditImpersonationStarted = "impersonation_started"
+	AuditImpersonationStopped = "impersonation_stopped"
+	AuditImpersonationDenied  = "impersonation_denied"
+)
+
+type impersonation struct {
+	Actor   string   `json:"actor"`
+	Subject string   `json:"sub"`
+	Email   string   `json:"email,omitempty"`
+	Roles   []string `json:"roles,omitempty"`
+	Expiry  int64    `json:"exp"`
+}
+
+func NewImpersonator(logger Logger, keyring *keyring, role string, ttl time.Duration, roles ...string) *impersonator {
+	return &impersonator{
+		Logger:     logger,
+		keyring:    keyring,
+		role:       role,
+		roles:      roles,
+		ttl:        ttl,
+		cookieName: "impersonate",
+	}
+}
+
+type impersonator struct {
+	Logger
+	keyring    *keyring
+	role       string
+	roles      []string
+	ttl        time.Duration
+	cookieName string
+}
+
+func (i *impersonator) Start(w http.ResponseWriter, r *http.Request) {
+
+	if r.Method != http.MethodPost {
+		w.Header().Set("Allow", http.MethodPost)
+		RenderError(w, r, NewStatusError(http.StatusMethodNotAllowed, nil))
+		return
+	}
+
+	if err := verifySameOrigin(r); err != nil {
+		RenderError(w, r, err)
+		return
+	}
+
+	actor, ok := UserFromContext(r.Context())
+	if !ok || !actor.HasRole(i.role) {
+		RenderError(w, r, fmt.Errorf("%w: missing role %v", ErrForbidden, i.role))
+		return
+	}
+
+	subject := r.FormValue("sub")
+	if subject == "" {
+		RenderError(w, r, fmt.Errorf("%w: missing sub", ErrBadRequest))
+		return
+	}
+
+	roles := []string{}
+	for _, role := range strings.Split(r.FormValue("roles"), ",") {
+		if role = strings.TrimSpace(role); role == "" {
+			continue
+		}
+
+		if !i.grantable(actor, role) {
+			Audit(r, AuditImpersonationDenied, map[string]string{"impersonated": subject, "role": role})
+			RenderError(w, r, fmt.Errorf("%w: role %v cannot be impersonated", ErrForbidden, role))
+			return
+		}
+
+		roles = append(roles, role)
+	}
+
+	expiry := time.Now().Add(i.ttl)
+
+	value, err := json.Marshal(impersonation{
+		Actor:   actor.Subject,
+		Subject: subject,
+		Email:   r.FormValue("email"),
+		Roles:   roles,
+		Expiry:  expiry.Unix(),
+	})
+	if err != nil {
+		RenderError(w, r, NewStatusError(http.StatusInternalServerError, err))
+		return
+	}
+
+	sealed, err := i.keyring.Seal(string(value))
+	if err != nil {
+		RenderError(w, r, NewStatusError(http.StatusInternalServerError, err))
+		i.Logger.Errorf("seal impersonation : %v", err)
+		return
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     i.cookieName,
+		Value:    sealed,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+		SameSite: http.SameSiteStrictMode,
+	})
+
+	Audit(r, AuditImpersonationStarted, map[string]string{"impersonated": subject, "roles": strings.Join(roles, ","), "expiry": expiry.UTC().Format(time.RFC3339)})
+	i.Logger.Infof("impersonation started : %v as %v", actor.Subject, subject)
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (i *impersonator) Stop(w http.ResponseWriter, r *http.Request) {
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   i.cookieName,
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	if identity, ok := UserFromContext(r.Context()); ok && identity.Actor != "" {
+		Audit(r, AuditImpersonationStopped, map[string]string{"actor": identity.Actor})
+	}
+
+	w.WriteHeader(http.StatusNoContent)
+}
+
+func (i *impersonator) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		r.Header.Del("X-Impersonated-By")
+
+		if identity, ok := i.impersonated(r); ok {
+			r = r.WithContext(ContextWithUser(r.Context(), identity))
+			r.Header.Set("X-Impersonated-By", identity.Actor)
+			w.Header().Set("X-Impersonated-By", identity.Actor)
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (i *impersonator) impersonated(r *http.Request) (*Identity, bool) {
+
+	cookie, err := r.Cookie(i.cookieName)
+	if err != nil {
+		return nil, false
+	}
+
+	actor, ok := UserFromContext(r.Context())
+	if !ok || !actor.HasRole(i.role) {
+		return nil, false
+	}
+
+	value, err := i.keyring.Open(cookie.Value)
+	if err != nil {
+		i.Logger.Infof("open impersonation : %v", err)
+		return nil, false
+	}
+
+	var session impersonation
+	if err := json.Unmarshal([]byte(value), &session); err != nil {
+		return nil, false
+	}
+
+	if session.Actor != actor.Subject || time.Now().Unix() > session.Expiry {
+		return nil, false
+	}
+
+	for _, role := range session.Roles {
+		if !i.grantable(actor, role) {
+			i.Logger.Infof("impersonation : %v may no longer grant role %v", actor.Subject, role)
+			return nil, false
+		}
+	}
+
+	return &Identity{
+		Subject: session.Subject,
+		Email:   session.Email,
+		Roles:   session.Roles,
+		Claims: map[string]interface{}{
+			"sub":   session.Subject,
+			"email": session.Email,
+			"act":   map[string]interface{}{"sub": session.Actor},
+		},
+		Expiry: time.Unix(session.Expiry, 0),
+		Token:  actor.Token,
+		Actor:  session.Actor,
+	}, true
+}
+
+func (i *impersonator) grantable(actor *Identity, role string) bool {
+
+	if role == i.role {
+		return false
+	}
+
+	if len(i.roles) > 0 {
+		return slices.Contains(i.roles, role)
+	}
+
+	return actor.HasRole(role)
+}
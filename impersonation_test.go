@@ -0,0 +1,125 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestImpersonationRoles(t *testing.T) {
+
+	keyring := NewKeyring([]byte("impersonation-key"))
+
+	admin := &Identity{Subject: "alice", Roles: []string{"impersonator", "support", "billing"}, Expiry: time.Now().Add(time.Hour)}
+	user := &Identity{Subject: "bob", Roles: []string{"support"}, Expiry: time.Now().Add(time.Hour)}
+
+	tests := []struct {
+		name      string
+		allowlist []string
+		actor     *Identity
+		roles     string
+		status    int
+		granted   []string
+	}{
+		{"actor roles", nil, admin, "support,billing", http.StatusNoContent, []string{"support", "billing"}},
+		{"role not held by actor", nil, admin, "owner", http.StatusForbidden, nil},
+		{"impersonator role", nil, admin, "impersonator", http.StatusForbidden, nil},
+		{"allowlisted role", []string{"viewer"}, admin, "viewer", http.StatusNoContent, []string{"viewer"}},
+		{"actor role outside allowlist", []string{"viewer"}, admin, "support", http.StatusForbidden, nil},
+		{"allowlisted impersonator role", []string{"impersonator"}, admin, "impersonator", http.StatusForbidden, nil},
+		{"no roles", nil, admin, "", http.StatusNoContent, nil},
+		{"actor without impersonator role", nil, user, "support", http.StatusForbidden, nil},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			i := NewImpersonator(nopLogger{}, keyring, "impersonator", time.Hour, test.allowlist...)
+
+			form := url.Values{"sub": {"carol"}, "roles": {test.roles}}
+			r := httptest.NewRequest(http.MethodPost, "/auth/impersonate", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("Accept", "application/json")
+			r.Header.Set("Sec-Fetch-Site", "same-origin")
+			r = r.WithContext(ContextWithUser(r.Context(), test.actor))
+
+			w := httptest.NewRecorder()
+			i.Start(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if w.Code != http.StatusNoContent {
+				return
+			}
+
+			next := httptest.NewRequest(http.MethodGet, "/", nil)
+			for _, cookie := range w.Result().Cookies() {
+				next.AddCookie(cookie)
+			}
+			next = next.WithContext(ContextWithUser(next.Context(), test.actor))
+
+			identity, ok := i.impersonated(next)
+			if !ok {
+				t.Fatal("expected impersonated identity")
+			}
+
+			if identity.Subject != "carol" || identity.Actor != test.actor.Subject {
+				t.Fatalf("unexpected identity %v acting as %v", identity.Actor, identity.Subject)
+			}
+
+			if strings.Join(identity.Roles, ",") != strings.Join(test.granted, ",") {
+				t.Fatalf("expected roles %v, got %v", test.granted, identity.Roles)
+			}
+		})
+	}
+}
+
+func TestImpersonationRequiresSameOrigin(t *testing.T) {
+
+	admin := &Identity{Subject: "alice", Roles: []string{"impersonator", "support"}, Expiry: time.Now().Add(time.Hour)}
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		status  int
+	}{
+		{"same origin fetch", map[string]string{"Sec-Fetch-Site": "same-origin"}, http.StatusNoContent},
+		{"matching origin", map[string]string{"Origin": "https://wx.example.com"}, http.StatusNoContent},
+		{"cross site fetch", map[string]string{"Sec-Fetch-Site": "cross-site"}, http.StatusForbidden},
+		{"same site fetch", map[string]string{"Sec-Fetch-Site": "same-site"}, http.StatusForbidden},
+		{"other origin", map[string]string{"Origin": "https://evil.example.com"}, http.StatusForbidden},
+		{"missing origin", map[string]string{}, http.StatusForbidden},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			i := NewImpersonator(nopLogger{}, NewKeyring([]byte("impersonation-key")), "impersonator", time.Hour)
+
+			form := url.Values{"sub": {"carol"}, "roles": {"support"}}
+			r := httptest.NewRequest(http.MethodPost, "https://wx.example.com/auth/impersonate", strings.NewReader(form.Encode()))
+			r.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+			r.Header.Set("Accept", "application/json")
+			for key, value := range test.headers {
+				r.Header.Set(key, value)
+			}
+			r = r.WithContext(ContextWithUser(r.Context(), admin))
+
+			w := httptest.NewRecorder()
+			i.Start(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			if started := len(w.Result().Cookies()) > 0; started != (test.status == http.StatusNoContent) {
+				t.Fatalf("expected impersonation cookie %v, got %v", test.status == http.StatusNoContent, w.Result().Cookies())
+			}
+		})
+	}
+}
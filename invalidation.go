@@ -0,0 +1,113 @@
+package wx
+
+import (
+	"context"
+	"errors"
+	"time"
+)
+
+const (
+	InvalidateURL = "url"
+	InvalidateKey = "key"
+	InvalidateAll = "all"
+)
+
+var ErrSubscriptionLost = errors.New("subscription lost")
+
+type InvalidationEvent struct {
+	Type   string `json:"type"`
+	Value  string `json:"value,omitempty"`
+	Origin string `json:"origin"`
+}
+
+type InvalidationBus interface {
+	Publish(ctx context.Context, event InvalidationEvent) error
+	Subscribe(ctx context.Context, handler func(InvalidationEvent)) error
+}
+
+func NewBroadcastPurger(logger Logger, purger Purger, bus InvalidationBus) *broadcastPurger {
+	return &broadcastPurger{
+		Logger: logger,
+		Purger: purger,
+		bus:    bus,
+		origin: newRequestID(),
+	}
+}
+
+type broadcastPurger struct {
+	Logger
+	Purger
+
+	bus    InvalidationBus
+	origin string
+}
+
+func (b *broadcastPurger) Purge(url string) {
+	b.Purger.Purge(url)
+	b.publish(InvalidationEvent{Type: InvalidateURL, Value: url})
+}
+
+func (b *broadcastPurger) PurgeSurrogateKey(surrogateKey string) {
+	b.Purger.PurgeSurrogateKey(surrogateKey)
+	b.publish(InvalidationEvent{Type: InvalidateKey, Value: surrogateKey})
+}
+
+func (b *broadcastPurger) PurgeAll() {
+	b.Purger.PurgeAll()
+	b.publish(InvalidationEvent{Type: InvalidateAll})
+}
+
+func (b *broadcastPurger) publish(event InvalidationEvent) {
+	event.Origin = b.origin
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	if err := b.bus.Publish(ctx, event); err != nil {
+		b.Logger.Errorf("publish invalidation : %v", err)
+	}
+}
+
+func (b *broadcastPurger) Listen(ctx context.Context) error {
+	backoff := time.Second
+
+	for {
+		err := b.bus.Subscribe(ctx, b.apply)
+		if ctx.Err() != nil {
+			return nil
+		}
+
+		b.Logger.Errorf("subscribe invalidations : %v", err)
+
+		if errors.Is(err, ErrSubscriptionLost) {
+			backoff = time.Second
+		}
+
+		select {
+		case <-time.After(backoff):
+			backoff = min(backoff*2, time.Minute)
+		case <-ctx.Done():
+			return nil
+		}
+	}
+}
+
+func (b *broadcastPurger) apply(event InvalidationEvent) {
+	if event.Origin == b.origin {
+		return
+	}
+
+	switch event.Type {
+	case InvalidateURL:
+		b.Purger.Purge(event.Value)
+	case InvalidateKey:
+		b.Purger.PurgeSurrogateKey(event.Value)
+	case InvalidateAll:
+		b.Purger.PurgeAll()
+	default:
+		b.Logger.Errorf("unknown invalidation : %v", event.Type)
+		return
+	}
+
+	b.Logger.Infof("applied invalidation from %v : %v %v", event.Origin, event.Type, event.Value)
+}
@@ -0,0 +1,274 @@
+package wx
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+)
+
+const redisPingInterval = 30 * time.Second
+
+type redisConfig struct {
+	username  string
+	password  string
+	tlsConfig *tls.Config
+}
+
+type redisOpt func(*redisConfig)
+
+func WithRedisAuth(username string, password string) redisOpt {
+	return func(c *redisConfig) {
+		c.username = username
+		c.password = password
+	}
+}
+
+func WithRedisTLS(config *tls.Config) redisOpt {
+	return func(c *redisConfig) {
+		c.tlsConfig = config
+	}
+}
+
+func newRedisConfig(opts ...redisOpt) redisConfig {
+	config := redisConfig{}
+	for _, opt := range opts {
+		opt(&config)
+	}
+	return config
+}
+
+func NewRedisInvalidationBus(addr string, channel string, opts ...redisOpt) *redisInvalidationBus {
+	return &redisInvalidationBus{
+		addr:    addr,
+		channel: channel,
+		config:  newRedisConfig(opts...),
+	}
+}
+
+type redisInvalidationBus struct {
+	addr    string
+	channel string
+	config  redisConfig
+}
+
+func (b *redisInvalidationBus) Publish(ctx context.Context, event InvalidationEvent) error {
+
+	payload, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event : %w", err)
+	}
+
+	conn, err := dialRedis(ctx, b.addr, b.config)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	if err := writeRESP(conn, "PUBLISH", b.channel, string(payload)); err != nil {
+		return fmt.Errorf("publish : %w", err)
+	}
+
+	if _, err := readRESP(reader); err != nil {
+		return fmt.Errorf("publish : %w", err)
+	}
+
+	return nil
+}
+
+func (b *redisInvalidationBus) Subscribe(ctx context.Context, handler func(InvalidationEvent)) error {
+
+	conn, err := dialRedis(ctx, b.addr, b.config)
+	if err != nil {
+		return err
+	}
+
+	defer conn.Close()
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	stop := context.AfterFunc(ctx, func() {
+		conn.Close()
+	})
+
+	defer stop()
+
+	if err := writeRESP(conn, "SUBSCRIBE", b.channel); err != nil {
+		return fmt.Errorf("subscribe : %w", err)
+	}
+
+	reader := bufio.NewReader(conn)
+
+	conn.SetReadDeadline(redisDeadline(ctx, 2*redisPingInterval))
+	if _, err := readRESP(reader); err != nil {
+		return fmt.Errorf("subscribe : %w", err)
+	}
+
+	go b.keepalive(ctx, conn)
+
+	for {
+		conn.SetReadDeadline(redisDeadline(ctx, 2*redisPingInterval))
+
+		reply, err := readRESP(reader)
+		if err != nil {
+			return fmt.Errorf("receive : %w : %w", ErrSubscriptionLost, err)
+		}
+
+		message, ok := reply.([]interface{})
+		if !ok || len(message) != 3 || message[0] != "message" {
+			continue
+		}
+
+		payload, _ := message[2].(string)
+
+		var event InvalidationEvent
+		if err := json.Unmarshal([]byte(payload), &event); err != nil {
+			continue
+		}
+
+		handler(event)
+	}
+}
+
+func (b *redisInvalidationBus) keepalive(ctx context.Context, conn net.Conn) {
+	ticker := time.NewTicker(redisPingInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ticker.C:
+			conn.SetWriteDeadline(redisDeadline(ctx, redisPingInterval))
+			if err := writeRESP(conn, "PING"); err != nil {
+				return
+			}
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+func dialRedis(ctx context.Context, addr string, config redisConfig) (net.Conn, error) {
+	var dialer net.Dialer
+
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("dial [%v] : %w", addr, err)
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	}
+
+	if config.tlsConfig != nil {
+		tlsConfig := config.tlsConfig.Clone()
+		if tlsConfig.ServerName == "" {
+			tlsConfig.ServerName, _, _ = net.SplitHostPort(addr)
+		}
+
+		tlsConn := tls.Client(conn, tlsConfig)
+		if err := tlsConn.HandshakeContext(ctx); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("tls handshake [%v] : %w", addr, err)
+		}
+
+		conn = tlsConn
+	}
+
+	if config.password != "" {
+		args := []string{"AUTH", config.password}
+		if config.username != "" {
+			args = []string{"AUTH", config.username, config.password}
+		}
+
+		if err := writeRESP(conn, args...); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("auth [%v] : %w", addr, err)
+		}
+
+		if _, err := readRESP(bufio.NewReader(conn)); err != nil {
+			conn.Close()
+			return nil, fmt.Errorf("auth [%v] : %w", addr, err)
+		}
+	}
+
+	return conn, nil
+}
+
+func redisDeadline(ctx context.Context, timeout time.Duration) time.Time {
+	if deadline, ok := ctx.Deadline(); ok && time.Until(deadline) < timeout {
+		return deadline
+	}
+
+	return time.Now().Add(timeout)
+}
+
+func writeRESP(w io.Writer, args ...string) error {
+	var command strings.Builder
+
+	fmt.Fprintf(&command, "*%d\r\n", len(args))
+	for _, arg := range args {
+		fmt.Fprintf(&command, "$%d\r\n%s\r\n", len(arg), arg)
+	}
+
+	_, err := io.WriteString(w, command.String())
+	return err
+}
+
+func readRESP(r *bufio.Reader) (interface{}, error) {
+
+	line, err := r.ReadString('\n')
+	if err != nil {
+		return nil, err
+	}
+
+	line = strings.TrimSuffix(line, "\r\n")
+	if line == "" {
+		return nil, errors.New("empty reply")
+	}
+
+	switch line[0] {
+	case '+':
+		return line[1:], nil
+	case '-':
+		return nil, errors.New(line[1:])
+	case ':':
+		return strconv.ParseInt(line[1:], 10, 64)
+	case '$':
+		size, err := strconv.Atoi(line[1:])
+		if err != nil || size < 0 {
+			return nil, err
+		}
+
+		data := make([]byte, size+2)
+		if _, err := io.ReadFull(r, data); err != nil {
+			return nil, err
+		}
+		return string(data[:size]), nil
+	case '*':
+		count, err := strconv.Atoi(line[1:])
+		if err != nil || count < 0 {
+			return nil, err
+		}
+
+		values := make([]interface{}, count)
+		for i := range values {
+			if values[i], err = readRESP(r); err != nil {
+				return nil, err
+			}
+		}
+		return values, nil
+	}
+
+	return nil, fmt.Errorf("unexpected reply : %q", line)
+}
@@ -0,0 +1,275 @@
+package wx
+
+import (
+	"bufio"
+	"context"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestReadRESP(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		reply string
+		value interface{}
+		fails bool
+	}{
+		{"simple string", "+OK\r\n", "OK", false},
+		{"error", "-ERR unknown command\r\n", nil, true},
+		{"integer", ":42\r\n", int64(42), false},
+		{"invalid integer", ":forty\r\n", nil, true},
+		{"bulk string", "$5\r\nhello\r\n", "hello", false},
+		{"empty bulk string", "$0\r\n\r\n", "", false},
+		{"bulk string with crlf", "$7\r\nhel\r\nlo\r\n", "hel\r\nlo", false},
+		{"null bulk string", "$-1\r\n", nil, false},
+		{"truncated bulk string", "$5\r\nhel", nil, true},
+		{"array", "*3\r\n$7\r\nmessage\r\n$2\r\nwx\r\n:1\r\n", []interface{}{"message", "wx", int64(1)}, false},
+		{"nested array", "*2\r\n*1\r\n+a\r\n+b\r\n", []interface{}{[]interface{}{"a"}, "b"}, false},
+		{"null array", "*-1\r\n", nil, false},
+		{"truncated array", "*2\r\n+a\r\n", nil, true},
+		{"empty line", "\r\n", nil, true},
+		{"unknown type", "?what\r\n", nil, true},
+		{"eof", "", nil, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			value, err := readRESP(bufio.NewReader(strings.NewReader(test.reply)))
+
+			if (err != nil) != test.fails {
+				t.Fatalf("expected error %v, got %v", test.fails, err)
+			}
+
+			if !test.fails && !reflect.DeepEqual(value, test.value) {
+				t.Fatalf("expected %#v, got %#v", test.value, value)
+			}
+		})
+	}
+}
+
+func redisScript(t *testing.T, listener net.Listener, script func(conn net.Conn, reader *bufio.Reader)) string {
+	t.Helper()
+
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+
+			go func() {
+				defer conn.Close()
+				script(conn, bufio.NewReader(conn))
+			}()
+		}
+	}()
+
+	return listener.Addr().String()
+}
+
+func redisMessage(payload string) string {
+	return fmt.Sprintf("*3\r\n$7\r\nmessage\r\n$2\r\nwx\r\n$%d\r\n%s\r\n", len(payload), payload)
+}
+
+func readCommand(reader *bufio.Reader) string {
+	request, err := readRESP(reader)
+	if err != nil {
+		return ""
+	}
+
+	args := []string{}
+	for _, arg := range request.([]interface{}) {
+		args = append(args, arg.(string))
+	}
+
+	return strings.Join(args, " ")
+}
+
+func TestRedisSubscribe(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		opts     []redisOpt
+		replies  []string
+		commands []string
+		events   []InvalidationEvent
+		lost     bool
+	}{
+		{
+			name:     "delivers events",
+			replies:  []string{"*3\r\n$9\r\nsubscribe\r\n$2\r\nwx\r\n:1\r\n", redisMessage(`{"type":"all","origin":"a"}`)},
+			commands: []string{"SUBSCRIBE wx"},
+			events:   []InvalidationEvent{{Type: InvalidateAll, Origin: "a"}},
+			lost:     true,
+		},
+		{
+			name: "skips malformed payloads and other replies",
+			replies: []string{
+				"*3\r\n$9\r\nsubscribe\r\n$2\r\nwx\r\n:1\r\n",
+				redisMessage(`{x}`),
+				"+PONG\r\n",
+				redisMessage(`{"type":"url","value":"/a","origin":"b"}`),
+			},
+			commands: []string{"SUBSCRIBE wx"},
+			events:   []InvalidationEvent{{Type: InvalidateURL, Value: "/a", Origin: "b"}},
+			lost:     true,
+		},
+		{
+			name:     "authenticates first",
+			opts:     []redisOpt{WithRedisAuth("", "secret")},
+			replies:  []string{"+OK\r\n", "*3\r\n$9\r\nsubscribe\r\n$2\r\nwx\r\n:1\r\n"},
+			commands: []string{"AUTH secret", "SUBSCRIBE wx"},
+			lost:     true,
+		},
+		{
+			name:     "authenticates with acl user",
+			opts:     []redisOpt{WithRedisAuth("wx", "secret")},
+			replies:  []string{"+OK\r\n", "*3\r\n$9\r\nsubscribe\r\n$2\r\nwx\r\n:1\r\n"},
+			commands: []string{"AUTH wx secret", "SUBSCRIBE wx"},
+			lost:     true,
+		},
+		{
+			name:     "rejected credentials",
+			opts:     []redisOpt{WithRedisAuth("", "wrong")},
+			replies:  []string{"-WRONGPASS invalid username-password pair\r\n"},
+			commands: []string{"AUTH wrong"},
+		},
+		{
+			name:     "subscribe refused",
+			replies:  []string{"-NOPERM no permissions\r\n"},
+			commands: []string{"SUBSCRIBE wx"},
+		},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			listener, err := net.Listen("tcp", "127.0.0.1:0")
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			commands := make(chan string, len(test.commands))
+
+			addr := redisScript(t, listener, func(conn net.Conn, reader *bufio.Reader) {
+				for i, reply := range test.replies {
+					if i < len(test.commands) {
+						commands <- readCommand(reader)
+					}
+					io.WriteString(conn, reply)
+				}
+			})
+
+			bus := NewRedisInvalidationBus(addr, "wx", test.opts...)
+
+			events := []InvalidationEvent{}
+			err = bus.Subscribe(context.Background(), func(event InvalidationEvent) {
+				events = append(events, event)
+			})
+
+			if lost := errors.Is(err, ErrSubscriptionLost); lost != test.lost {
+				t.Fatalf("expected subscription lost %v, got %v", test.lost, err)
+			}
+
+			for _, expected := range test.commands {
+				if command := <-commands; command != expected {
+					t.Fatalf("expected command %q, got %q", expected, command)
+				}
+			}
+
+			if len(events) != len(test.events) || (len(events) > 0 && !reflect.DeepEqual(events, test.events)) {
+				t.Fatalf("expected events %v, got %v", test.events, events)
+			}
+		})
+	}
+}
+
+func TestRedisSubscribeStopsOnCancel(t *testing.T) {
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := redisScript(t, listener, func(conn net.Conn, reader *bufio.Reader) {
+		readCommand(reader)
+		io.WriteString(conn, "*3\r\n$9\r\nsubscribe\r\n$2\r\nwx\r\n:1\r\n")
+		io.Copy(io.Discard, reader)
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	time.AfterFunc(50*time.Millisecond, cancel)
+
+	done := make(chan error, 1)
+	go func() {
+		done <- NewRedisInvalidationBus(addr, "wx").Subscribe(ctx, func(InvalidationEvent) {})
+	}()
+
+	select {
+	case <-done:
+	case <-time.After(5 * time.Second):
+		t.Fatal("expected subscribe to return after cancel")
+	}
+}
+
+func TestRedisTLS(t *testing.T) {
+
+	server := httptest.NewUnstartedServer(nil)
+	server.StartTLS()
+	certificate := server.TLS.Certificates[0]
+	server.Close()
+
+	listener, err := tls.Listen("tcp", "127.0.0.1:0", &tls.Config{Certificates: []tls.Certificate{certificate}})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	addr := redisScript(t, listener, func(conn net.Conn, reader *bufio.Reader) {
+		if readCommand(reader) == "PUBLISH wx {\"type\":\"all\",\"origin\":\"\"}" {
+			io.WriteString(conn, ":1\r\n")
+		}
+	})
+
+	leaf, err := x509.ParseCertificate(certificate.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(leaf)
+
+	tests := []struct {
+		name   string
+		config *tls.Config
+		fails  bool
+	}{
+		{"trusted certificate", &tls.Config{RootCAs: roots, ServerName: "example.com"}, false},
+		{"untrusted certificate", &tls.Config{ServerName: "example.com"}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+			defer cancel()
+
+			err := NewRedisInvalidationBus(addr, "wx", WithRedisTLS(test.config)).Publish(ctx, InvalidationEvent{Type: InvalidateAll})
+			if (err != nil) != test.fails {
+				t.Fatalf("expected error %v, got %v", test.fails, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,54 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"net/netip"
+)
+
+type IPRule struct {
+	Path  string
+	Allow []netip.Prefix
+	Deny  []netip.Prefix
+}
+
+func (rule IPRule) Permits(ip netip.Addr) bool {
+	if containsIP(rule.Deny, ip) {
+		return false
+	}
+
+	return len(rule.Allow) == 0 || containsIP(rule.Allow, ip)
+}
+
+func NewIPFilter(logger Logger, rules ...IPRule) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			ip, ok := ClientIP(r)
+
+			for _, rule := range rules {
+				if rule.Path != "" && !matchPath(rule.Path, r.URL.Path) {
+					continue
+				}
+
+				if !ok || !rule.Permits(ip) {
+					Audit(r, AuditAuthorizationDenied, map[string]string{"path": r.URL.Path, "reason": "ip"})
+					RenderError(w, r, fmt.Errorf("%w: address %v not permitted", ErrForbidden, ip))
+					logger.Infof("ip denied : %v : %v", ip, r.URL.Path)
+					return
+				}
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
+
+func containsIP(prefixes []netip.Prefix, ip netip.Addr) bool {
+	for _, prefix := range prefixes {
+		if prefix.Contains(ip) {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,320 @@
+package wx
+
+import (
+	"context"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"math/big"
+	"net/http"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+)
+
+const jwksMinRefresh = time.Minute
+
+var jwsCurves = map[string]elliptic.Curve{
+	"ES256": elliptic.P256(),
+	"ES384": elliptic.P384(),
+	"ES512": elliptic.P521(),
+}
+
+var errUnverifiable = fmt.Errorf("%w: token signature cannot be verified", ErrUnauthorized)
+
+func WithJWKS(url string, issuer string, audience string, refresh time.Duration) authOpt {
+	return func(a *authServer) {
+		a.jwks = NewJWKSVerifier(http.DefaultClient, url, issuer, audience, refresh)
+	}
+}
+
+func NewJWKSVerifier(client *http.Client, url string, issuer string, audience string, refresh time.Duration) *jwksVerifier {
+
+	if refresh <= 0 {
+		refresh = time.Hour
+	}
+
+	return &jwksVerifier{
+		Client:   client,
+		url:      url,
+		issuer:   issuer,
+		audience: audience,
+		refresh:  refresh,
+		keys:     map[string]crypto.PublicKey{},
+	}
+}
+
+type jwksVerifier struct {
+	*http.Client
+
+	url      string
+	issuer   string
+	audience string
+	refresh  time.Duration
+
+	mutex     sync.Mutex
+	keys      map[string]crypto.PublicKey
+	fetched   time.Time
+	attempted time.Time
+}
+
+type jwk struct {
+	Kty string `json:"kty"`
+	Kid string `json:"kid"`
+	Use string `json:"use"`
+	N   string `json:"n"`
+	E   string `json:"e"`
+	Crv string `json:"crv"`
+	X   string `json:"x"`
+	Y   string `json:"y"`
+}
+
+func (v *jwksVerifier) Verify(ctx context.Context, token string) (map[string]interface{}, error) {
+
+	token = strings.TrimSpace(token[strings.LastIndex(token, " ")+1:])
+
+	parts := strings.Split(token, ".")
+	if len(parts) != 3 {
+		return nil, fmt.Errorf("%w: malformed token", ErrUnauthorized)
+	}
+
+	var header struct {
+		Alg string `json:"alg"`
+		Kid string `json:"kid"`
+	}
+
+	if err := decodeSegment(parts[0], &header); err != nil {
+		return nil, fmt.Errorf("%w: decode header : %w", ErrUnauthorized, err)
+	}
+
+	signature, err := base64.RawURLEncoding.DecodeString(parts[2])
+	if err != nil {
+		return nil, fmt.Errorf("%w: decode signature : %w", ErrUnauthorized, err)
+	}
+
+	keys, err := v.candidates(ctx, header.Kid)
+	if err != nil {
+		return nil, fmt.Errorf("%w: jwks : %w", ErrUnauthorized, err)
+	}
+
+	signed := []byte(parts[0] + "." + parts[1])
+
+	verified := false
+	for _, key := range keys {
+		if err := verifySignature(header.Alg, key, signed, signature); err == nil {
+			verified = true
+			break
+		}
+	}
+
+	if !verified {
+		return nil, fmt.Errorf("%w: invalid token signature", ErrUnauthorized)
+	}
+
+	var claims map[string]interface{}
+	if err := decodeSegment(parts[1], &claims); err != nil {
+		return nil, fmt.Errorf("%w: decode claims : %w", ErrUnauthorized, err)
+	}
+
+	if issuer, _ := claims["iss"].(string); v.issuer == "" || issuer != v.issuer {
+		return nil, fmt.Errorf("%w: unexpected issuer %q", ErrUnauthorized, issuer)
+	}
+
+	if audiences := claimStrings(claims["aud"]); v.audience == "" || !slices.Contains(audiences, v.audience) {
+		return nil, fmt.Errorf("%w: %q not in audience", ErrUnauthorized, v.audience)
+	}
+
+	return claims, nil
+}
+
+func (v *jwksVerifier) candidates(ctx context.Context, kid string) ([]crypto.PublicKey, error) {
+	v.mutex.Lock()
+	defer v.mutex.Unlock()
+
+	_, known := v.keys[kid]
+	stale := time.Since(v.fetched) > v.refresh
+	unknown := kid != "" && !known && time.Since(v.attempted) > jwksMinRefresh
+
+	if stale || unknown {
+		v.attempted = time.Now()
+
+		keys, err := v.fetch(ctx)
+		if err != nil && len(v.keys) == 0 {
+			return nil, err
+		}
+
+		if err == nil {
+			v.keys = keys
+			v.fetched = time.Now()
+		}
+	}
+
+	if kid != "" {
+		if key, ok := v.keys[kid]; ok {
+			return []crypto.PublicKey{key}, nil
+		}
+		return nil, fmt.Errorf("unknown key id %q", kid)
+	}
+
+	keys := []crypto.PublicKey{}
+	for _, key := range v.keys {
+		keys = append(keys, key)
+	}
+
+	return keys, nil
+}
+
+func (v *jwksVerifier) fetch(ctx context.Context) (map[string]crypto.PublicKey, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, v.url, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request : %w", err)
+	}
+
+	resp, err := v.Client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("status %d", resp.StatusCode)
+	}
+
+	var jwks struct {
+		Keys []jwk `json:"keys"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&jwks); err != nil {
+		return nil, fmt.Errorf("decode : %w", err)
+	}
+
+	keys := map[string]crypto.PublicKey{}
+
+	for i, key := range jwks.Keys {
+		if key.Use != "" && key.Use != "sig" {
+			continue
+		}
+
+		public, err := key.publicKey()
+		if err != nil {
+			continue
+		}
+
+		kid := key.Kid
+		if kid == "" {
+			kid = fmt.Sprintf("#%d", i)
+		}
+
+		keys[kid] = public
+	}
+
+	if len(keys) == 0 {
+		return nil, errors.New("no usable keys")
+	}
+
+	return keys, nil
+}
+
+func (k jwk) publicKey() (crypto.PublicKey, error) {
+	switch k.Kty {
+	case "RSA":
+		n, err := base64.RawURLEncoding.DecodeString(k.N)
+		if err != nil {
+			return nil, err
+		}
+
+		e, err := base64.RawURLEncoding.DecodeString(k.E)
+		if err != nil {
+			return nil, err
+		}
+
+		return &rsa.PublicKey{N: new(big.Int).SetBytes(n), E: int(new(big.Int).SetBytes(e).Int64())}, nil
+
+	case "EC":
+		var curve elliptic.Curve
+		switch k.Crv {
+		case "P-256":
+			curve = elliptic.P256()
+		case "P-384":
+			curve = elliptic.P384()
+		case "P-521":
+			curve = elliptic.P521()
+		default:
+			return nil, fmt.Errorf("unsupported curve %q", k.Crv)
+		}
+
+		x, err := base64.RawURLEncoding.DecodeString(k.X)
+		if err != nil {
+			return nil, err
+		}
+
+		y, err := base64.RawURLEncoding.DecodeString(k.Y)
+		if err != nil {
+			return nil, err
+		}
+
+		key := &ecdsa.PublicKey{Curve: curve, X: new(big.Int).SetBytes(x), Y: new(big.Int).SetBytes(y)}
+		if !curve.IsOnCurve(key.X, key.Y) {
+			return nil, errors.New("point not on curve")
+		}
+
+		return key, nil
+	}
+
+	return nil, fmt.Errorf("unsupported key type %q", k.Kty)
+}
+
+func verifySignature(alg string, key crypto.PublicKey, signed []byte, signature []byte) error {
+
+	if len(alg) != 5 {
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+
+	var hash crypto.Hash
+	switch alg[2:] {
+	case "256":
+		hash = crypto.SHA256
+	case "384":
+		hash = crypto.SHA384
+	case "512":
+		hash = crypto.SHA512
+	default:
+		return fmt.Errorf("unsupported alg %q", alg)
+	}
+
+	h := hash.New()
+	h.Write(signed)
+	digest := h.Sum(nil)
+
+	switch public := key.(type) {
+	case *rsa.PublicKey:
+		switch alg[:2] {
+		case "RS":
+			return rsa.VerifyPKCS1v15(public, hash, digest, signature)
+		case "PS":
+			return rsa.VerifyPSS(public, hash, digest, signature, &rsa.PSSOptions{SaltLength: rsa.PSSSaltLengthEqualsHash})
+		}
+
+	case *ecdsa.PublicKey:
+		size := (public.Curve.Params().BitSize + 7) / 8
+		if alg[:2] != "ES" || public.Curve != jwsCurves[alg] || len(signature) != 2*size {
+			break
+		}
+
+		r, s := new(big.Int).SetBytes(signature[:size]), new(big.Int).SetBytes(signature[size:])
+		if ecdsa.Verify(public, digest, r, s) {
+			return nil
+		}
+		return errors.New("ecdsa verification failed")
+	}
+
+	return fmt.Errorf("alg %q does not match key", alg)
+}
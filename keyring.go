@@ -0,0 +1,155 @@
+package wx
+
+import (
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"hash"
+	"sync"
+)
+
+const keyringSize = 2
+
+func NewKeyring(keys ...[]byte) *keyring {
+	k := &keyring{}
+	for i := len(keys) - 1; i >= 0; i-- {
+		k.add(keys[i])
+	}
+	return k
+}
+
+type keyring struct {
+	mutex   sync.RWMutex
+	aeads   []cipher.AEAD
+	macKeys [][]byte
+}
+
+func (k *keyring) Rotate(key []byte) {
+	k.mutex.Lock()
+	defer k.mutex.Unlock()
+
+	k.add(key)
+
+	if len(k.aeads) > keyringSize {
+		k.aeads = k.aeads[:keyringSize]
+		k.macKeys = k.macKeys[:keyringSize]
+	}
+}
+
+func (k *keyring) add(key []byte) {
+	digest := sha256.Sum256(key)
+
+	block, err := aes.NewCipher(digest[:])
+	if err != nil {
+		panic(err)
+	}
+
+	aead, err := cipher.NewGCM(block)
+	if err != nil {
+		panic(err)
+	}
+
+	mac := hmac.New(sha256.New, digest[:])
+	mac.Write([]byte("wx mac key"))
+
+	k.aeads = append([]cipher.AEAD{aead}, k.aeads...)
+	k.macKeys = append([][]byte{mac.Sum(nil)}, k.macKeys...)
+}
+
+func (k *keyring) Seal(value string) (string, error) {
+
+	sealed, err := k.SealBytes([]byte(value))
+	if err != nil {
+		return "", err
+	}
+
+	return base64.RawURLEncoding.EncodeToString(sealed), nil
+}
+
+func (k *keyring) Open(sealed string) (string, error) {
+
+	data, err := base64.RawURLEncoding.DecodeString(sealed)
+	if err != nil {
+		return "", fmt.Errorf("decode : %w", err)
+	}
+
+	value, err := k.OpenBytes(data)
+	if err != nil {
+		return "", err
+	}
+
+	return string(value), nil
+}
+
+func (k *keyring) SealBytes(value []byte) ([]byte, error) {
+	return k.seal(value, nil)
+}
+
+func (k *keyring) OpenBytes(data []byte) ([]byte, error) {
+	return k.open(data, nil)
+}
+
+func (k *keyring) seal(value []byte, aad []byte) ([]byte, error) {
+
+	aead, err := k.current()
+	if err != nil {
+		return nil, err
+	}
+
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, fmt.Errorf("nonce : %w", err)
+	}
+
+	return aead.Seal(nonce, nonce, value, aad), nil
+}
+
+func (k *keyring) open(data []byte, aad []byte) ([]byte, error) {
+
+	for _, aead := range k.all() {
+		if len(data) < aead.NonceSize() {
+			continue
+		}
+
+		nonce, ciphertext := data[:aead.NonceSize()], data[aead.NonceSize():]
+		if value, err := aead.Open(nil, nonce, ciphertext, aad); err == nil {
+			return value, nil
+		}
+	}
+
+	return nil, errors.New("no matching key")
+}
+
+func (k *keyring) current() (cipher.AEAD, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	if len(k.aeads) == 0 {
+		return nil, errors.New("keyring empty")
+	}
+
+	return k.aeads[0], nil
+}
+
+func (k *keyring) mac() (hash.Hash, error) {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	if len(k.macKeys) == 0 {
+		return nil, errors.New("keyring empty")
+	}
+
+	return hmac.New(sha256.New, k.macKeys[0]), nil
+}
+
+func (k *keyring) all() []cipher.AEAD {
+	k.mutex.RLock()
+	defer k.mutex.RUnlock()
+
+	return append([]cipher.AEAD{}, k.aeads...)
+}
@@ -0,0 +1,159 @@
+package wx
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"unicode/utf8"
+)
+
+type LambdaRequest struct {
+	Version               string            `json:"version"`
+	RawPath               string            `json:"rawPath"`
+	RawQueryString        string            `json:"rawQueryString"`
+	Cookies               []string          `json:"cookies,omitempty"`
+	Headers               map[string]string `json:"headers"`
+	Body                  string            `json:"body,omitempty"`
+	IsBase64Encoded       bool              `json:"isBase64Encoded"`
+	RequestContext        LambdaContext     `json:"requestContext"`
+	QueryStringParameters map[string]string `json:"queryStringParameters,omitempty"`
+}
+
+type LambdaContext struct {
+	DomainName string         `json:"domainName"`
+	RequestID  string         `json:"requestId"`
+	HTTP       LambdaHTTPInfo `json:"http"`
+}
+
+type LambdaHTTPInfo struct {
+	Method   string `json:"method"`
+	Path     string `json:"path"`
+	Protocol string `json:"protocol"`
+	SourceIP string `json:"sourceIp"`
+}
+
+type LambdaResponse struct {
+	StatusCode      int               `json:"statusCode"`
+	Headers         map[string]string `json:"headers,omitempty"`
+	Cookies         []string          `json:"cookies,omitempty"`
+	Body            string            `json:"body,omitempty"`
+	IsBase64Encoded bool              `json:"isBase64Encoded"`
+}
+
+func NewLambdaHandler(handler http.Handler) func(ctx context.Context, event LambdaRequest) (LambdaResponse, error) {
+	return func(ctx context.Context, event LambdaRequest) (LambdaResponse, error) {
+
+		r, err := NewLambdaHTTPRequest(ctx, event)
+		if err != nil {
+			return LambdaResponse{}, err
+		}
+
+		writer := &lambdaWriter{header: http.Header{}}
+		handler.ServeHTTP(writer, r)
+
+		if writer.statusCode == 0 {
+			writer.statusCode = http.StatusOK
+		}
+
+		return NewLambdaResponse(writer.statusCode, writer.header, writer.body.Bytes()), nil
+	}
+}
+
+func NewLambdaHTTPRequest(ctx context.Context, event LambdaRequest) (*http.Request, error) {
+
+	body := []byte(event.Body)
+	if event.IsBase64Encoded {
+		decoded, err := base64.StdEncoding.DecodeString(event.Body)
+		if err != nil {
+			return nil, fmt.Errorf("decode body : %w", err)
+		}
+		body = decoded
+	}
+
+	path, err := url.PathUnescape(event.RawPath)
+	if err != nil {
+		return nil, fmt.Errorf("unescape path : %w", err)
+	}
+
+	r, err := http.NewRequestWithContext(ctx, event.RequestContext.HTTP.Method, "/", bytes.NewReader(body))
+	if err != nil {
+		return nil, fmt.Errorf("new request : %w", err)
+	}
+
+	r.URL = &url.URL{Path: path, RawPath: event.RawPath, RawQuery: event.RawQueryString}
+
+	for h, v := range event.Headers {
+		r.Header.Set(h, v)
+	}
+
+	if len(event.Cookies) > 0 {
+		r.Header.Set("Cookie", strings.Join(event.Cookies, "; "))
+	}
+
+	if event.RequestContext.RequestID != "" && r.Header.Get("X-Request-ID") == "" {
+		r.Header.Set("X-Request-ID", event.RequestContext.RequestID)
+	}
+
+	if event.RequestContext.HTTP.SourceIP != "" {
+		r.RemoteAddr = event.RequestContext.HTTP.SourceIP + ":0"
+	}
+
+	r.Host = event.RequestContext.DomainName
+	r.ContentLength = int64(len(body))
+
+	return r, nil
+}
+
+func NewLambdaResponse(statusCode int, header http.Header, body []byte) LambdaResponse {
+
+	response := LambdaResponse{
+		StatusCode: statusCode,
+		Headers:    map[string]string{},
+		Cookies:    header.Values("Set-Cookie"),
+	}
+
+	for h, v := range header {
+		if h == "Set-Cookie" {
+			continue
+		}
+		response.Headers[h] = strings.Join(v, ",")
+	}
+
+	if utf8.Valid(body) {
+		response.Body = string(body)
+	} else {
+		response.Body = base64.StdEncoding.EncodeToString(body)
+		response.IsBase64Encoded = true
+	}
+
+	return response
+}
+
+type lambdaWriter struct {
+	header     http.Header
+	body       bytes.Buffer
+	statusCode int
+}
+
+func (l *lambdaWriter) Header() http.Header {
+	return l.header
+}
+
+func (l *lambdaWriter) Write(bytes []byte) (int, error) {
+	if l.statusCode == 0 {
+		l.statusCode = http.StatusOK
+	}
+	return l.body.Write(bytes)
+}
+
+func (l *lambdaWriter) WriteHeader(statusCode int) {
+	if l.statusCode == 0 && !informational(statusCode) {
+		l.statusCode = statusCode
+	}
+}
+
+func (l *lambdaWriter) Flush() {}
@@ -0,0 +1,155 @@
+package wx
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"hash"
+	"io"
+	"net/http"
+	"os"
+	"time"
+)
+
+const contextKeyLargeObject contextKey = "large_object"
+
+var errLargeObjectStored = errors.New("stored in large object tier")
+
+type largeObjectMeta struct {
+	Header  http.Header
+	Body    string
+	Stored  time.Time
+	Expires time.Time
+}
+
+type largeObject struct {
+	ctx   context.Context
+	store ObjectStore
+	key   string
+	ttl   time.Duration
+}
+
+type bodyHasher interface {
+	bodyHash() (hash.Hash, error)
+}
+
+func bodyHash(store ObjectStore) (hash.Hash, error) {
+	if hasher, ok := store.(bodyHasher); ok {
+		return hasher.bodyHash()
+	}
+	return sha256.New(), nil
+}
+
+func largeObjectKey(generation string, url string) string {
+	sum := sha256.Sum256([]byte(generation + " " + url))
+	return hex.EncodeToString(sum[:])
+}
+
+func (c *proxyCache) serveLargeObject(w http.ResponseWriter, r *http.Request, url string, key string, status string) bool {
+
+	metaReader, err := c.largeObjects.Get(r.Context(), "meta/"+key)
+	if err != nil {
+		return false
+	}
+
+	var meta largeObjectMeta
+	err = json.NewDecoder(metaReader).Decode(&meta)
+	metaReader.Close()
+
+	if err != nil || time.Now().After(meta.Expires) {
+		return false
+	}
+
+	body, err := c.largeObjects.Get(r.Context(), "body/"+meta.Body)
+	if err != nil {
+		c.Logger.Errorf("large object body [%v] : %v", meta.Body, err)
+		return false
+	}
+
+	defer body.Close()
+
+	MetricsFromContext(r.Context()).Counter("cache_requests_total", 1, map[string]string{"status": status, "tier": "object"})
+
+	c.writeHeader(w, url, meta.Header, meta.Stored, status)
+	w.WriteHeader(http.StatusOK)
+	copyBody(w, body)
+	return true
+}
+
+func (o *largeObject) spill(buffered []byte) (*largeObjectSpill, error) {
+
+	hash, err := bodyHash(o.store)
+	if err != nil {
+		return nil, fmt.Errorf("body hash : %w", err)
+	}
+
+	file, err := os.CreateTemp("", "wx-large-*")
+	if err != nil {
+		return nil, fmt.Errorf("create spill : %w", err)
+	}
+
+	spill := &largeObjectSpill{largeObject: o, file: file, hash: hash}
+
+	if _, err := spill.Write(buffered); err != nil {
+		spill.abort()
+		return nil, err
+	}
+
+	return spill, nil
+}
+
+type largeObjectSpill struct {
+	*largeObject
+
+	file *os.File
+	hash hash.Hash
+	size int64
+}
+
+func (s *largeObjectSpill) Write(bytes []byte) (int, error) {
+	n, err := io.MultiWriter(s.file, s.hash).Write(bytes)
+	s.size += int64(n)
+	return n, err
+}
+
+func (s *largeObjectSpill) finish(header http.Header) error {
+
+	defer s.abort()
+
+	if _, err := s.file.Seek(0, io.SeekStart); err != nil {
+		return fmt.Errorf("seek spill : %w", err)
+	}
+
+	body := hex.EncodeToString(s.hash.Sum(nil))
+
+	if err := s.store.Put(s.ctx, "body/"+body, s.file, s.size); err != nil {
+		return fmt.Errorf("put body : %w", err)
+	}
+
+	now := time.Now()
+
+	meta, err := json.Marshal(largeObjectMeta{
+		Header:  header,
+		Body:    body,
+		Stored:  now,
+		Expires: now.Add(s.ttl),
+	})
+	if err != nil {
+		return fmt.Errorf("marshal meta : %w", err)
+	}
+
+	if err := s.store.Put(s.ctx, "meta/"+s.key, bytes.NewReader(meta), int64(len(meta))); err != nil {
+		return fmt.Errorf("put meta : %w", err)
+	}
+
+	return nil
+}
+
+func (s *largeObjectSpill) abort() {
+	s.file.Close()
+	os.Remove(s.file.Name())
+}
@@ -0,0 +1,115 @@
+package wx
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+)
+
+const (
+	webhookAttempts = 5
+	webhookBackoff  = time.Second
+)
+
+func NewLifecycleWebhookSink(logger Logger, client *http.Client, url string, secret []byte, eventTypes ...string) *lifecycleWebhookSink {
+	if len(eventTypes) == 0 {
+		eventTypes = []string{AuditLogin, AuditLoginFailed, AuditLogout}
+	}
+
+	types := map[string]bool{}
+	for _, eventType := range eventTypes {
+		types[eventType] = true
+	}
+
+	return &lifecycleWebhookSink{
+		Logger: logger,
+		Client: client,
+		url:    url,
+		secret: secret,
+		types:  types,
+	}
+}
+
+type lifecycleWebhookSink struct {
+	Logger
+	*http.Client
+
+	url    string
+	secret []byte
+	types  map[string]bool
+}
+
+func (s *lifecycleWebhookSink) Audit(event AuditEvent) error {
+	if !s.types[event.Type] {
+		return nil
+	}
+
+	body, err := json.Marshal(event)
+	if err != nil {
+		return fmt.Errorf("marshal event : %w", err)
+	}
+
+	go s.deliver(newRequestID(), event.Type, body)
+	return nil
+}
+
+func (s *lifecycleWebhookSink) deliver(id string, eventType string, body []byte) {
+	backoff := webhookBackoff
+
+	for attempt := 1; ; attempt++ {
+		retry, err := s.post(id, eventType, body)
+		if err == nil {
+			return
+		}
+
+		if !retry || attempt == webhookAttempts {
+			s.Logger.Errorf("webhook [%v] %v : giving up after %d attempts : %v", s.url, eventType, attempt, err)
+			return
+		}
+
+		time.Sleep(backoff)
+		backoff *= 2
+	}
+}
+
+func (s *lifecycleWebhookSink) post(id string, eventType string, body []byte) (bool, error) {
+
+	req, err := http.NewRequest(http.MethodPost, s.url, bytes.NewReader(body))
+	if err != nil {
+		return false, fmt.Errorf("new request: %w", err)
+	}
+
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("X-Webhook-ID", id)
+	req.Header.Set("X-Webhook-Event", eventType)
+	req.Header.Set("X-Webhook-Signature", "t="+timestamp+",v1="+s.sign(timestamp, body))
+
+	resp, err := s.Client.Do(req)
+	if err != nil {
+		return true, fmt.Errorf("post event : %w", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		retry := resp.StatusCode >= 500 || resp.StatusCode == http.StatusTooManyRequests
+		return retry, NewStatusError(resp.StatusCode, fmt.Errorf("post event [%v]", s.url))
+	}
+
+	return false, nil
+}
+
+func (s *lifecycleWebhookSink) sign(timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, s.secret)
+	mac.Write([]byte(timestamp + "."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -0,0 +1,199 @@
+package wx
+
+import (
+	"context"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"runtime/metrics"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const memorySampleInterval = time.Second
+
+const contextKeyShedSlot contextKey = "shed_slot"
+
+type Priority int
+
+const (
+	PriorityNormal Priority = iota
+	PriorityLow
+	PriorityCritical
+)
+
+func (p Priority) String() string {
+	switch p {
+	case PriorityLow:
+		return "low"
+	case PriorityCritical:
+		return "critical"
+	default:
+		return "normal"
+	}
+}
+
+type RoutePriority struct {
+	Path     string
+	Priority Priority
+}
+
+type LoadShedding struct {
+	MaxInflight   int
+	MaxQueueDelay time.Duration
+	MaxMemory     uint64
+	Routes        []RoutePriority
+}
+
+func NewLoadShedder(logger Logger, config LoadShedding) *loadShedder {
+
+	if config.MaxInflight <= 0 {
+		config.MaxInflight = 1000
+	}
+
+	if config.MaxQueueDelay <= 0 {
+		config.MaxQueueDelay = 100 * time.Millisecond
+	}
+
+	return &loadShedder{
+		Logger: logger,
+		config: config,
+		slots:  make(chan struct{}, config.MaxInflight),
+	}
+}
+
+type loadShedder struct {
+	Logger
+
+	config  LoadShedding
+	slots   chan struct{}
+	delay   atomic.Int64
+	memory  atomic.Uint64
+	sampled atomic.Int64
+}
+
+func (s *loadShedder) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		priority := s.priority(r.URL.Path)
+		if priority == PriorityCritical {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		if reason, shed := s.pressure(priority); shed {
+			s.shed(w, r, priority, reason)
+			return
+		}
+
+		select {
+		case s.slots <- struct{}{}:
+			s.observe(0)
+		default:
+			if priority == PriorityLow {
+				s.shed(w, r, priority, "inflight")
+				return
+			}
+
+			start := time.Now()
+			timer := time.NewTimer(s.config.MaxQueueDelay)
+
+			select {
+			case s.slots <- struct{}{}:
+				timer.Stop()
+				s.observe(time.Since(start))
+			case <-timer.C:
+				s.observe(s.config.MaxQueueDelay)
+				s.shed(w, r, priority, "queue_delay")
+				return
+			case <-r.Context().Done():
+				timer.Stop()
+				return
+			}
+		}
+
+		slot := &shedSlot{slots: s.slots}
+		defer slot.release()
+
+		MetricsFromContext(r.Context()).Gauge("load_shed_inflight", float64(len(s.slots)), nil)
+
+		next.ServeHTTP(w, r.WithContext(context.WithValue(r.Context(), contextKeyShedSlot, slot)))
+	})
+}
+
+type shedSlot struct {
+	slots chan struct{}
+	once  sync.Once
+}
+
+func (s *shedSlot) release() {
+	s.once.Do(func() { <-s.slots })
+}
+
+func releaseShedSlot(r *http.Request) {
+	if slot, ok := r.Context().Value(contextKeyShedSlot).(*shedSlot); ok {
+		slot.release()
+	}
+}
+
+func (s *loadShedder) priority(path string) Priority {
+	for _, route := range s.config.Routes {
+		if matchPath(route.Path, path) {
+			return route.Priority
+		}
+	}
+	return PriorityNormal
+}
+
+func (s *loadShedder) pressure(priority Priority) (string, bool) {
+
+	if s.config.MaxMemory > 0 && s.heap() > s.config.MaxMemory {
+		return "memory", true
+	}
+
+	if priority != PriorityLow {
+		return "", false
+	}
+
+	if time.Duration(s.delay.Load()) > s.config.MaxQueueDelay/2 {
+		return "queue_delay", true
+	}
+
+	if len(s.slots) >= cap(s.slots)*3/4 {
+		return "inflight", true
+	}
+
+	return "", false
+}
+
+func (s *loadShedder) observe(delay time.Duration) {
+	current := s.delay.Load()
+	s.delay.Store(current + (int64(delay)-current)/8)
+}
+
+func (s *loadShedder) heap() uint64 {
+
+	now := time.Now().UnixNano()
+	last := s.sampled.Load()
+
+	if now-last > int64(memorySampleInterval) && s.sampled.CompareAndSwap(last, now) {
+		sample := []metrics.Sample{{Name: "/memory/classes/heap/objects:bytes"}}
+		metrics.Read(sample)
+
+		if sample[0].Value.Kind() == metrics.KindUint64 {
+			s.memory.Store(sample[0].Value.Uint64())
+		}
+	}
+
+	return s.memory.Load()
+}
+
+func (s *loadShedder) shed(w http.ResponseWriter, r *http.Request, priority Priority, reason string) {
+	MetricsFromContext(r.Context()).Counter("requests_shed_total", 1, map[string]string{"priority": priority.String(), "reason": reason})
+	s.Logger.Debug("load shed : ", r.URL.Path, " : ", reason)
+
+	w.Header().Set("Retry-After", strconv.Itoa(1+rand.IntN(5)))
+	RenderError(w, r, fmt.Errorf("%w: overloaded (%v)", ErrServiceUnavailable, reason))
+}
@@ -0,0 +1,64 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestLoadShedReleasesStreams(t *testing.T) {
+
+	shedder := NewLoadShedder(nopLogger{}, LoadShedding{MaxInflight: 1, MaxQueueDelay: 10 * time.Millisecond})
+
+	tests := []struct {
+		name   string
+		stream bool
+		status int
+	}{
+		{"request while stream open", true, http.StatusOK},
+		{"request while request inflight", false, http.StatusServiceUnavailable},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			started := make(chan struct{})
+			done := make(chan struct{})
+
+			first := shedder.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if test.stream {
+					_, release, err := TrackStream(r)
+					if err != nil {
+						t.Error(err)
+						return
+					}
+					defer release()
+				}
+
+				close(started)
+				<-done
+			}))
+
+			go first.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/events", nil))
+			<-started
+
+			second := shedder.Handler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+
+			r := httptest.NewRequest(http.MethodGet, "/api", nil)
+			r.Header.Set("Accept", "application/json")
+			w := httptest.NewRecorder()
+			second.ServeHTTP(w, r)
+
+			close(done)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+
+			for len(shedder.slots) > 0 {
+				time.Sleep(time.Millisecond)
+			}
+		})
+	}
+}
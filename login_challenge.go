@@ -0,0 +1,68 @@
+package wx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+type LoginRequiredError struct {
+	LoginURL string
+	Err      error
+}
+
+func (e *LoginRequiredError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *LoginRequiredError) Unwrap() error {
+	return e.Err
+}
+
+func ChallengeLogin(w http.ResponseWriter, r *http.Request, loginPath string, err error) {
+
+	loginURL := loginPath + "?redirect_uri=" + url.QueryEscape(r.URL.RequestURI())
+
+	if r.Method == http.MethodGet && !isAPIRequest(r) {
+		http.Redirect(w, r, loginURL, http.StatusTemporaryRedirect)
+		return
+	}
+
+	if w.Header().Get("WWW-Authenticate") == "" {
+		if errors.Is(err, errMissingCredentials) {
+			w.Header().Set("WWW-Authenticate", BearerChallenge(""))
+		} else if errors.Is(err, ErrUnauthorized) {
+			w.Header().Set("WWW-Authenticate", BearerChallenge("invalid_token"))
+		}
+	}
+
+	RenderError(w, r, &LoginRequiredError{LoginURL: loginURL, Err: err})
+}
+
+func BearerChallenge(code string, params ...string) string {
+	if code == "" {
+		return "Bearer"
+	}
+
+	challenge := fmt.Sprintf(`Bearer error="%s"`, code)
+
+	for i := 0; i+1 < len(params); i += 2 {
+		challenge += fmt.Sprintf(`, %s="%s"`, params[i], strings.NewReplacer(`"`, "", `\`, "").Replace(params[i+1]))
+	}
+
+	return challenge
+}
+
+func isAPIRequest(r *http.Request) bool {
+	if r.Header.Get("X-Requested-With") == "XMLHttpRequest" {
+		return true
+	}
+
+	if mode := r.Header.Get("Sec-Fetch-Mode"); mode != "" {
+		return mode != "navigate"
+	}
+
+	return !prefersHTML(r)
+}
@@ -0,0 +1,71 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"unicode"
+
+	"golang.org/x/oauth2"
+)
+
+const maxLoginParam = 256
+
+var defaultLoginParams = []string{"prompt", "login_hint", "acr_values", "ui_locales", "max_age"}
+
+var promptValues = map[string]bool{
+	"none":           true,
+	"login":          true,
+	"consent":        true,
+	"select_account": true,
+}
+
+func WithLoginParams(names ...string) authOpt {
+	return func(a *authServer) {
+		a.loginParams = names
+	}
+}
+
+func (a *authServer) loginParamOptions(r *http.Request) ([]oauth2.AuthCodeOption, error) {
+
+	opts := []oauth2.AuthCodeOption{}
+
+	for _, name := range a.loginParams {
+		value := r.FormValue(name)
+		if value == "" {
+			continue
+		}
+
+		if err := validateLoginParam(name, value); err != nil {
+			return nil, fmt.Errorf("%w: %w", ErrBadRequest, err)
+		}
+
+		opts = append(opts, oauth2.SetAuthURLParam(name, value))
+	}
+
+	return opts, nil
+}
+
+func validateLoginParam(name string, value string) error {
+
+	if len(value) > maxLoginParam || strings.IndexFunc(value, unicode.IsControl) >= 0 {
+		return fmt.Errorf("invalid %v", name)
+	}
+
+	switch name {
+	case "prompt":
+		for _, prompt := range strings.Fields(value) {
+			if !promptValues[prompt] {
+				return fmt.Errorf("invalid prompt %q", prompt)
+			}
+		}
+
+	case "max_age":
+		if age, err := strconv.Atoi(value); err != nil || age < 0 {
+			return fmt.Errorf("invalid max_age %q", value)
+		}
+	}
+
+	return nil
+}
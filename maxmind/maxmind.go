@@ -0,0 +1,40 @@
+package maxmind
+
+import (
+	"fmt"
+	"net/netip"
+
+	"github.com/oschwald/maxminddb-golang"
+)
+
+func NewLookup(path string) (*lookup, error) {
+
+	reader, err := maxminddb.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("open [%s] : %w", path, err)
+	}
+
+	return &lookup{reader: reader}, nil
+}
+
+type lookup struct {
+	reader *maxminddb.Reader
+}
+
+func (m *lookup) Lookup(ip netip.Addr) (string, error) {
+	var record struct {
+		Country struct {
+			ISOCode string `maxminddb:"iso_code"`
+		} `maxminddb:"country"`
+	}
+
+	if err := m.reader.Lookup(ip.AsSlice(), &record); err != nil {
+		return "", fmt.Errorf("lookup [%v] : %w", ip, err)
+	}
+
+	return record.Country.ISOCode, nil
+}
+
+func (m *lookup) Close() error {
+	return m.reader.Close()
+}
@@ -0,0 +1,34 @@
+package maxmind
+
+import (
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestNewLookupRejectsInvalidDatabase(t *testing.T) {
+
+	dir := t.TempDir()
+
+	invalid := filepath.Join(dir, "invalid.mmdb")
+	if err := os.WriteFile(invalid, []byte("not a maxmind database"), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name string
+		path string
+	}{
+		{"missing database", filepath.Join(dir, "missing.mmdb")},
+		{"invalid database", invalid},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			if _, err := NewLookup(test.path); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
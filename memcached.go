@@ -0,0 +1,259 @@
+package wx
+
+import (
+	"bufio"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/golang/groupcache"
+	"github.com/golang/groupcache/consistenthash"
+	"github.com/golang/groupcache/singleflight"
+)
+
+const (
+	memcachedReplicas  = 50
+	memcachedIdleConns = 8
+	memcachedTimeout   = time.Second
+	memcachedMaxRelTTL = 30 * 24 * time.Hour
+)
+
+var errMemcachedMiss = errors.New("memcached miss")
+
+func NewMemcachedGetter(logger Logger, getter groupcache.Getter, ttl time.Duration, servers ...string) *memcachedGetter {
+	ring := consistenthash.New(memcachedReplicas, nil)
+	ring.Add(servers...)
+
+	pools := map[string]chan net.Conn{}
+	for _, server := range servers {
+		pools[server] = make(chan net.Conn, memcachedIdleConns)
+	}
+
+	return &memcachedGetter{
+		Logger: logger,
+		Getter: getter,
+		ttl:    ttl,
+		ring:   ring,
+		pools:  pools,
+	}
+}
+
+func NewEncryptedMemcachedGetter(logger Logger, getter groupcache.Getter, ttl time.Duration, keyring *keyring, servers ...string) *memcachedGetter {
+	m := NewMemcachedGetter(logger, getter, ttl, servers...)
+	m.keyring = keyring
+	return m
+}
+
+type memcachedGetter struct {
+	Logger
+	groupcache.Getter
+
+	ttl     time.Duration
+	keyring *keyring
+	ring    *consistenthash.Map
+	pools   map[string]chan net.Conn
+	flight  singleflight.Group
+}
+
+func (m *memcachedGetter) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+
+	hashed := memcachedKey(key)
+
+	value, err := m.flight.Do(hashed, func() (interface{}, error) {
+		data, err := m.load(hashed)
+		if err == nil {
+			return data, nil
+		}
+
+		if !errors.Is(err, errMemcachedMiss) {
+			m.Logger.Errorf("memcached get [%v] : %v", key, err)
+		}
+
+		if err := m.Getter.Get(ctx, key, groupcache.AllocatingByteSliceSink(&data)); err != nil {
+			return nil, err
+		}
+
+		if err := m.store(hashed, data); err != nil {
+			m.Logger.Errorf("memcached set [%v] : %v", key, err)
+		}
+
+		return data, nil
+	})
+	if err != nil {
+		return err
+	}
+
+	return dest.SetBytes(value.([]byte))
+}
+
+func (m *memcachedGetter) load(key string) ([]byte, error) {
+
+	data, err := m.get(key)
+	if err != nil || m.keyring == nil {
+		return data, err
+	}
+
+	value, err := m.keyring.open(data, []byte(key))
+	if err != nil {
+		return nil, fmt.Errorf("%w: open : %v", errMemcachedMiss, err)
+	}
+
+	return value, nil
+}
+
+func (m *memcachedGetter) store(key string, data []byte) error {
+
+	if m.keyring != nil {
+		sealed, err := m.keyring.seal(data, []byte(key))
+		if err != nil {
+			return fmt.Errorf("seal : %w", err)
+		}
+		data = sealed
+	}
+
+	return m.set(key, data)
+}
+
+func (m *memcachedGetter) get(key string) ([]byte, error) {
+	var data []byte
+
+	err := m.do(key, func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "get %s\r\n", key); err != nil {
+			return err
+		}
+
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		if line == "END\r\n" {
+			return errMemcachedMiss
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) != 4 || fields[0] != "VALUE" {
+			return fmt.Errorf("unexpected response : %q", line)
+		}
+
+		size, err := strconv.Atoi(fields[3])
+		if err != nil {
+			return fmt.Errorf("invalid size : %w", err)
+		}
+
+		data = make([]byte, size+2)
+		if _, err := io.ReadFull(rw, data); err != nil {
+			return err
+		}
+		data = data[:size]
+
+		if line, err = rw.ReadString('\n'); err != nil {
+			return err
+		}
+
+		if line != "END\r\n" {
+			return fmt.Errorf("unexpected response : %q", line)
+		}
+
+		return nil
+	})
+
+	return data, err
+}
+
+func (m *memcachedGetter) set(key string, data []byte) error {
+	return m.do(key, func(rw *bufio.ReadWriter) error {
+		if _, err := fmt.Fprintf(rw, "set %s 0 %d %d\r\n", key, memcachedExpiry(m.ttl, time.Now()), len(data)); err != nil {
+			return err
+		}
+
+		if _, err := rw.Write(data); err != nil {
+			return err
+		}
+
+		if _, err := rw.WriteString("\r\n"); err != nil {
+			return err
+		}
+
+		if err := rw.Flush(); err != nil {
+			return err
+		}
+
+		line, err := rw.ReadString('\n')
+		if err != nil {
+			return err
+		}
+
+		if line != "STORED\r\n" {
+			return fmt.Errorf("unexpected response : %q", strings.TrimSpace(line))
+		}
+
+		return nil
+	})
+}
+
+func (m *memcachedGetter) do(key string, fn func(rw *bufio.ReadWriter) error) error {
+
+	server := m.ring.Get(key)
+	if server == "" {
+		return errors.New("no memcached servers")
+	}
+
+	conn, err := m.conn(server)
+	if err != nil {
+		return err
+	}
+
+	conn.SetDeadline(time.Now().Add(memcachedTimeout))
+
+	err = fn(bufio.NewReadWriter(bufio.NewReader(conn), bufio.NewWriter(conn)))
+	if err != nil && !errors.Is(err, errMemcachedMiss) {
+		conn.Close()
+		return err
+	}
+
+	select {
+	case m.pools[server] <- conn:
+	default:
+		conn.Close()
+	}
+
+	return err
+}
+
+func (m *memcachedGetter) conn(server string) (net.Conn, error) {
+	select {
+	case conn := <-m.pools[server]:
+		return conn, nil
+	default:
+		return net.DialTimeout("tcp", server, memcachedTimeout)
+	}
+}
+
+func memcachedExpiry(ttl time.Duration, now time.Time) int64 {
+	switch {
+	case ttl <= 0:
+		return 0
+	case ttl < time.Second:
+		return 1
+	case ttl > memcachedMaxRelTTL:
+		return now.Add(ttl).Unix()
+	}
+	return int64(ttl / time.Second)
+}
+
+func memcachedKey(key string) string {
+	sum := sha256.Sum256([]byte(key))
+	return "wx:" + hex.EncodeToString(sum[:])
+}
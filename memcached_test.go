@@ -0,0 +1,201 @@
+package wx
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/golang/groupcache"
+)
+
+type fakeMemcached struct {
+	net.Listener
+
+	mutex  sync.Mutex
+	values map[string][]byte
+	sets   []string
+}
+
+func newFakeMemcached(t *testing.T) *fakeMemcached {
+	t.Helper()
+
+	listener, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	memcached := &fakeMemcached{Listener: listener, values: map[string][]byte{}}
+	t.Cleanup(func() { listener.Close() })
+
+	go func() {
+		for {
+			conn, err := listener.Accept()
+			if err != nil {
+				return
+			}
+			go memcached.serve(conn)
+		}
+	}()
+
+	return memcached
+}
+
+func (f *fakeMemcached) serve(conn net.Conn) {
+	defer conn.Close()
+
+	reader := bufio.NewReader(conn)
+
+	for {
+		line, err := reader.ReadString('\n')
+		if err != nil {
+			return
+		}
+
+		fields := strings.Fields(line)
+		if len(fields) == 0 {
+			return
+		}
+
+		switch fields[0] {
+		case "get":
+			f.mutex.Lock()
+			value, ok := f.values[fields[1]]
+			f.mutex.Unlock()
+
+			if ok {
+				fmt.Fprintf(conn, "VALUE %s 0 %d\r\n%s\r\n", fields[1], len(value), value)
+			}
+			io.WriteString(conn, "END\r\n")
+
+		case "set":
+			size, _ := strconv.Atoi(fields[4])
+			data := make([]byte, size+2)
+			if _, err := io.ReadFull(reader, data); err != nil {
+				return
+			}
+
+			f.mutex.Lock()
+			f.values[fields[1]] = data[:size]
+			f.sets = append(f.sets, strings.Join(fields[:4], " "))
+			f.mutex.Unlock()
+
+			io.WriteString(conn, "STORED\r\n")
+		}
+	}
+}
+
+type countingGetter struct {
+	mutex sync.Mutex
+	calls int
+}
+
+func (g *countingGetter) Get(ctx context.Context, key string, dest groupcache.Sink) error {
+	g.mutex.Lock()
+	g.calls++
+	g.mutex.Unlock()
+
+	return dest.SetBytes([]byte("value of " + key))
+}
+
+func TestMemcachedExpiry(t *testing.T) {
+
+	now := time.Unix(1700000000, 0)
+
+	tests := []struct {
+		name   string
+		ttl    time.Duration
+		expiry int64
+	}{
+		{"no expiry", 0, 0},
+		{"sub second", 500 * time.Millisecond, 1},
+		{"seconds", 90 * time.Second, 90},
+		{"thirty days", memcachedMaxRelTTL, int64(memcachedMaxRelTTL / time.Second)},
+		{"beyond thirty days", 31 * 24 * time.Hour, now.Add(31 * 24 * time.Hour).Unix()},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if expiry := memcachedExpiry(test.ttl, now); expiry != test.expiry {
+				t.Fatalf("expected %v, got %v", test.expiry, expiry)
+			}
+		})
+	}
+}
+
+func TestMemcachedGetter(t *testing.T) {
+
+	ring := NewKeyring([]byte("memcached-key"))
+
+	tests := []struct {
+		name      string
+		keyring   *keyring
+		tamper    func(values map[string][]byte)
+		refetched bool
+	}{
+		{"plain hit", nil, nil, false},
+		{"sealed hit", ring, nil, false},
+		{"sealed value moved to other key", ring, func(values map[string][]byte) {
+			values[memcachedKey("a")] = values[memcachedKey("b")]
+		}, true},
+		{"sealed value corrupted", ring, func(values map[string][]byte) {
+			values[memcachedKey("a")] = []byte("garbage")
+		}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			server := newFakeMemcached(t)
+			getter := &countingGetter{}
+
+			memcached := NewEncryptedMemcachedGetter(nopLogger{}, getter, time.Minute, test.keyring, server.Addr().String())
+
+			for _, key := range []string{"a", "b"} {
+				var value []byte
+				if err := memcached.Get(context.Background(), key, groupcache.AllocatingByteSliceSink(&value)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			server.mutex.Lock()
+
+			stored, set := server.values[memcachedKey("a")], server.sets[0]
+			if test.tamper != nil {
+				test.tamper(server.values)
+			}
+
+			server.mutex.Unlock()
+
+			if sealed := !bytes.Contains(stored, []byte("value of a")); sealed != (test.keyring != nil) {
+				t.Fatalf("expected sealed %v, got %q", test.keyring != nil, stored)
+			}
+
+			if set != "set "+memcachedKey("a")+" 0 60" {
+				t.Fatalf("expected relative ttl, got %v", set)
+			}
+
+			getter.calls = 0
+
+			var value []byte
+			if err := NewEncryptedMemcachedGetter(nopLogger{}, getter, time.Minute, test.keyring, server.Addr().String()).Get(context.Background(), "a", groupcache.AllocatingByteSliceSink(&value)); err != nil {
+				t.Fatal(err)
+			}
+
+			if string(value) != "value of a" {
+				t.Fatalf("expected value of a, got %q", value)
+			}
+
+			if refetched := getter.calls > 0; refetched != test.refetched {
+				t.Fatalf("expected refetched %v, got %v", test.refetched, refetched)
+			}
+		})
+	}
+}
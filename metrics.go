@@ -0,0 +1,81 @@
+package wx
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"sync/atomic"
+	"time"
+)
+
+const contextKeyMetrics contextKey = "metrics"
+
+type Metrics interface {
+	Counter(name string, value float64, tags map[string]string)
+	Histogram(name string, value float64, tags map[string]string)
+	Gauge(name string, value float64, tags map[string]string)
+}
+
+type nopMetrics struct{}
+
+func (nopMetrics) Counter(name string, value float64, tags map[string]string)   {}
+func (nopMetrics) Histogram(name string, value float64, tags map[string]string) {}
+func (nopMetrics) Gauge(name string, value float64, tags map[string]string)     {}
+
+func NewMultiMetrics(metrics ...Metrics) multiMetrics {
+	return multiMetrics(metrics)
+}
+
+type multiMetrics []Metrics
+
+func (m multiMetrics) Counter(name string, value float64, tags map[string]string) {
+	for _, metrics := range m {
+		metrics.Counter(name, value, tags)
+	}
+}
+
+func (m multiMetrics) Histogram(name string, value float64, tags map[string]string) {
+	for _, metrics := range m {
+		metrics.Histogram(name, value, tags)
+	}
+}
+
+func (m multiMetrics) Gauge(name string, value float64, tags map[string]string) {
+	for _, metrics := range m {
+		metrics.Gauge(name, value, tags)
+	}
+}
+
+func NewWithMetrics(metrics Metrics, handler http.Handler) http.Handler {
+	var inflight atomic.Int64
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+
+		metrics.Gauge("http_requests_in_flight", float64(inflight.Add(1)), nil)
+		defer func() {
+			metrics.Gauge("http_requests_in_flight", float64(inflight.Add(-1)), nil)
+		}()
+
+		writer := NewCountingWriter(w)
+
+		ctx := context.WithValue(r.Context(), contextKeyMetrics, metrics)
+		handler.ServeHTTP(writer, r.WithContext(ctx))
+
+		tags := map[string]string{
+			"method": r.Method,
+			"status": strconv.Itoa(writer.StatusCode()),
+		}
+
+		metrics.Counter("http_requests_total", 1, tags)
+		metrics.Histogram("http_request_duration_seconds", time.Since(start).Seconds(), tags)
+		metrics.Histogram("http_response_size_bytes", float64(writer.Size()), tags)
+	})
+}
+
+func MetricsFromContext(ctx context.Context) Metrics {
+	if metrics, ok := ctx.Value(contextKeyMetrics).(Metrics); ok {
+		return metrics
+	}
+	return nopMetrics{}
+}
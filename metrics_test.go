@@ -0,0 +1,40 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestMetricsStatus(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc
+		status  string
+	}{
+		{"never writes", func(w http.ResponseWriter, r *http.Request) {}, "200"},
+		{"writes body", func(w http.ResponseWriter, r *http.Request) { w.Write([]byte("ok")) }, "200"},
+		{"writes header", func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusNotFound) }, "404"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			metrics := NewDashboardMetrics()
+			handler := NewWithMetrics(metrics, test.handler)
+			handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/", nil))
+
+			statuses := []string{}
+			for _, counter := range metrics.Snapshot().Counters {
+				if counter.Name == "http_requests_total" {
+					statuses = append(statuses, counter.Tags["status"])
+				}
+			}
+
+			if len(statuses) != 1 || statuses[0] != test.status {
+				t.Fatalf("expected status %v, got %v", test.status, statuses)
+			}
+		})
+	}
+}
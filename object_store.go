@@ -0,0 +1,174 @@
+package wx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+type ObjectStore interface {
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	Put(ctx context.Context, key string, body io.Reader, size int64) error
+}
+
+func NewDirObjectStore(dir string) *dirObjectStore {
+	return &dirObjectStore{dir: dir}
+}
+
+type dirObjectStore struct {
+	dir string
+}
+
+func (d *dirObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	return os.Open(filepath.Join(d.dir, filepath.FromSlash(key)))
+}
+
+func (d *dirObjectStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+
+	name := filepath.Join(d.dir, filepath.FromSlash(key))
+
+	if err := os.MkdirAll(filepath.Dir(name), 0o755); err != nil {
+		return fmt.Errorf("mkdir [%v] : %w", key, err)
+	}
+
+	file, err := os.CreateTemp(filepath.Dir(name), ".put-*")
+	if err != nil {
+		return fmt.Errorf("create [%v] : %w", key, err)
+	}
+
+	defer os.Remove(file.Name())
+
+	if _, err = copyBody(file, body); err != nil {
+		file.Close()
+		return fmt.Errorf("write [%v] : %w", key, err)
+	}
+
+	if err = file.Close(); err != nil {
+		return fmt.Errorf("close [%v] : %w", key, err)
+	}
+
+	return os.Rename(file.Name(), name)
+}
+
+func (d *dirObjectStore) Sweep(maxAge time.Duration) error {
+
+	cutoff := time.Now().Add(-maxAge)
+
+	return filepath.WalkDir(d.dir, func(name string, entry fs.DirEntry, err error) error {
+		if err != nil {
+			if errors.Is(err, fs.ErrNotExist) {
+				return nil
+			}
+			return err
+		}
+
+		if entry.IsDir() {
+			return nil
+		}
+
+		info, err := entry.Info()
+		if err != nil {
+			return nil
+		}
+
+		if info.ModTime().Before(cutoff) {
+			if err := os.Remove(name); err != nil && !errors.Is(err, fs.ErrNotExist) {
+				return fmt.Errorf("remove [%v] : %w", name, err)
+			}
+		}
+
+		return nil
+	})
+}
+
+func (d *dirObjectStore) Run(ctx context.Context, logger Logger, maxAge time.Duration, interval time.Duration) {
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			if err := d.Sweep(maxAge); err != nil {
+				logger.Errorf("sweep object store [%v] : %v", d.dir, err)
+			}
+		}
+	}
+}
+
+func NewHTTPObjectStore(client *http.Client, baseURL string) *httpObjectStore {
+	return &httpObjectStore{
+		Client:  client,
+		baseURL: strings.TrimRight(baseURL, "/"),
+	}
+}
+
+func NewS3ObjectStore(client *http.Client, bucketURL string, credentials SigV4Credentials) *httpObjectStore {
+
+	signed := *client
+	signed.Transport = NewSigV4Transport(client.Transport, credentials)
+
+	return NewHTTPObjectStore(&signed, bucketURL)
+}
+
+type httpObjectStore struct {
+	*http.Client
+	baseURL string
+}
+
+func (h *httpObjectStore) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, h.baseURL+"/"+key, nil)
+	if err != nil {
+		return nil, fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("get [%v] : %w", key, err)
+	}
+
+	if resp.StatusCode == http.StatusNotFound {
+		resp.Body.Close()
+		return nil, fmt.Errorf("get [%v] : %w", key, fs.ErrNotExist)
+	}
+
+	if resp.StatusCode != http.StatusOK {
+		resp.Body.Close()
+		return nil, fmt.Errorf("get [%v] : status %d", key, resp.StatusCode)
+	}
+
+	return resp.Body, nil
+}
+
+func (h *httpObjectStore) Put(ctx context.Context, key string, body io.Reader, size int64) error {
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPut, h.baseURL+"/"+key, body)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	req.ContentLength = size
+
+	resp, err := h.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("put [%v] : %w", key, err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= 300 {
+		return fmt.Errorf("put [%v] : status %d", key, resp.StatusCode)
+	}
+
+	return nil
+}
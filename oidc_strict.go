@@ -0,0 +1,195 @@
+package wx
+
+import (
+	"crypto/sha256"
+	"crypto/sha512"
+	"crypto/subtle"
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"hash"
+	"slices"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+type StrictOIDC struct {
+	Issuer   string
+	Audience string
+	MaxSkew  time.Duration
+	MaxAge   time.Duration
+}
+
+func NewStrictOIDC(config StrictOIDC) (*StrictOIDC, error) {
+
+	if err := validateURL(config.Issuer); err != nil {
+		return nil, fmt.Errorf("strict oidc : issuer : %w", err)
+	}
+
+	if config.MaxSkew <= 0 {
+		config.MaxSkew = time.Minute
+	}
+
+	if config.MaxAge <= 0 {
+		config.MaxAge = 10 * time.Minute
+	}
+
+	return &config, nil
+}
+
+func WithStrictOIDC(config *StrictOIDC) authOpt {
+	return func(a *authServer) {
+		a.strictOIDC = config
+	}
+}
+
+func (a *authServer) validateIDToken(token *oauth2.Token, nonce string, clientID string) (map[string]string, error) {
+
+	details := map[string]string{}
+
+	reject := func(check string, format string, args ...interface{}) (map[string]string, error) {
+		details["check"] = check
+		details["reason"] = fmt.Sprintf(format, args...)
+		return details, fmt.Errorf("%w: id token %v : %v", ErrUnauthorized, check, details["reason"])
+	}
+
+	idToken, ok := token.Extra("id_token").(string)
+	if !ok || idToken == "" {
+		return reject("presence", "no id_token in token response")
+	}
+
+	claims, err := a.claims(idToken)
+	if err != nil {
+		return reject("format", "%v", err)
+	}
+
+	config := a.strictOIDC
+
+	audience := config.Audience
+	if audience == "" {
+		audience = clientID
+	}
+
+	issuer, _ := claims["iss"].(string)
+	subject, _ := claims["sub"].(string)
+	details["iss"] = issuer
+	details["sub"] = subject
+
+	if issuer == "" || issuer != config.Issuer {
+		return reject("iss", "expected %q, got %q", config.Issuer, issuer)
+	}
+
+	if subject == "" {
+		return reject("sub", "missing subject")
+	}
+
+	audiences := claimStrings(claims["aud"])
+	details["aud"] = strings.Join(audiences, " ")
+
+	if !slices.Contains(audiences, audience) {
+		return reject("aud", "%q not in audience", audience)
+	}
+
+	azp, hasAzp := claims["azp"].(string)
+	if len(audiences) > 1 && !hasAzp {
+		return reject("azp", "missing azp with multiple audiences")
+	}
+
+	if hasAzp && azp != clientID {
+		return reject("azp", "expected %q, got %q", clientID, azp)
+	}
+
+	now := time.Now()
+
+	exp, ok := claims["exp"].(float64)
+	if !ok {
+		return reject("exp", "missing expiry")
+	}
+
+	if expiry := time.Unix(int64(exp), 0); now.After(expiry.Add(config.MaxSkew)) {
+		return reject("exp", "expired at %v", expiry.UTC().Format(time.RFC3339))
+	}
+
+	iat, ok := claims["iat"].(float64)
+	if !ok {
+		return reject("iat", "missing issued at")
+	}
+
+	issued := time.Unix(int64(iat), 0)
+	if issued.After(now.Add(config.MaxSkew)) {
+		return reject("iat", "issued in the future at %v", issued.UTC().Format(time.RFC3339))
+	}
+
+	if now.Sub(issued) > config.MaxAge+config.MaxSkew {
+		return reject("iat", "issued too long ago at %v", issued.UTC().Format(time.RFC3339))
+	}
+
+	claimed, _ := claims["nonce"].(string)
+	if claimed == "" || nonce == "" || subtle.ConstantTimeCompare([]byte(claimed), []byte(nonce)) != 1 {
+		return reject("nonce", "nonce mismatch")
+	}
+
+	if atHash, ok := claims["at_hash"].(string); ok {
+		expected, err := accessTokenHash(idToken, token.AccessToken)
+		if err != nil {
+			return reject("at_hash", "%v", err)
+		}
+
+		if subtle.ConstantTimeCompare([]byte(atHash), []byte(expected)) != 1 {
+			return reject("at_hash", "access token hash mismatch")
+		}
+	}
+
+	return details, nil
+}
+
+func accessTokenHash(idToken string, accessToken string) (string, error) {
+
+	header, err := base64.RawURLEncoding.DecodeString(strings.TrimRight(strings.Split(idToken, ".")[0], "="))
+	if err != nil {
+		return "", fmt.Errorf("decode header : %w", err)
+	}
+
+	var jose struct {
+		Alg string `json:"alg"`
+	}
+
+	if err := json.Unmarshal(header, &jose); err != nil {
+		return "", fmt.Errorf("unmarshal header : %w", err)
+	}
+
+	var h hash.Hash
+	switch {
+	case strings.HasSuffix(jose.Alg, "256"):
+		h = sha256.New()
+	case strings.HasSuffix(jose.Alg, "384"):
+		h = sha512.New384()
+	case strings.HasSuffix(jose.Alg, "512"), jose.Alg == "EdDSA":
+		h = sha512.New()
+	default:
+		return "", fmt.Errorf("unsupported alg %q", jose.Alg)
+	}
+
+	h.Write([]byte(accessToken))
+	sum := h.Sum(nil)
+
+	return base64.RawURLEncoding.EncodeToString(sum[:len(sum)/2]), nil
+}
+
+func claimStrings(claim interface{}) []string {
+	switch t := claim.(type) {
+	case string:
+		return []string{t}
+	case []interface{}:
+		values := []string{}
+		for _, v := range t {
+			if s, ok := v.(string); ok {
+				values = append(values, s)
+			}
+		}
+		return values
+	}
+	return nil
+}
@@ -0,0 +1,134 @@
+package wx
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/base64"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestNewStrictOIDC(t *testing.T) {
+
+	tests := []struct {
+		name   string
+		issuer string
+		valid  bool
+	}{
+		{"valid", "https://idp.example.com", true},
+		{"empty issuer", "", false},
+		{"relative issuer", "idp.example.com", false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			config, err := NewStrictOIDC(StrictOIDC{Issuer: test.issuer})
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+
+			if test.valid && (config.MaxSkew <= 0 || config.MaxAge <= 0) {
+				t.Fatalf("expected default skew and age, got %v %v", config.MaxSkew, config.MaxAge)
+			}
+		})
+	}
+}
+
+func TestValidateIDToken(t *testing.T) {
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := NewStrictOIDC(StrictOIDC{Issuer: "https://idp.example.com"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	a := NewAuthServer(nopLogger{}, WithStrictOIDC(config)).(*authServer)
+
+	digest := sha256.Sum256([]byte("access"))
+	atHash := base64.RawURLEncoding.EncodeToString(digest[:16])
+
+	now := time.Now()
+
+	claims := func(changes map[string]interface{}) map[string]interface{} {
+		claims := map[string]interface{}{
+			"iss":   "https://idp.example.com",
+			"sub":   "alice",
+			"aud":   "client",
+			"exp":   now.Add(time.Hour).Unix(),
+			"iat":   now.Unix(),
+			"nonce": "nonce",
+		}
+
+		for name, value := range changes {
+			if value == nil {
+				delete(claims, name)
+			} else {
+				claims[name] = value
+			}
+		}
+
+		return claims
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		nonce  string
+		check  string
+	}{
+		{"valid", claims(nil), "nonce", ""},
+		{"valid at_hash", claims(map[string]interface{}{"at_hash": atHash}), "nonce", ""},
+		{"missing issuer", claims(map[string]interface{}{"iss": nil}), "nonce", "iss"},
+		{"other issuer", claims(map[string]interface{}{"iss": "https://evil.example.com"}), "nonce", "iss"},
+		{"missing subject", claims(map[string]interface{}{"sub": nil}), "nonce", "sub"},
+		{"other audience", claims(map[string]interface{}{"aud": "other"}), "nonce", "aud"},
+		{"multiple audiences without azp", claims(map[string]interface{}{"aud": []string{"client", "other"}}), "nonce", "azp"},
+		{"multiple audiences with azp", claims(map[string]interface{}{"aud": []string{"client", "other"}, "azp": "client"}), "nonce", ""},
+		{"other azp", claims(map[string]interface{}{"azp": "other"}), "nonce", "azp"},
+		{"missing expiry", claims(map[string]interface{}{"exp": nil}), "nonce", "exp"},
+		{"expired", claims(map[string]interface{}{"exp": now.Add(-time.Hour).Unix()}), "nonce", "exp"},
+		{"expired within skew", claims(map[string]interface{}{"exp": now.Add(-30 * time.Second).Unix()}), "nonce", ""},
+		{"missing issued at", claims(map[string]interface{}{"iat": nil}), "nonce", "iat"},
+		{"issued in the future", claims(map[string]interface{}{"iat": now.Add(time.Hour).Unix()}), "nonce", "iat"},
+		{"issued too long ago", claims(map[string]interface{}{"iat": now.Add(-time.Hour).Unix()}), "nonce", "iat"},
+		{"missing nonce", claims(map[string]interface{}{"nonce": nil}), "nonce", "nonce"},
+		{"other nonce", claims(nil), "other", "nonce"},
+		{"empty expected nonce", claims(map[string]interface{}{"nonce": ""}), "", "nonce"},
+		{"other at_hash", claims(map[string]interface{}{"at_hash": "other"}), "nonce", "at_hash"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			token := (&oauth2.Token{AccessToken: "access"}).WithExtra(map[string]interface{}{
+				"id_token": rsaToken(t, key, "kid", test.claims),
+			})
+
+			details, err := a.validateIDToken(token, test.nonce, "client")
+
+			if test.check == "" {
+				if err != nil {
+					t.Fatalf("expected valid token, got %v", err)
+				}
+				return
+			}
+
+			if err == nil || details["check"] != test.check {
+				t.Fatalf("expected %v check to fail, got %v", test.check, err)
+			}
+		})
+	}
+
+	t.Run("missing id token", func(t *testing.T) {
+		if _, err := a.validateIDToken(&oauth2.Token{AccessToken: "access"}, "nonce", "client"); err == nil {
+			t.Fatal("expected missing id_token to fail")
+		}
+	})
+}
@@ -0,0 +1,96 @@
+package otelmetrics
+
+import (
+	"context"
+	"sync"
+
+	"go.opentelemetry.io/otel/attribute"
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/reverted/wx"
+)
+
+const otelMeterName = "github.com/reverted/wx"
+
+func NewMetrics(logger wx.Logger, provider metric.MeterProvider) *otelMetrics {
+
+	if provider == nil {
+		logger.Error("otel metrics : no meter provider configured")
+		provider = noop.NewMeterProvider()
+	}
+
+	return &otelMetrics{
+		Logger:     logger,
+		Meter:      provider.Meter(otelMeterName),
+		counters:   map[string]metric.Float64Counter{},
+		histograms: map[string]metric.Float64Histogram{},
+		gauges:     map[string]metric.Float64Gauge{},
+	}
+}
+
+type otelMetrics struct {
+	wx.Logger
+	metric.Meter
+
+	mutex      sync.Mutex
+	counters   map[string]metric.Float64Counter
+	histograms map[string]metric.Float64Histogram
+	gauges     map[string]metric.Float64Gauge
+}
+
+func (m *otelMetrics) Counter(name string, value float64, tags map[string]string) {
+	m.mutex.Lock()
+	counter, ok := m.counters[name]
+	if !ok {
+		var err error
+		if counter, err = m.Meter.Float64Counter(name); err != nil {
+			m.Logger.Errorf("otel counter [%v] : %v", name, err)
+			counter = noop.Float64Counter{}
+		}
+		m.counters[name] = counter
+	}
+	m.mutex.Unlock()
+
+	counter.Add(context.Background(), value, metric.WithAttributes(otelAttributes(tags)...))
+}
+
+func (m *otelMetrics) Histogram(name string, value float64, tags map[string]string) {
+	m.mutex.Lock()
+	histogram, ok := m.histograms[name]
+	if !ok {
+		var err error
+		if histogram, err = m.Meter.Float64Histogram(name); err != nil {
+			m.Logger.Errorf("otel histogram [%v] : %v", name, err)
+			histogram = noop.Float64Histogram{}
+		}
+		m.histograms[name] = histogram
+	}
+	m.mutex.Unlock()
+
+	histogram.Record(context.Background(), value, metric.WithAttributes(otelAttributes(tags)...))
+}
+
+func (m *otelMetrics) Gauge(name string, value float64, tags map[string]string) {
+	m.mutex.Lock()
+	gauge, ok := m.gauges[name]
+	if !ok {
+		var err error
+		if gauge, err = m.Meter.Float64Gauge(name); err != nil {
+			m.Logger.Errorf("otel gauge [%v] : %v", name, err)
+			gauge = noop.Float64Gauge{}
+		}
+		m.gauges[name] = gauge
+	}
+	m.mutex.Unlock()
+
+	gauge.Record(context.Background(), value, metric.WithAttributes(otelAttributes(tags)...))
+}
+
+func otelAttributes(tags map[string]string) []attribute.KeyValue {
+	attributes := make([]attribute.KeyValue, 0, len(tags))
+	for k, v := range tags {
+		attributes = append(attributes, attribute.String(k, v))
+	}
+	return attributes
+}
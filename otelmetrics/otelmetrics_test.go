@@ -0,0 +1,94 @@
+package otelmetrics
+
+import (
+	"testing"
+
+	"go.opentelemetry.io/otel/metric"
+	"go.opentelemetry.io/otel/metric/noop"
+
+	"github.com/reverted/wx"
+)
+
+var _ wx.Metrics = (*otelMetrics)(nil)
+
+type recordingLogger struct {
+	errors []string
+}
+
+func (l *recordingLogger) Error(args ...interface{}) { l.errors = append(l.errors, "error") }
+func (l *recordingLogger) Errorf(format string, args ...interface{}) {
+	l.errors = append(l.errors, format)
+}
+func (l *recordingLogger) Info(...interface{})          {}
+func (l *recordingLogger) Infof(string, ...interface{}) {}
+func (l *recordingLogger) Debug(...interface{})         {}
+
+type recordingProvider struct {
+	noop.MeterProvider
+	meter *recordingMeter
+}
+
+func (p recordingProvider) Meter(name string, opts ...metric.MeterOption) metric.Meter {
+	p.meter.name = name
+	return p.meter
+}
+
+type recordingMeter struct {
+	noop.Meter
+	name        string
+	instruments []string
+}
+
+func (m *recordingMeter) Float64Counter(name string, opts ...metric.Float64CounterOption) (metric.Float64Counter, error) {
+	m.instruments = append(m.instruments, "counter:"+name)
+	return noop.Float64Counter{}, nil
+}
+
+func (m *recordingMeter) Float64Histogram(name string, opts ...metric.Float64HistogramOption) (metric.Float64Histogram, error) {
+	m.instruments = append(m.instruments, "histogram:"+name)
+	return noop.Float64Histogram{}, nil
+}
+
+func (m *recordingMeter) Float64Gauge(name string, opts ...metric.Float64GaugeOption) (metric.Float64Gauge, error) {
+	m.instruments = append(m.instruments, "gauge:"+name)
+	return noop.Float64Gauge{}, nil
+}
+
+func TestMetricsCreatesInstrumentsOnce(t *testing.T) {
+
+	meter := &recordingMeter{}
+	metrics := NewMetrics(&recordingLogger{}, recordingProvider{meter: meter})
+
+	for i := 0; i < 3; i++ {
+		metrics.Counter("requests", 1, map[string]string{"status": "200"})
+		metrics.Histogram("latency", 0.5, nil)
+		metrics.Gauge("inflight", 2, nil)
+	}
+
+	if meter.name != otelMeterName {
+		t.Fatalf("expected meter %v, got %v", otelMeterName, meter.name)
+	}
+
+	expected := []string{"counter:requests", "histogram:latency", "gauge:inflight"}
+	if len(meter.instruments) != len(expected) {
+		t.Fatalf("expected instruments %v, got %v", expected, meter.instruments)
+	}
+
+	for i, instrument := range expected {
+		if meter.instruments[i] != instrument {
+			t.Fatalf("expected instruments %v, got %v", expected, meter.instruments)
+		}
+	}
+}
+
+func TestMetricsWithoutProvider(t *testing.T) {
+
+	logger := &recordingLogger{}
+	metrics := NewMetrics(logger, nil)
+
+	metrics.Counter("requests", 1, nil)
+
+	if len(logger.errors) != 1 {
+		t.Fatalf("expected missing provider to be logged, got %v", logger.errors)
+	}
+}
@@ -0,0 +1,66 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func WithPKCE() authOpt {
+	return func(a *authServer) {
+		a.pkce = true
+	}
+}
+
+func (a *authServer) pkceCookieName() string {
+	return a.stateCookieName + "_pkce"
+}
+
+func (a *authServer) setVerifier(w http.ResponseWriter) (oauth2.AuthCodeOption, error) {
+
+	verifier := oauth2.GenerateVerifier()
+
+	value := verifier
+	if a.keyring != nil {
+		sealed, err := a.keyring.Seal(verifier)
+		if err != nil {
+			return nil, fmt.Errorf("seal verifier : %w", err)
+		}
+		value = sealed
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.pkceCookieName(),
+		Value:    value,
+		Path:     "/",
+		Expires:  time.Now().Add(stateLifetime),
+		HttpOnly: true,
+	})
+
+	return oauth2.S256ChallengeOption(verifier), nil
+}
+
+func (a *authServer) verifier(w http.ResponseWriter, r *http.Request) (oauth2.AuthCodeOption, error) {
+
+	cookie, err := r.Cookie(a.pkceCookieName())
+	if err != nil || cookie.Value == "" {
+		return nil, fmt.Errorf("%w: missing pkce verifier", ErrBadRequest)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:   a.pkceCookieName(),
+		Path:   "/",
+		MaxAge: -1,
+	})
+
+	verifier := cookie.Value
+	if a.keyring != nil {
+		if verifier, err = a.keyring.Open(cookie.Value); err != nil {
+			return nil, fmt.Errorf("%w: open pkce verifier : %v", ErrBadRequest, err)
+		}
+	}
+
+	return oauth2.VerifierOption(verifier), nil
+}
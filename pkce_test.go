@@ -0,0 +1,121 @@
+package wx
+
+import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func pkceTokenServer(t *testing.T, challenges map[string]string) *httptest.Server {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+		digest := sha256.Sum256([]byte(r.FormValue("code_verifier")))
+		if challenges[r.FormValue("code")] != base64.RawURLEncoding.EncodeToString(digest[:]) {
+			w.Header().Set("Content-Type", "application/json")
+			w.WriteHeader(http.StatusBadRequest)
+			json.NewEncoder(w).Encode(map[string]string{"error": "invalid_grant"})
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(map[string]interface{}{
+			"access_token": "access",
+			"token_type":   "Bearer",
+			"expires_in":   3600,
+		})
+	}))
+	t.Cleanup(server.Close)
+
+	return server
+}
+
+func TestPKCE(t *testing.T) {
+
+	challenges := map[string]string{}
+	idp := pkceTokenServer(t, challenges)
+
+	config := oauth2Config()
+	config.Endpoint = oauth2.Endpoint{AuthURL: idp.URL + "/authorize", TokenURL: idp.URL + "/token"}
+
+	tests := []struct {
+		name    string
+		keyring *keyring
+		tamper  func(cookie *http.Cookie)
+		status  int
+	}{
+		{"plain verifier", nil, nil, http.StatusTemporaryRedirect},
+		{"sealed verifier", NewKeyring([]byte("cookie-key")), nil, http.StatusTemporaryRedirect},
+		{"missing verifier", nil, func(cookie *http.Cookie) { cookie.Value = "" }, http.StatusBadRequest},
+		{"other verifier", nil, func(cookie *http.Cookie) { cookie.Value = oauth2.GenerateVerifier() }, http.StatusBadRequest},
+		{"unsealed verifier with keyring", NewKeyring([]byte("cookie-key")), func(cookie *http.Cookie) { cookie.Value = oauth2.GenerateVerifier() }, http.StatusBadRequest},
+		{"verifier sealed by other key", NewKeyring([]byte("cookie-key")), func(cookie *http.Cookie) {
+			cookie.Value, _ = NewKeyring([]byte("other-key")).Seal(oauth2.GenerateVerifier())
+		}, http.StatusBadRequest},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			opts := []authOpt{WithOAuthConfig(config), WithPKCE()}
+			if test.keyring != nil {
+				opts = append(opts, WithCookieKeyring(test.keyring))
+			}
+
+			a := NewAuthServer(nopLogger{}, opts...).(*authServer)
+
+			login := httptest.NewRecorder()
+			a.Login(login, httptest.NewRequest(http.MethodGet, "https://wx.example.com/auth/login?redirect_uri=/home", nil))
+
+			if login.Code != http.StatusTemporaryRedirect {
+				t.Fatalf("expected login redirect, got %v", login.Code)
+			}
+
+			location, err := url.Parse(login.Header().Get("Location"))
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			query := location.Query()
+			if query.Get("code_challenge_method") != "S256" || query.Get("code_challenge") == "" {
+				t.Fatalf("expected S256 challenge, got %v", query)
+			}
+
+			code := test.name
+			challenges[code] = query.Get("code_challenge")
+
+			callback := httptest.NewRequest(http.MethodGet, "https://wx.example.com/auth/callback?"+url.Values{"code": {code}, "state": {query.Get("state")}}.Encode(), nil)
+
+			for _, cookie := range login.Result().Cookies() {
+				if cookie.Name == a.pkceCookieName() {
+					digest := sha256.Sum256([]byte(cookie.Value))
+					if plain := base64.RawURLEncoding.EncodeToString(digest[:]) == challenges[code]; plain != (test.keyring == nil) {
+						t.Fatalf("expected plain verifier cookie %v, got %v", test.keyring == nil, plain)
+					}
+					if test.tamper != nil {
+						test.tamper(cookie)
+					}
+				}
+				callback.AddCookie(cookie)
+			}
+
+			w := httptest.NewRecorder()
+			a.Callback(w, callback)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v : %v", test.status, w.Code, w.Body.String())
+			}
+
+			if test.status == http.StatusTemporaryRedirect && w.Header().Get("Location") != "/home" {
+				t.Fatalf("expected redirect to /home, got %v", w.Header().Get("Location"))
+			}
+		})
+	}
+}
@@ -0,0 +1,46 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+)
+
+type Preload struct {
+	Path        string
+	As          string
+	CrossOrigin bool
+}
+
+func NewWithPreload(logger Logger, assets AssetResolver, preloads []Preload, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodGet || !strings.Contains(r.Header.Get("Accept"), "text/html") {
+			handler.ServeHTTP(w, r)
+			return
+		}
+
+		for _, preload := range preloads {
+			url, err := assets.Asset(preload.Path)
+			if err != nil {
+				logger.Errorf("preload [%s] : %v", preload.Path, err)
+				continue
+			}
+
+			w.Header().Add("Link", preload.link(url))
+		}
+
+		if r.ProtoMajor >= 2 && w.Header().Get("Link") != "" {
+			w.WriteHeader(http.StatusEarlyHints)
+		}
+
+		handler.ServeHTTP(w, r)
+	})
+}
+
+func (p Preload) link(url string) string {
+	link := fmt.Sprintf("<%s>; rel=preload; as=%s", url, p.As)
+	if p.CrossOrigin {
+		link += "; crossorigin"
+	}
+	return link
+}
@@ -0,0 +1,115 @@
+package wx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"net/http/httptrace"
+	"net/textproto"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+type staticAssets map[string]string
+
+func (a staticAssets) Asset(asset string) (string, error) {
+	if url, ok := a[asset]; ok {
+		return url, nil
+	}
+	return "", fmt.Errorf("unknown asset %v", asset)
+}
+
+func TestPreloadEarlyHintsThroughMiddleware(t *testing.T) {
+
+	assets := staticAssets{"/app.css": "/app.3f2a.css"}
+	preloads := []Preload{{Path: "/app.css", As: "style"}}
+
+	page := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		w.Write([]byte("<html></html>"))
+	})
+
+	slowLog := &recordingLogger{}
+
+	tests := []struct {
+		name    string
+		handler http.Handler
+		header  string
+		logged  string
+	}{
+		{"preload", NewWithPreload(nopLogger{}, assets, preloads, page), "", ""},
+		{"etag", NewWithETag(nopLogger{}, NewWithPreload(nopLogger{}, assets, preloads, page)), "ETag", ""},
+		{"cache control", NewWithCacheControl(nopLogger{}, time.Minute, NewWithPreload(nopLogger{}, assets, preloads, page)), "Cache-Control", ""},
+		{"slow request log", NewWithSlowRequestLog(slowLog, SlowRequestThresholds{Latency: time.Nanosecond}, NewWithPreload(nopLogger{}, assets, preloads, page)), "", "status 200"},
+		{"full chain", NewWithMetrics(nopMetrics{}, NewWithSlowRequestLog(slowLog, SlowRequestThresholds{Latency: time.Nanosecond},
+			NewWithCacheControl(nopLogger{}, time.Minute, NewWithETag(nopLogger{}, NewWithPreload(nopLogger{}, assets, preloads, page))))), "ETag", "status 200"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			slowLog.infos = nil
+
+			server := httptest.NewUnstartedServer(test.handler)
+			server.EnableHTTP2 = true
+			server.StartTLS()
+			defer server.Close()
+
+			var mutex sync.Mutex
+			hints := []string{}
+
+			trace := &httptrace.ClientTrace{
+				Got1xxResponse: func(code int, header textproto.MIMEHeader) error {
+					mutex.Lock()
+					defer mutex.Unlock()
+					hints = append(hints, fmt.Sprintf("%d %s", code, header.Get("Link")))
+					return nil
+				},
+			}
+
+			r, err := http.NewRequestWithContext(httptrace.WithClientTrace(context.Background(), trace), http.MethodGet, server.URL+"/", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+			r.Header.Set("Accept", "text/html")
+
+			resp, err := server.Client().Do(r)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if resp.ProtoMajor != 2 {
+				t.Fatalf("expected http/2, got %v", resp.Proto)
+			}
+
+			if resp.StatusCode != http.StatusOK || string(body) != "<html></html>" {
+				t.Fatalf("expected page, got %v %q", resp.StatusCode, body)
+			}
+
+			if test.header != "" && resp.Header.Get(test.header) == "" {
+				t.Fatalf("expected %v on final response, got %v", test.header, resp.Header)
+			}
+
+			if test.logged != "" && (len(slowLog.infos) != 1 || !strings.Contains(slowLog.infos[0], test.logged)) {
+				t.Fatalf("expected slow request log with %q, got %v", test.logged, slowLog.infos)
+			}
+
+			mutex.Lock()
+			defer mutex.Unlock()
+
+			if len(hints) != 1 || hints[0] != "103 </app.3f2a.css>; rel=preload; as=style" {
+				t.Fatalf("expected early hints, got %v", hints)
+			}
+		})
+	}
+}
@@ -0,0 +1,154 @@
+package wx
+
+import (
+	"fmt"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+type Provider struct {
+	oauth2.Config
+	RoleClaim           string
+	Issuer              string
+	JWKSURL             string
+	AccessTokenAudience string
+	AuthCodeOptions     []oauth2.AuthCodeOption
+}
+
+func (p Provider) Options() []serverOpt {
+	opts := []serverOpt{}
+
+	if p.RoleClaim != "" {
+		opts = append(opts, WithAuthOptions(WithRoleClaim(p.RoleClaim)))
+	}
+
+	if p.AuthCodeOptions != nil {
+		opts = append(opts, WithAuthOptions(WithAuthCodeOptions(p.AuthCodeOptions...)))
+	}
+
+	if p.JWKSURL != "" && p.Issuer != "" {
+		opts = append(opts, WithAuthOptions(WithIDTokenJWKS(p.JWKSURL, p.Issuer, p.ClientID, 0)))
+	}
+
+	if p.JWKSURL != "" && p.AccessTokenAudience != "" {
+		opts = append(opts, WithAuthOptions(WithJWKS(p.JWKSURL, p.Issuer, p.AccessTokenAudience, 0)))
+	}
+
+	return opts
+}
+
+func Google(clientID string, clientSecret string) Provider {
+	return Provider{
+		Config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  "https://accounts.google.com/o/oauth2/auth",
+				TokenURL: "https://oauth2.googleapis.com/token",
+			},
+			Scopes: []string{"openid", "email", "profile"},
+		},
+		Issuer:          "https://accounts.google.com",
+		JWKSURL:         "https://www.googleapis.com/oauth2/v3/certs",
+		AuthCodeOptions: []oauth2.AuthCodeOption{oauth2.AccessTypeOffline},
+	}
+}
+
+func AzureAD(tenant string, clientID string, clientSecret string) Provider {
+	base := fmt.Sprintf("https://login.microsoftonline.com/%s", tenant)
+
+	return Provider{
+		Config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  base + "/oauth2/v2.0/authorize",
+				TokenURL: base + "/oauth2/v2.0/token",
+			},
+			Scopes: []string{"openid", "email", "profile", "offline_access"},
+		},
+		RoleClaim:       "roles",
+		Issuer:          base + "/v2.0",
+		JWKSURL:         base + "/discovery/v2.0/keys",
+		AuthCodeOptions: []oauth2.AuthCodeOption{},
+	}
+}
+
+func Keycloak(baseURL string, realm string, clientID string, clientSecret string) Provider {
+	issuer := fmt.Sprintf("%s/realms/%s", strings.TrimRight(baseURL, "/"), realm)
+	base := issuer + "/protocol/openid-connect"
+
+	return Provider{
+		Config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  base + "/auth",
+				TokenURL: base + "/token",
+			},
+			Scopes: []string{"openid", "email", "profile"},
+		},
+		RoleClaim:           "realm_access.roles",
+		Issuer:              issuer,
+		JWKSURL:             base + "/certs",
+		AccessTokenAudience: "account",
+		AuthCodeOptions:     []oauth2.AuthCodeOption{},
+	}
+}
+
+func Okta(domain string, clientID string, clientSecret string) Provider {
+	base := fmt.Sprintf("https://%s/oauth2/default/v1", domain)
+
+	return Provider{
+		Config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  base + "/authorize",
+				TokenURL: base + "/token",
+			},
+			Scopes: []string{"openid", "email", "profile", "groups"},
+		},
+		RoleClaim:           "groups",
+		Issuer:              fmt.Sprintf("https://%s/oauth2/default", domain),
+		JWKSURL:             base + "/keys",
+		AccessTokenAudience: "api://default",
+		AuthCodeOptions:     []oauth2.AuthCodeOption{},
+	}
+}
+
+func Auth0(domain string, clientID string, clientSecret string) Provider {
+	base := fmt.Sprintf("https://%s", domain)
+
+	return Provider{
+		Config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  base + "/authorize",
+				TokenURL: base + "/oauth/token",
+			},
+			Scopes: []string{"openid", "email", "profile"},
+		},
+		RoleClaim:       "permissions",
+		Issuer:          base + "/",
+		JWKSURL:         base + "/.well-known/jwks.json",
+		AuthCodeOptions: []oauth2.AuthCodeOption{},
+	}
+}
+
+func GitHub(clientID string, clientSecret string) Provider {
+	return Provider{
+		Config: oauth2.Config{
+			ClientID:     clientID,
+			ClientSecret: clientSecret,
+			Endpoint: oauth2.Endpoint{
+				AuthURL:  "https://github.com/login/oauth/authorize",
+				TokenURL: "https://github.com/login/oauth/access_token",
+			},
+			Scopes: []string{"read:user", "user:email"},
+		},
+		AuthCodeOptions: []oauth2.AuthCodeOption{},
+	}
+}
@@ -0,0 +1,52 @@
+package wx
+
+import (
+	"testing"
+)
+
+func TestProviderOptionsVerifySessions(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		provider Provider
+		idTokens string
+		issuer   string
+		access   string
+		audience string
+	}{
+		{"google", Google("client", "secret"), "https://www.googleapis.com/oauth2/v3/certs", "https://accounts.google.com", "", ""},
+		{"azure ad", AzureAD("tenant", "client", "secret"), "https://login.microsoftonline.com/tenant/discovery/v2.0/keys", "https://login.microsoftonline.com/tenant/v2.0", "", ""},
+		{"keycloak", Keycloak("https://sso.example.com/", "wx", "client", "secret"), "https://sso.example.com/realms/wx/protocol/openid-connect/certs", "https://sso.example.com/realms/wx", "https://sso.example.com/realms/wx/protocol/openid-connect/certs", "account"},
+		{"okta", Okta("wx.okta.com", "client", "secret"), "https://wx.okta.com/oauth2/default/v1/keys", "https://wx.okta.com/oauth2/default", "https://wx.okta.com/oauth2/default/v1/keys", "api://default"},
+		{"auth0", Auth0("wx.auth0.com", "client", "secret"), "https://wx.auth0.com/.well-known/jwks.json", "https://wx.auth0.com/", "", ""},
+		{"github", GitHub("client", "secret"), "", "", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			config := newServerConfig(test.provider.Options()...)
+
+			a := &authServer{}
+			for _, opt := range config.authOpts {
+				opt(a)
+			}
+
+			if test.idTokens == "" && a.idTokens != nil {
+				t.Fatalf("expected no id token verifier, got %v", a.idTokens.url)
+			}
+
+			if test.idTokens != "" && (a.idTokens == nil || a.idTokens.url != test.idTokens || a.idTokens.issuer != test.issuer || a.idTokens.audience != "client") {
+				t.Fatalf("expected id token verifier for %v %v, got %+v", test.idTokens, test.issuer, a.idTokens)
+			}
+
+			if test.access == "" && a.jwks != nil {
+				t.Fatalf("expected no access token verifier, got %v", a.jwks.url)
+			}
+
+			if test.access != "" && (a.jwks == nil || a.jwks.url != test.access || a.jwks.issuer != test.issuer || a.jwks.audience != test.audience) {
+				t.Fatalf("expected access token verifier for %v %v, got %+v", test.access, test.audience, a.jwks)
+			}
+		})
+	}
+}
@@ -0,0 +1,178 @@
+package wx
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"strings"
+
+	"github.com/golang/groupcache/singleflight"
+)
+
+const maxCoalescedResponse = 1 << 20
+
+type Coalescing struct {
+	Paths       []string
+	Headers     []string
+	MaxBodySize int64
+}
+
+func WithCoalescing(config Coalescing) proxyOpt {
+	return func(p *proxyServer) {
+		if len(config.Headers) == 0 {
+			config.Headers = []string{"Authorization", "Cookie", "Accept", "Accept-Encoding", "Accept-Language"}
+		}
+
+		if config.MaxBodySize <= 0 {
+			config.MaxBodySize = maxCoalescedResponse
+		}
+
+		p.coalescing = &coalescer{Coalescing: config}
+	}
+}
+
+type coalescer struct {
+	Coalescing
+	flight singleflight.Group
+}
+
+type coalescedResponse struct {
+	status int
+	proto  string
+	header http.Header
+	body   []byte
+	live   *http.Response
+}
+
+func (c *coalescer) coalescable(r *http.Request) bool {
+
+	if r.Method != http.MethodGet || r.ContentLength != 0 || isUpgrade(r) {
+		return false
+	}
+
+	if len(c.Paths) == 0 {
+		return true
+	}
+
+	route, ok := RouteFromContext(r.Context())
+	if !ok {
+		return false
+	}
+
+	for _, path := range c.Paths {
+		if matchPath(path, route.Path) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (c *coalescer) key(r *http.Request) string {
+
+	parts := []string{r.Method, r.URL.String()}
+	for _, header := range c.Headers {
+		parts = append(parts, strings.Join(r.Header.Values(header), ","))
+	}
+
+	return strings.Join(parts, "\x00")
+}
+
+func (p *proxyServer) do(req *http.Request) (*http.Response, error) {
+
+	if p.coalescing == nil || !p.coalescing.coalescable(req) {
+		return p.Client.Do(req)
+	}
+
+	leader := false
+
+	value, err := p.coalescing.flight.Do(p.coalescing.key(req), func() (interface{}, error) {
+		leader = true
+
+		ctx, cancel := context.WithCancel(context.WithoutCancel(req.Context()))
+
+		resp, err := p.Client.Do(req.WithContext(ctx))
+		if err != nil {
+			cancel()
+			return nil, err
+		}
+
+		if resp.Header.Get("Content-Type") == "text/event-stream" || resp.ContentLength > p.coalescing.MaxBodySize {
+			return &coalescedResponse{live: liveResponse(req.Context(), resp, nil, cancel)}, nil
+		}
+
+		body, err := io.ReadAll(io.LimitReader(resp.Body, p.coalescing.MaxBodySize+1))
+		if err != nil {
+			resp.Body.Close()
+			cancel()
+			return nil, err
+		}
+
+		if int64(len(body)) > p.coalescing.MaxBodySize {
+			return &coalescedResponse{live: liveResponse(req.Context(), resp, body, cancel)}, nil
+		}
+
+		resp.Body.Close()
+		cancel()
+
+		return &coalescedResponse{
+			status: resp.StatusCode,
+			proto:  resp.Proto,
+			header: resp.Header,
+			body:   body,
+		}, nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	shared := value.(*coalescedResponse)
+
+	if shared.live != nil {
+		if leader {
+			return shared.live, nil
+		}
+		return p.Client.Do(req)
+	}
+
+	if !leader {
+		MetricsFromContext(req.Context()).Counter("upstream_coalesced_total", 1, p.tags(req.URL))
+	}
+
+	return &http.Response{
+		Status:        http.StatusText(shared.status),
+		StatusCode:    shared.status,
+		Proto:         shared.proto,
+		Header:        shared.header.Clone(),
+		Body:          io.NopCloser(bytes.NewReader(shared.body)),
+		ContentLength: int64(len(shared.body)),
+		Request:       req,
+	}, nil
+}
+
+func liveResponse(ctx context.Context, resp *http.Response, read []byte, cancel context.CancelFunc) *http.Response {
+
+	stop := context.AfterFunc(ctx, cancel)
+	body := resp.Body
+
+	resp.Body = &liveBody{
+		Reader: io.MultiReader(bytes.NewReader(read), body),
+		close: func() error {
+			stop()
+			defer cancel()
+			return body.Close()
+		},
+	}
+
+	return resp
+}
+
+type liveBody struct {
+	io.Reader
+	close func() error
+}
+
+func (b *liveBody) Close() error {
+	return b.close()
+}
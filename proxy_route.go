@@ -0,0 +1,37 @@
+package wx
+
+import (
+	"context"
+	"net/url"
+)
+
+const (
+	contextKeyRoute  contextKey = "route"
+	contextKeyLogger contextKey = "logger"
+)
+
+type ProxyRoute struct {
+	Path       string
+	Target     *url.URL
+	Experiment string
+}
+
+func ContextWithRoute(ctx context.Context, route ProxyRoute) context.Context {
+	return context.WithValue(ctx, contextKeyRoute, route)
+}
+
+func RouteFromContext(ctx context.Context) (ProxyRoute, bool) {
+	route, ok := ctx.Value(contextKeyRoute).(ProxyRoute)
+	return route, ok
+}
+
+func ContextWithLogger(ctx context.Context, logger Logger) context.Context {
+	return context.WithValue(ctx, contextKeyLogger, logger)
+}
+
+func LoggerFromContext(ctx context.Context) Logger {
+	if logger, ok := ctx.Value(contextKeyLogger).(Logger); ok {
+		return logger
+	}
+	return nopLogger{}
+}
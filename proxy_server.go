@@ -1,15 +1,30 @@
 package wx
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"io"
 	"net/http"
 	"net/url"
+	"strings"
+	"sync"
+	"sync/atomic"
 	"time"
 )
 
 type Modifier func(r *http.Request) error
 
+type ModifierFunc func(ctx context.Context, r *http.Request) error
+
+type ResponseModifier func(resp *http.Response) error
+
+type ProxyServer interface {
+	Serve(w http.ResponseWriter, r *http.Request)
+	NewRequest(r *http.Request) (*http.Request, error)
+	Health(ctx context.Context) error
+}
+
 type proxyOpt func(*proxyServer)
 
 func WithClient(client *http.Client) proxyOpt {
@@ -18,22 +33,52 @@ func WithClient(client *http.Client) proxyOpt {
 	}
 }
 
+func WithTransportSettings(settings TransportSettings) proxyOpt {
+	return func(p *proxyServer) {
+		p.Client = NewTargetClient(settings)
+	}
+}
+
 func WithTarget(target *url.URL) proxyOpt {
 	return func(p *proxyServer) {
 		p.Target = target
 	}
 }
 
+func WithTargetSelector(selector TargetSelector) proxyOpt {
+	return func(p *proxyServer) {
+		p.selector = selector
+	}
+}
+
+func WithMaxUploadSize(size int64) proxyOpt {
+	return func(p *proxyServer) {
+		p.maxUploadSize = size
+	}
+}
+
 func WithModifier(modifier Modifier) proxyOpt {
 	return func(p *proxyServer) {
 		p.Modifiers = append(p.Modifiers, modifier)
 	}
 }
 
-func NewProxyServer(logger Logger, opts ...proxyOpt) *proxyServer {
+func WithModifierFunc(modifier ModifierFunc) proxyOpt {
+	return WithModifier(func(r *http.Request) error {
+		return modifier(r.Context(), r)
+	})
+}
+
+func WithResponseModifier(modifier ResponseModifier) proxyOpt {
+	return func(p *proxyServer) {
+		p.responseModifiers = append(p.responseModifiers, modifier)
+	}
+}
+
+func NewProxyServer(logger Logger, opts ...proxyOpt) ProxyServer {
 	server := &proxyServer{
 		Logger:    logger,
-		Client:    http.DefaultClient,
+		Client:    NewTargetClient(TransportSettings{}),
 		Modifiers: []Modifier{},
 	}
 
@@ -49,64 +94,179 @@ type proxyServer struct {
 	*http.Client
 	Target    *url.URL
 	Modifiers []Modifier
+	selector  TargetSelector
+
+	upgradeIdleTimeout time.Duration
+	maxUploadSize      int64
+	experiments        []Experiment
+	responseModifiers  []ResponseModifier
+	coalescing         *coalescer
+
+	upgradePolicy   UpgradePolicy
+	upgradeMutex    sync.Mutex
+	upgradesPerUser map[string]int
+	upgrades        atomic.Int64
 }
 
 func (p *proxyServer) Serve(w http.ResponseWriter, r *http.Request) {
 
+	if isUpgrade(r) {
+		release, err := p.admitUpgrade(r)
+		if err != nil {
+			RenderError(w, r, err)
+			p.Logger.Info(err)
+			return
+		}
+
+		defer release()
+	}
+
+	tracked := isUpgrade(r) || acceptsEventStream(r)
+
+	if tracked {
+		ctx, release, err := TrackStream(r)
+		if err != nil {
+			RejectStream(w, r, err)
+			p.Logger.Info(err)
+			return
+		}
+
+		defer release()
+		r = r.WithContext(ctx)
+	}
+
 	req, err := p.NewRequest(r)
 	if err != nil {
-		http.Error(w, err.Error(), http.StatusInternalServerError)
+		RenderError(w, r, err)
 		p.Logger.Errorf("new request : %v", err)
 		return
 	}
 
-	resp, err := p.Client.Do(req)
+	start := time.Now()
+
+	resp, err := p.do(req)
+	RecordTiming(r.Context(), "upstream_headers", time.Since(start))
+	MetricsFromContext(r.Context()).Histogram("upstream_request_duration_seconds", time.Since(start).Seconds(), p.tags(req.URL))
 	if err != nil {
-		switch t := err.(type) {
-		default:
-			http.Error(w, err.Error(), http.StatusInternalServerError)
-		case *statusError:
-			http.Error(w, t.Error(), t.StatusCode)
+		MetricsFromContext(r.Context()).Counter("upstream_errors_total", 1, p.tags(req.URL))
+		if errors.As(err, new(*http.MaxBytesError)) {
+			err = NewStatusError(http.StatusRequestEntityTooLarge, err)
+		} else if !errors.As(err, new(*StatusError)) {
+			err = NewStatusError(http.StatusBadGateway, err)
 		}
+		RenderError(w, r, err)
 		p.Logger.Errorf("client do : %v", err)
 		return
 	}
 
 	defer resp.Body.Close()
 
+	for _, modifier := range p.responseModifiers {
+		if err := modifier(resp); err != nil {
+			RenderError(w, r, NewStatusError(http.StatusBadGateway, fmt.Errorf("response modifier : %w", err)))
+			p.Logger.Errorf("response modifier : %v", err)
+			return
+		}
+	}
+
+	streaming := resp.Header.Get("Content-Type") == "text/event-stream"
+
+	if streaming && !tracked {
+		ctx, release, err := TrackStream(r)
+		if err != nil {
+			RejectStream(w, r, err)
+			p.Logger.Info(err)
+			return
+		}
+
+		defer release()
+		r = r.WithContext(ctx)
+	}
+
 	for h, val := range resp.Header {
 		for _, v := range val {
 			w.Header().Add(h, v)
 		}
 	}
 
+	if resp.StatusCode == http.StatusSwitchingProtocols {
+		p.Tunnel(w, r, resp)
+		return
+	}
+
 	w.WriteHeader(resp.StatusCode)
 
-	if resp.Header.Get("Content-Type") == "text/event-stream" {
-		p.Stream(w, req, resp)
+	start = time.Now()
+
+	if streaming {
+		p.Stream(w, r, resp)
 		p.Logger.Info("streaming done")
 	} else {
-		io.Copy(w, resp.Body)
+		copyBody(w, resp.Body)
+	}
+
+	RecordTiming(r.Context(), "upstream_body", time.Since(start))
+}
+
+func acceptsEventStream(r *http.Request) bool {
+	for _, value := range r.Header.Values("Accept") {
+		for _, part := range strings.Split(value, ",") {
+			if mediaType, _, _ := strings.Cut(part, ";"); strings.EqualFold(strings.TrimSpace(mediaType), "text/event-stream") {
+				return true
+			}
+		}
 	}
+	return false
+}
+
+func (p *proxyServer) tags(target *url.URL) map[string]string {
+	return map[string]string{"target": target.Host}
 }
 
 func (p *proxyServer) NewRequest(r *http.Request) (*http.Request, error) {
 
-	url := p.Target.ResolveReference(r.URL)
+	experiment, inExperiment := p.experiment(r)
+
+	url, err := p.targetUrl(r, experiment.Target)
+	if err != nil {
+		return nil, err
+	}
 
 	p.Logger.Info("<<< ", r.URL.String())
 
-	req, err := http.NewRequestWithContext(r.Context(), r.Method, url.String(), r.Body)
+	if p.maxUploadSize > 0 && r.ContentLength > p.maxUploadSize {
+		return nil, NewStatusError(http.StatusRequestEntityTooLarge, fmt.Errorf("upload of %d bytes exceeds %d", r.ContentLength, p.maxUploadSize))
+	}
+
+	req, err := http.NewRequestWithContext(r.Context(), r.Method, url.String(), p.uploadBody(r, url))
 	if err != nil {
 		return nil, fmt.Errorf("new request: %w", err)
 	}
 
+	req.ContentLength = r.ContentLength
+
 	for h, val := range r.Header {
 		for _, v := range val {
 			req.Header.Add(h, v)
 		}
 	}
 
+	if len(p.experiments) > 0 {
+		req.Header.Del("X-Experiment")
+	}
+
+	if inExperiment {
+		req.Header.Set("X-Experiment", experiment.Name)
+	}
+
+	ctx := ContextWithRoute(req.Context(), ProxyRoute{
+		Path:       r.URL.Path,
+		Target:     url,
+		Experiment: experiment.Name,
+	})
+
+	req = req.WithContext(ContextWithLogger(ctx, p.Logger))
+
 	for _, modifier := range p.Modifiers {
 		if err := modifier(req); err != nil {
 			return nil, fmt.Errorf("modifier: %w", err)
@@ -118,60 +278,134 @@ func (p *proxyServer) NewRequest(r *http.Request) (*http.Request, error) {
 	return req, nil
 }
 
-func (p *proxyServer) Stream(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+func (p *proxyServer) uploadBody(r *http.Request, target *url.URL) io.ReadCloser {
 
-	flusher, ok := w.(http.Flusher)
-	if !ok {
-		http.Error(w, "Streaming not supported", http.StatusInternalServerError)
-		return
+	if r.Body == nil || r.Body == http.NoBody || r.ContentLength == 0 {
+		return http.NoBody
 	}
 
-	ctx := r.Context()
+	body := r.Body
+	if p.maxUploadSize > 0 {
+		body = http.MaxBytesReader(nil, body, p.maxUploadSize)
+	}
 
-	go func() {
-		buf := make([]byte, 8192)
-		for {
-			n, err := resp.Body.Read(buf)
-			if err != nil {
-				p.Logger.Errorf("read body: %v", err)
-				break
-			}
+	return &uploadReader{
+		ReadCloser: body,
+		metrics:    MetricsFromContext(r.Context()),
+		tags:       p.tags(target),
+	}
+}
 
-			if _, err := w.Write(buf[:n]); err != nil {
-				p.Logger.Errorf("write body: %v", err)
-				break
-			}
+type uploadReader struct {
+	io.ReadCloser
+	metrics Metrics
+	tags    map[string]string
+}
 
-			if ctx.Err() != nil {
-				break
-			}
-		}
-		p.Logger.Info("copy done")
-	}()
+func (u *uploadReader) Read(p []byte) (int, error) {
+	n, err := u.ReadCloser.Read(p)
+	if n > 0 {
+		u.metrics.Counter("upstream_upload_bytes_total", float64(n), u.tags)
+	}
+	return n, err
+}
 
-	for {
-		select {
-		case <-time.After(100 * time.Millisecond):
-			flusher.Flush()
-		case <-ctx.Done():
-			p.Logger.Info("context done")
-			return
+func (p *proxyServer) targetUrl(r *http.Request, target *url.URL) (*url.URL, error) {
+
+	if target == nil && p.selector == nil {
+		return p.Target.ResolveReference(r.URL), nil
+	}
+
+	if target == nil {
+		selected, err := p.selector(r)
+		if err != nil {
+			return nil, fmt.Errorf("select target : %w", err)
 		}
+		target = selected
 	}
-}
 
-func NewStatusError(statusCode int, err error) *statusError {
-	return &statusError{
-		StatusCode: statusCode,
-		Err:        err,
+	targetUrl := target.ResolveReference(r.URL)
+	if prefix := strings.TrimRight(target.Path, "/"); prefix != "" {
+		targetUrl.Path = prefix + r.URL.Path
+		targetUrl.RawPath = ""
 	}
+
+	return targetUrl, nil
 }
 
-type statusError struct {
-	StatusCode int
-	Err        error
+func (p *proxyServer) Health(ctx context.Context) error {
+
+	if p.Target == nil {
+		return errors.New("upstream health : no static target configured")
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodHead, p.Target.String(), nil)
+	if err != nil {
+		return fmt.Errorf("new request: %w", err)
+	}
+
+	resp, err := p.Client.Do(req)
+	if err != nil {
+		return fmt.Errorf("client do: %w", err)
+	}
+
+	resp.Body.Close()
+
+	if resp.StatusCode >= 500 {
+		return NewStatusError(resp.StatusCode, errors.New("upstream unhealthy"))
+	}
+
+	return nil
 }
 
-func (r *statusError) Error() string {
-	return fmt.Sprintf("status %d: err %v", r.StatusCode, r.Err)
+func (p *proxyServer) Stream(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+
+	ctx := r.Context()
+
+	stop := context.AfterFunc(ctx, func() {
+		resp.Body.Close()
+	})
+
+	defer stop()
+
+	controller := http.NewResponseController(w)
+	flush := true
+
+	if err := controller.SetWriteDeadline(time.Time{}); err != nil && !errors.Is(err, http.ErrNotSupported) {
+		p.Logger.Errorf("clear write deadline: %v", err)
+	}
+
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+
+	for {
+		n, err := resp.Body.Read(*buf)
+		if n > 0 {
+			if _, err := w.Write((*buf)[:n]); err != nil {
+				StreamClosed(ctx, StreamWriteError)
+				p.Logger.Errorf("write body: %v", err)
+				return
+			}
+
+			StreamTransferred(ctx, n)
+
+			if flush {
+				if err := controller.Flush(); errors.Is(err, http.ErrNotSupported) {
+					flush = false
+				} else if err != nil {
+					StreamClosed(ctx, StreamWriteError)
+					p.Logger.Errorf("flush: %v", err)
+					return
+				}
+			}
+		}
+
+		if err != nil {
+			if err != io.EOF && ctx.Err() == nil {
+				StreamClosed(ctx, StreamUpstreamError)
+				p.Logger.Errorf("read body: %v", err)
+			}
+			return
+		}
+	}
 }
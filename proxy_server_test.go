@@ -0,0 +1,330 @@
+package wx
+
+import (
+	"bufio"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func upgradeUpstream(t *testing.T) *url.URL {
+	t.Helper()
+
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		conn, brw, err := http.NewResponseController(w).Hijack()
+		if err != nil {
+			t.Error(err)
+			return
+		}
+		defer conn.Close()
+
+		fmt.Fprint(brw, "HTTP/1.1 101 Switching Protocols\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+		brw.Flush()
+
+		io.Copy(conn, brw)
+	}))
+	t.Cleanup(server.Close)
+
+	target, err := url.Parse(server.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return target
+}
+
+func dialUpgrade(t *testing.T, server *httptest.Server) (net.Conn, *http.Response) {
+	t.Helper()
+
+	conn, err := net.Dial("tcp", server.Listener.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { conn.Close() })
+
+	conn.SetDeadline(time.Now().Add(5 * time.Second))
+	fmt.Fprint(conn, "GET /tunnel HTTP/1.1\r\nHost: wx\r\nUpgrade: echo\r\nConnection: Upgrade\r\n\r\n")
+
+	resp, err := http.ReadResponse(bufio.NewReader(conn), nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	return conn, resp
+}
+
+func TestUpgradeClientStreamLimits(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		limits   ClientLimits
+		statuses []int
+	}{
+		{"one stream per ip", ClientLimits{MaxStreamsPerIP: 1}, []int{http.StatusSwitchingProtocols, http.StatusTooManyRequests}},
+		{"two streams per ip", ClientLimits{MaxStreamsPerIP: 2}, []int{http.StatusSwitchingProtocols, http.StatusSwitchingProtocols, http.StatusTooManyRequests}},
+		{"inflight slot released by stream", ClientLimits{MaxInflightPerIP: 1, MaxStreamsPerIP: 2}, []int{http.StatusSwitchingProtocols, http.StatusSwitchingProtocols}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			proxy := NewProxyServer(nopLogger{}, WithTarget(upgradeUpstream(t)))
+			limiter := NewClientLimiter(nopLogger{}, test.limits)
+
+			server := httptest.NewServer(limiter.Handler(http.HandlerFunc(proxy.Serve)))
+			defer server.Close()
+
+			for i, status := range test.statuses {
+				if _, resp := dialUpgrade(t, server); resp.StatusCode != status {
+					t.Fatalf("connection %d : expected %v, got %v", i, status, resp.StatusCode)
+				}
+			}
+		})
+	}
+}
+
+func TestStreamLimitsCheckedBeforeUpstream(t *testing.T) {
+
+	var hits atomic.Int64
+	done := make(chan struct{})
+
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		hits.Add(1)
+		w.Header().Set("Content-Type", "text/event-stream")
+		w.WriteHeader(http.StatusOK)
+		fmt.Fprint(w, ": connected\n\n")
+		w.(http.Flusher).Flush()
+
+		select {
+		case <-done:
+		case <-r.Context().Done():
+		}
+	}))
+	defer upstream.Close()
+
+	target, err := url.Parse(upstream.URL)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := NewProxyServer(nopLogger{}, WithTarget(target))
+	tracker := NewStreamTracker(nil, WithMaxStreams(1))
+
+	server := httptest.NewServer(tracker.Handler(http.HandlerFunc(proxy.Serve)))
+	defer server.Close()
+	defer close(done)
+
+	open := []io.Closer{}
+	defer func() {
+		for _, body := range open {
+			body.Close()
+		}
+	}()
+
+	tests := []struct {
+		name   string
+		accept string
+		status int
+		hits   int64
+	}{
+		{"first stream", "text/event-stream", http.StatusOK, 1},
+		{"stream over limit", "text/event-stream", http.StatusServiceUnavailable, 1},
+		{"stream over limit with params", "text/plain, text/event-stream;q=0.9", http.StatusServiceUnavailable, 1},
+		{"undeclared stream over limit", "", http.StatusServiceUnavailable, 2},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			req, err := http.NewRequest(http.MethodGet, server.URL+"/events", nil)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			if test.accept != "" {
+				req.Header.Set("Accept", test.accept)
+			}
+
+			resp, err := http.DefaultClient.Do(req)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			open = append(open, resp.Body)
+
+			if resp.StatusCode != test.status {
+				t.Fatalf("expected %v, got %v", test.status, resp.StatusCode)
+			}
+
+			if got := hits.Load(); got != test.hits {
+				t.Fatalf("expected %d upstream requests, got %d", test.hits, got)
+			}
+		})
+	}
+}
+
+func TestUploadStreaming(t *testing.T) {
+
+	tests := []struct {
+		name          string
+		contentLength int64
+		transfer      []string
+	}{
+		{"known length", 10, nil},
+		{"chunked", -1, []string{"chunked"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			received := make(chan struct{})
+
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if r.ContentLength != test.contentLength || fmt.Sprint(r.TransferEncoding) != fmt.Sprint(test.transfer) {
+					t.Errorf("expected length %d %v, got %d %v", test.contentLength, test.transfer, r.ContentLength, r.TransferEncoding)
+				}
+
+				first := make([]byte, 5)
+				if _, err := io.ReadFull(r.Body, first); err != nil {
+					t.Error(err)
+					return
+				}
+				close(received)
+
+				rest, _ := io.ReadAll(r.Body)
+				fmt.Fprintf(w, "%s%s", first, rest)
+			}))
+			defer upstream.Close()
+
+			target, err := url.Parse(upstream.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			server := httptest.NewServer(http.HandlerFunc(NewProxyServer(nopLogger{}, WithTarget(target)).Serve))
+			defer server.Close()
+
+			body, upload := io.Pipe()
+
+			req, err := http.NewRequest(http.MethodPost, server.URL+"/upload", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			req.ContentLength = test.contentLength
+
+			responses := make(chan *http.Response, 1)
+			go func() {
+				resp, err := http.DefaultClient.Do(req)
+				if err != nil {
+					t.Error(err)
+				}
+				responses <- resp
+			}()
+
+			fmt.Fprint(upload, "first")
+
+			select {
+			case <-received:
+			case <-time.After(5 * time.Second):
+				upload.Close()
+				t.Fatal("upstream did not receive the first chunk before the upload completed")
+			}
+
+			fmt.Fprint(upload, "-last")
+			upload.Close()
+
+			resp := <-responses
+			if resp == nil {
+				t.FailNow()
+			}
+			defer resp.Body.Close()
+
+			if data, _ := io.ReadAll(resp.Body); string(data) != "first-last" {
+				t.Fatalf("expected echoed upload, got %q", data)
+			}
+		})
+	}
+}
+
+func TestUploadLimits(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		size    int
+		chunked bool
+		max     int64
+		status  int
+		hits    int64
+	}{
+		{"within limit", 512, false, 1024, http.StatusOK, 1},
+		{"chunked within limit", 512, true, 1024, http.StatusOK, 1},
+		{"no limit", 4096, false, 0, http.StatusOK, 1},
+		{"declared over limit", 2048, false, 1024, http.StatusRequestEntityTooLarge, 0},
+		{"chunked over limit", 2048, true, 1024, http.StatusRequestEntityTooLarge, 1},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var hits atomic.Int64
+
+			upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				hits.Add(1)
+				io.Copy(io.Discard, r.Body)
+			}))
+			defer upstream.Close()
+
+			target, err := url.Parse(upstream.URL)
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			metrics := NewDashboardMetrics()
+			proxy := NewProxyServer(nopLogger{}, WithTarget(target), WithMaxUploadSize(test.max))
+
+			server := httptest.NewServer(NewWithMetrics(metrics, http.HandlerFunc(proxy.Serve)))
+			defer server.Close()
+
+			var body io.Reader = strings.NewReader(strings.Repeat("x", test.size))
+			if test.chunked {
+				body = io.MultiReader(body)
+			}
+
+			resp, err := http.Post(server.URL+"/upload", "application/octet-stream", body)
+			if err != nil {
+				t.Fatal(err)
+			}
+			resp.Body.Close()
+
+			if resp.StatusCode != test.status {
+				t.Fatalf("expected %v, got %v", test.status, resp.StatusCode)
+			}
+
+			if got := hits.Load(); got != test.hits {
+				t.Fatalf("expected %d upstream requests, got %d", test.hits, got)
+			}
+
+			if test.status != http.StatusOK {
+				return
+			}
+
+			uploaded := 0.0
+			for _, counter := range metrics.Snapshot().Counters {
+				if counter.Name == "upstream_upload_bytes_total" {
+					uploaded += counter.Value
+				}
+			}
+
+			if uploaded != float64(test.size) {
+				t.Fatalf("expected %d uploaded bytes, got %v", test.size, uploaded)
+			}
+		})
+	}
+}
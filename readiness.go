@@ -0,0 +1,84 @@
+package wx
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"slices"
+	"time"
+)
+
+type readinessOpt func(*readiness)
+
+func WithReadinessCheck(name string, check HealthCheck) readinessOpt {
+	return func(r *readiness) {
+		r.checks[name] = check
+	}
+}
+
+func WithReadinessInclude(names ...string) readinessOpt {
+	return func(r *readiness) {
+		r.include = append(r.include, names...)
+	}
+}
+
+func WithReadinessExclude(names ...string) readinessOpt {
+	return func(r *readiness) {
+		r.exclude = append(r.exclude, names...)
+	}
+}
+
+func WithReadinessTimeout(timeout time.Duration) readinessOpt {
+	return func(r *readiness) {
+		r.timeout = timeout
+	}
+}
+
+func NewReadiness(logger Logger, opts ...readinessOpt) *readiness {
+	readiness := &readiness{
+		Logger:  logger,
+		checks:  map[string]HealthCheck{},
+		timeout: 5 * time.Second,
+	}
+
+	for _, opt := range opts {
+		opt(readiness)
+	}
+
+	return readiness
+}
+
+type readiness struct {
+	Logger
+	checks  map[string]HealthCheck
+	include []string
+	exclude []string
+	timeout time.Duration
+}
+
+func (r *readiness) Ready(w http.ResponseWriter, req *http.Request) {
+
+	ctx, cancel := context.WithTimeout(req.Context(), r.timeout)
+	defer cancel()
+
+	checks := map[string]HealthCheck{}
+	for name, check := range r.checks {
+		if len(r.include) > 0 && !slices.Contains(r.include, name) {
+			continue
+		}
+		if slices.Contains(r.exclude, name) {
+			continue
+		}
+		checks[name] = check
+	}
+
+	status, results := runHealthChecks(ctx, checks)
+	if status != http.StatusOK {
+		r.Logger.Infof("not ready : %v", results)
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	json.NewEncoder(w).Encode(results)
+}
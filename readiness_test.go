@@ -0,0 +1,44 @@
+package wx
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestReadinessIgnoresRequestExclusions(t *testing.T) {
+
+	failing := func(ctx context.Context) error { return errors.New("down") }
+	healthy := func(ctx context.Context) error { return nil }
+
+	tests := []struct {
+		name   string
+		opts   []readinessOpt
+		query  string
+		status int
+	}{
+		{"failing check", nil, "", http.StatusServiceUnavailable},
+		{"failing check excluded by request", nil, "?exclude=upstream", http.StatusServiceUnavailable},
+		{"failing check excluded by config", []readinessOpt{WithReadinessExclude("upstream")}, "", http.StatusOK},
+		{"failing check not included by config", []readinessOpt{WithReadinessInclude("session_store")}, "", http.StatusOK},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			opts := append([]readinessOpt{
+				WithReadinessCheck("upstream", failing),
+				WithReadinessCheck("session_store", healthy),
+			}, test.opts...)
+
+			w := httptest.NewRecorder()
+			NewReadiness(nopLogger{}, opts...).Ready(w, httptest.NewRequest(http.MethodGet, "/readyz"+test.query, nil))
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,95 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func (a *authServer) Reauth(w http.ResponseWriter, r *http.Request) {
+	a.login(w, r, oauth2.SetAuthURLParam("prompt", "login"), oauth2.SetAuthURLParam("max_age", "0"))
+}
+
+func (a *authServer) authTimeCookieName(name string) string {
+	return name + "_auth_time"
+}
+
+func (a *authServer) setAuthTime(w http.ResponseWriter, name string, token *oauth2.Token, expiry time.Time) error {
+
+	var authTime int64
+
+	if idToken, ok := token.Extra("id_token").(string); ok {
+		if claims, err := a.claims(idToken); err == nil {
+			if value, ok := claims["auth_time"].(float64); ok {
+				authTime = int64(value)
+			}
+		}
+	}
+
+	if a.keyring == nil || authTime == 0 {
+		http.SetCookie(w, &http.Cookie{
+			Name:   a.authTimeCookieName(name),
+			Path:   "/",
+			MaxAge: -1,
+		})
+		return nil
+	}
+
+	sealed, err := a.keyring.Seal(strconv.FormatInt(authTime, 10))
+	if err != nil {
+		return fmt.Errorf("seal auth time : %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.authTimeCookieName(name),
+		Value:    sealed,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+	})
+
+	return nil
+}
+
+func (a *authServer) authTime(r *http.Request, claims map[string]interface{}) time.Time {
+
+	if cookie, err := r.Cookie(a.authTimeCookieName(a.sessionCookieName(r))); err == nil && a.keyring != nil {
+		value, err := a.keyring.Open(cookie.Value)
+		if err != nil {
+			a.Logger.Debug("open auth time : ", err)
+		} else if seconds, err := strconv.ParseInt(value, 10, 64); err == nil {
+			return time.Unix(seconds, 0)
+		}
+	}
+
+	if value, ok := claims["auth_time"].(float64); ok {
+		return time.Unix(int64(value), 0)
+	}
+
+	return time.Time{}
+}
+
+func RequireRecentAuth(reauthPath string, maxAge time.Duration) Middleware {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+
+			identity, ok := UserFromContext(r.Context())
+			if !ok {
+				ChallengeLogin(w, r, reauthPath, errMissingCredentials)
+				return
+			}
+
+			if time.Since(identity.AuthTime) > maxAge {
+				Audit(r, AuditAuthorizationDenied, map[string]string{"path": r.URL.Path, "reason": "stale authentication"})
+				w.Header().Set("WWW-Authenticate", BearerChallenge("insufficient_user_authentication", "max_age", strconv.Itoa(int(maxAge.Seconds()))))
+				ChallengeLogin(w, r, reauthPath, fmt.Errorf("%w: authentication older than %v", ErrUnauthorized, maxAge))
+				return
+			}
+
+			next.ServeHTTP(w, r)
+		})
+	}
+}
@@ -0,0 +1,118 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+func TestSetAuthTime(t *testing.T) {
+
+	ring := NewKeyring([]byte("cookie-key"))
+	recent := time.Now().Add(-time.Minute).Unix()
+
+	tests := []struct {
+		name     string
+		keyring  *keyring
+		authTime interface{}
+		set      bool
+	}{
+		{"auth time with keyring", ring, recent, true},
+		{"missing auth time", ring, nil, false},
+		{"auth time without keyring", nil, recent, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			a := &authServer{Logger: nopLogger{}, keyring: test.keyring}
+
+			claims := map[string]interface{}{"sub": "alice"}
+			if test.authTime != nil {
+				claims["auth_time"] = test.authTime
+			}
+
+			token := (&oauth2.Token{AccessToken: "token"}).WithExtra(map[string]interface{}{"id_token": unsignedToken(t, claims)})
+
+			w := httptest.NewRecorder()
+			if err := a.setAuthTime(w, "auth", token, time.Now().Add(time.Hour)); err != nil {
+				t.Fatalf("unexpected error : %v", err)
+			}
+
+			cookies := w.Result().Cookies()
+			if len(cookies) != 1 || cookies[0].Name != "auth_auth_time" {
+				t.Fatalf("expected auth time cookie, got %v", cookies)
+			}
+
+			if set := cookies[0].MaxAge >= 0; set != test.set {
+				t.Fatalf("expected set %v, got %v", test.set, cookies[0])
+			}
+
+			if test.set {
+				value, err := ring.Open(cookies[0].Value)
+				if err != nil || value != strconv.FormatInt(recent, 10) {
+					t.Fatalf("expected sealed auth time, got %v : %v", value, err)
+				}
+			}
+		})
+	}
+}
+
+func TestRequireRecentAuth(t *testing.T) {
+
+	ring := NewKeyring([]byte("cookie-key"))
+
+	seal := func(value string) string {
+		sealed, err := ring.Seal(value)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sealed
+	}
+
+	recent := strconv.FormatInt(time.Now().Add(-time.Minute).Unix(), 10)
+	stale := strconv.FormatInt(time.Now().Add(-time.Hour).Unix(), 10)
+
+	tests := []struct {
+		name    string
+		keyring *keyring
+		cookie  string
+		claims  map[string]interface{}
+		status  int
+	}{
+		{"sealed recent auth time", ring, seal(recent), nil, http.StatusOK},
+		{"sealed stale auth time", ring, seal(stale), nil, http.StatusUnauthorized},
+		{"forged auth time", ring, recent, nil, http.StatusUnauthorized},
+		{"forged auth time without keyring", nil, recent, nil, http.StatusUnauthorized},
+		{"missing auth time", ring, "", nil, http.StatusUnauthorized},
+		{"verified claim auth time", ring, "", map[string]interface{}{"auth_time": float64(time.Now().Unix())}, http.StatusOK},
+		{"forged cookie falls back to claims", ring, recent, map[string]interface{}{"auth_time": float64(time.Now().Add(-time.Hour).Unix())}, http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			a := &authServer{Logger: nopLogger{}, keyring: test.keyring, authCookieName: "auth"}
+
+			r := httptest.NewRequest(http.MethodPost, "/settings", nil)
+			r.Header.Set("Accept", "application/json")
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth_auth_time", Value: test.cookie})
+			}
+
+			identity := &Identity{Subject: "alice", AuthTime: a.authTime(r, test.claims)}
+			r = r.WithContext(ContextWithUser(r.Context(), identity))
+
+			w := httptest.NewRecorder()
+			RequireRecentAuth("/auth/reauth", 10*time.Minute)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {})).ServeHTTP(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v", test.status, w.Code)
+			}
+		})
+	}
+}
@@ -0,0 +1,50 @@
+package wx
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+)
+
+const contextKeyRequestID contextKey = "request_id"
+
+func NewWithRequestID(handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requestID := r.Header.Get("X-Request-ID")
+		if !validRequestID(requestID) {
+			requestID = newRequestID()
+			r.Header.Set("X-Request-ID", requestID)
+		}
+
+		w.Header().Set("X-Request-ID", requestID)
+
+		ctx := context.WithValue(r.Context(), contextKeyRequestID, requestID)
+		handler.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func RequestID(ctx context.Context) string {
+	requestID, _ := ctx.Value(contextKeyRequestID).(string)
+	return requestID
+}
+
+func newRequestID() string {
+	bytes := make([]byte, 16)
+	rand.Read(bytes)
+	return hex.EncodeToString(bytes)
+}
+
+func validRequestID(requestID string) bool {
+	if requestID == "" || len(requestID) > 128 {
+		return false
+	}
+
+	for _, c := range requestID {
+		if c < '!' || c > '~' {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,184 @@
+package wx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"os"
+	"os/exec"
+	"strconv"
+	"strings"
+)
+
+const (
+	envListenFDs = "WX_LISTEN_FDS"
+	envReadyFD   = "WX_READY_FD"
+)
+
+type filer interface {
+	File() (*os.File, error)
+}
+
+func listen(addrs []string) ([]net.Listener, bool, error) {
+
+	value := os.Getenv(envListenFDs)
+	if value == "" {
+		listeners := []net.Listener{}
+		for _, addr := range addrs {
+			listener, err := net.Listen("tcp", addr)
+			if err != nil {
+				closeListeners(listeners)
+				return nil, false, fmt.Errorf("listen [%v] : %w", addr, err)
+			}
+			listeners = append(listeners, listener)
+		}
+		return listeners, false, nil
+	}
+
+	os.Unsetenv(envListenFDs)
+
+	fds := strings.Split(value, ",")
+	if len(fds) != len(addrs) {
+		return nil, false, fmt.Errorf("inherited %d listeners, configured %d", len(fds), len(addrs))
+	}
+
+	listeners := []net.Listener{}
+	for _, value := range fds {
+		listener, err := inherit(value)
+		if err != nil {
+			closeListeners(listeners)
+			return nil, false, err
+		}
+		listeners = append(listeners, listener)
+	}
+
+	return listeners, true, nil
+}
+
+func inherit(value string) (net.Listener, error) {
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return nil, fmt.Errorf("parse %v : %w", envListenFDs, err)
+	}
+
+	file := os.NewFile(uintptr(fd), "listener")
+	defer file.Close()
+
+	listener, err := net.FileListener(file)
+	if err != nil {
+		return nil, fmt.Errorf("inherit listener : %w", err)
+	}
+
+	return listener, nil
+}
+
+func closeListeners(listeners []net.Listener) {
+	for _, listener := range listeners {
+		listener.Close()
+	}
+}
+
+func notifyReady() error {
+
+	value := os.Getenv(envReadyFD)
+	if value == "" {
+		return nil
+	}
+
+	os.Unsetenv(envReadyFD)
+
+	fd, err := strconv.Atoi(value)
+	if err != nil {
+		return fmt.Errorf("parse %v : %w", envReadyFD, err)
+	}
+
+	file := os.NewFile(uintptr(fd), "ready")
+	defer file.Close()
+
+	_, err = file.Write([]byte{1})
+	return err
+}
+
+func handoff(ctx context.Context, listeners []net.Listener) (int, error) {
+
+	files := []*os.File{}
+	fds := []string{}
+
+	defer func() {
+		for _, file := range files {
+			file.Close()
+		}
+	}()
+
+	for _, listener := range listeners {
+		l, ok := listener.(filer)
+		if !ok {
+			return 0, fmt.Errorf("listener [%v] does not expose a file descriptor", listener.Addr())
+		}
+
+		file, err := l.File()
+		if err != nil {
+			return 0, fmt.Errorf("listener file [%v] : %w", listener.Addr(), err)
+		}
+
+		files = append(files, file)
+		fds = append(fds, strconv.Itoa(2+len(files)))
+	}
+
+	executable, err := os.Executable()
+	if err != nil {
+		return 0, fmt.Errorf("executable : %w", err)
+	}
+
+	ready, notify, err := os.Pipe()
+	if err != nil {
+		return 0, fmt.Errorf("pipe : %w", err)
+	}
+
+	defer ready.Close()
+
+	cmd := exec.Command(executable, os.Args[1:]...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+	cmd.ExtraFiles = append(files, notify)
+	cmd.Env = append(os.Environ(), envListenFDs+"="+strings.Join(fds, ","), envReadyFD+"="+strconv.Itoa(3+len(files)))
+
+	err = cmd.Start()
+	notify.Close()
+
+	if err != nil {
+		return 0, fmt.Errorf("start : %w", err)
+	}
+
+	pid := cmd.Process.Pid
+
+	started := make(chan error, 1)
+	go func() {
+		_, err := ready.Read(make([]byte, 1))
+		if errors.Is(err, io.EOF) {
+			err = errors.New("exited before becoming ready")
+		}
+		started <- err
+	}()
+
+	select {
+	case err := <-started:
+		if err != nil {
+			cmd.Process.Kill()
+			cmd.Wait()
+			return pid, fmt.Errorf("process %d : %w", pid, err)
+		}
+	case <-ctx.Done():
+		cmd.Process.Kill()
+		cmd.Wait()
+		return pid, fmt.Errorf("process %d : %w", pid, ctx.Err())
+	}
+
+	cmd.Process.Release()
+
+	return pid, nil
+}
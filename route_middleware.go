@@ -0,0 +1,42 @@
+package wx
+
+import "net/http"
+
+type routeMiddleware struct {
+	pattern    string
+	middleware []Middleware
+}
+
+func RouteMiddleware(pattern string, middleware ...Middleware) routeMiddleware {
+	return routeMiddleware{pattern, middleware}
+}
+
+func NewRouteMiddleware(routes ...routeMiddleware) Middleware {
+	return func(next http.Handler) http.Handler {
+
+		chains := make([]http.Handler, len(routes))
+
+		var dispatch func(i int) http.Handler
+		dispatch = func(i int) http.Handler {
+			return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				for j := i; j < len(routes); j++ {
+					if matchPath(routes[j].pattern, r.URL.Path) {
+						chains[j].ServeHTTP(w, r)
+						return
+					}
+				}
+				next.ServeHTTP(w, r)
+			})
+		}
+
+		for i := len(routes) - 1; i >= 0; i-- {
+			var handler http.Handler = dispatch(i + 1)
+			for j := len(routes[i].middleware) - 1; j >= 0; j-- {
+				handler = routes[i].middleware[j](handler)
+			}
+			chains[i] = handler
+		}
+
+		return dispatch(0)
+	}
+}
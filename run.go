@@ -0,0 +1,280 @@
+package wx
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+)
+
+const restartTimeout = 30 * time.Second
+
+type runOpt func(*runConfig)
+
+func WithDrainTimeout(timeout time.Duration) runOpt {
+	return func(c *runConfig) {
+		c.drainTimeout = timeout
+	}
+}
+
+func WithRunMetrics(metrics Metrics) runOpt {
+	return func(c *runConfig) {
+		c.metrics = metrics
+	}
+}
+
+func WithReadHeaderTimeout(timeout time.Duration) runOpt {
+	return func(c *runConfig) {
+		c.readHeaderTimeout = timeout
+	}
+}
+
+func WithReadTimeout(timeout time.Duration) runOpt {
+	return func(c *runConfig) {
+		c.readTimeout = timeout
+	}
+}
+
+func WithWriteTimeout(timeout time.Duration) runOpt {
+	return func(c *runConfig) {
+		c.writeTimeout = timeout
+	}
+}
+
+func WithIdleTimeout(timeout time.Duration) runOpt {
+	return func(c *runConfig) {
+		c.idleTimeout = timeout
+	}
+}
+
+func WithMaxHeaderBytes(size int) runOpt {
+	return func(c *runConfig) {
+		c.maxHeaderBytes = size
+	}
+}
+
+func WithStreamLimits(opts ...streamOpt) runOpt {
+	return func(c *runConfig) {
+		c.streamOpts = append(c.streamOpts, opts...)
+	}
+}
+
+func WithStreamSummary(interval time.Duration) runOpt {
+	return func(c *runConfig) {
+		c.streamSummary = interval
+	}
+}
+
+func WithRestartSignal(sig os.Signal) runOpt {
+	return func(c *runConfig) {
+		c.restartSignal = sig
+	}
+}
+
+type Listener struct {
+	Name         string
+	Addr         string
+	Handler      http.Handler
+	TLSConfig    *tls.Config
+	DrainTimeout time.Duration
+}
+
+func WithListener(listener Listener) runOpt {
+	return func(c *runConfig) {
+		c.listeners = append(c.listeners, listener)
+	}
+}
+
+func WithTLS(config *tls.Config) runOpt {
+	return func(c *runConfig) {
+		c.tlsConfig = config
+	}
+}
+
+type runConfig struct {
+	drainTimeout      time.Duration
+	streamOpts        []streamOpt
+	streamSummary     time.Duration
+	restartSignal     os.Signal
+	listeners         []Listener
+	tlsConfig         *tls.Config
+	metrics           Metrics
+	readHeaderTimeout time.Duration
+	readTimeout       time.Duration
+	writeTimeout      time.Duration
+	idleTimeout       time.Duration
+	maxHeaderBytes    int
+}
+
+func Run(ctx context.Context, logger Logger, addr string, handler http.Handler, opts ...runOpt) error {
+
+	config := &runConfig{
+		drainTimeout:      30 * time.Second,
+		readHeaderTimeout: 10 * time.Second,
+		idleTimeout:       2 * time.Minute,
+	}
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	streams := NewStreamTracker(config.metrics, config.streamOpts...)
+
+	listeners := append([]Listener{{
+		Name:         "main",
+		Addr:         addr,
+		Handler:      handler,
+		TLSConfig:    config.tlsConfig,
+		DrainTimeout: config.drainTimeout,
+	}}, config.listeners...)
+
+	addrs := []string{}
+	for _, l := range listeners {
+		addrs = append(addrs, l.Addr)
+	}
+
+	sockets, inherited, err := listen(addrs)
+	if err != nil {
+		return fmt.Errorf("listen : %w", err)
+	}
+
+	servers := []*http.Server{}
+	errs := make(chan error, len(listeners))
+
+	for i, l := range listeners {
+		server := &http.Server{
+			Addr:              l.Addr,
+			Handler:           streams.Handler(l.Handler),
+			ReadHeaderTimeout: config.readHeaderTimeout,
+			ReadTimeout:       config.readTimeout,
+			WriteTimeout:      config.writeTimeout,
+			IdleTimeout:       config.idleTimeout,
+			MaxHeaderBytes:    config.maxHeaderBytes,
+			TLSConfig:         l.TLSConfig,
+		}
+
+		socket := sockets[i]
+		if l.TLSConfig != nil {
+			socket = tls.NewListener(socket, l.TLSConfig)
+		}
+
+		go func(name string) {
+			if err := server.Serve(socket); !errors.Is(err, http.ErrServerClosed) {
+				errs <- fmt.Errorf("%v : %w", name, err)
+				return
+			}
+			errs <- nil
+		}(l.Name)
+
+		servers = append(servers, server)
+
+		if inherited {
+			logger.Infof("listening on %v [%v] (inherited)", socket.Addr(), l.Name)
+		} else {
+			logger.Infof("listening on %v [%v]", socket.Addr(), l.Name)
+		}
+	}
+
+	if err := notifyReady(); err != nil {
+		logger.Errorf("notify ready : %v", err)
+	}
+
+	restarts := make(chan os.Signal, 1)
+	if config.restartSignal != nil {
+		signal.Notify(restarts, config.restartSignal)
+		defer signal.Stop(restarts)
+	}
+
+	if config.streamSummary > 0 {
+		go func() {
+			ticker := time.NewTicker(config.streamSummary)
+			defer ticker.Stop()
+
+			for {
+				select {
+				case <-ticker.C:
+					streams.Report(logger)
+				case <-ctx.Done():
+					return
+				}
+			}
+		}()
+	}
+
+	for done := false; !done; {
+		select {
+		case err := <-errs:
+			for _, server := range servers {
+				server.Close()
+			}
+			return fmt.Errorf("listen and serve : %w", err)
+		case <-ctx.Done():
+			done = true
+		case <-restarts:
+			restartCtx, cancel := context.WithTimeout(ctx, restartTimeout)
+			pid, err := handoff(restartCtx, sockets)
+			cancel()
+
+			if err != nil {
+				logger.Errorf("restart : %v", err)
+				continue
+			}
+
+			logger.Infof("handed off listeners to process %d", pid)
+			done = true
+		}
+	}
+
+	logger.Infof("draining %d streams", streams.Active())
+
+	drainCtx, cancel := context.WithTimeout(context.Background(), config.drainTimeout)
+	defer cancel()
+
+	go func() {
+		if err := streams.Drain(drainCtx); err != nil {
+			logger.Errorf("drain streams : %v", err)
+		}
+	}()
+
+	var wg sync.WaitGroup
+
+	for i, server := range servers {
+		wg.Add(1)
+		go func(l Listener, server *http.Server) {
+			defer wg.Done()
+
+			timeout := l.DrainTimeout
+			if timeout <= 0 {
+				timeout = config.drainTimeout
+			}
+
+			ctx, cancel := context.WithTimeout(context.Background(), timeout)
+			defer cancel()
+
+			if err := server.Shutdown(ctx); err != nil {
+				logger.Errorf("shutdown [%v] : %v", l.Name, err)
+				server.Close()
+			}
+		}(listeners[i], server)
+	}
+
+	wg.Wait()
+
+	failed := []error{}
+	for range servers {
+		if err := <-errs; err != nil {
+			failed = append(failed, err)
+		}
+	}
+
+	if len(failed) > 0 {
+		return fmt.Errorf("listen and serve : %w", errors.Join(failed...))
+	}
+
+	return nil
+}
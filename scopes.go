@@ -0,0 +1,100 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func WithScopeAllowlist(scopes ...string) authOpt {
+	return func(a *authServer) {
+		a.scopeAllowlist = append(a.scopeAllowlist, scopes...)
+	}
+}
+
+func (i *Identity) HasScope(scope string) bool {
+	for _, s := range i.Scopes {
+		if s == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *authServer) requestedScopes(r *http.Request) ([]string, error) {
+
+	scopes := []string{}
+
+	for _, scope := range strings.Split(r.FormValue("scopes"), ",") {
+		if scope = strings.TrimSpace(scope); scope == "" {
+			continue
+		}
+
+		if !a.scopeAllowed(scope) {
+			return nil, fmt.Errorf("%w: scope %q not allowed", ErrBadRequest, scope)
+		}
+
+		scopes = append(scopes, scope)
+	}
+
+	return scopes, nil
+}
+
+func (a *authServer) scopeAllowed(scope string) bool {
+	for _, allowed := range a.scopeAllowlist {
+		if allowed == scope {
+			return true
+		}
+	}
+	return false
+}
+
+func (a *authServer) scopesCookieName(name string) string {
+	return name + "_scopes"
+}
+
+func (a *authServer) setScopes(w http.ResponseWriter, name string, scopes []string, expiry time.Time) error {
+
+	if a.keyring == nil {
+		http.SetCookie(w, &http.Cookie{
+			Name:   a.scopesCookieName(name),
+			Path:   "/",
+			MaxAge: -1,
+		})
+		return nil
+	}
+
+	sealed, err := a.keyring.Seal(strings.Join(scopes, " "))
+	if err != nil {
+		return fmt.Errorf("seal scopes : %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.scopesCookieName(name),
+		Value:    sealed,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+	})
+
+	return nil
+}
+
+func (a *authServer) scopes(r *http.Request, claims map[string]interface{}) []string {
+
+	if cookie, err := r.Cookie(a.scopesCookieName(a.sessionCookieName(r))); err == nil && a.keyring != nil {
+		value, err := a.keyring.Open(cookie.Value)
+		if err != nil {
+			a.Logger.Debug("open scopes : ", err)
+		} else if scopes := strings.Fields(value); len(scopes) > 0 {
+			return scopes
+		}
+	}
+
+	if scope, ok := claims["scope"].(string); ok {
+		return strings.Fields(scope)
+	}
+
+	return claimValues(claims, "scp")
+}
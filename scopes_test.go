@@ -0,0 +1,71 @@
+package wx
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+)
+
+func TestScopesCookie(t *testing.T) {
+
+	ring := NewKeyring([]byte("cookie-key"))
+
+	sealed, err := ring.Seal("openid billing:write")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	claims := map[string]interface{}{"scope": "openid profile"}
+
+	tests := []struct {
+		name    string
+		keyring *keyring
+		cookie  string
+		scopes  string
+	}{
+		{"sealed cookie", ring, sealed, "openid billing:write"},
+		{"forged cookie", ring, "openid+billing:write", "openid profile"},
+		{"forged cookie without keyring", nil, "openid+billing:write", "openid profile"},
+		{"no cookie", ring, "", "openid profile"},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			a := &authServer{Logger: nopLogger{}, keyring: test.keyring, authCookieName: "auth"}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth_scopes", Value: test.cookie})
+			}
+
+			if scopes := strings.Join(a.scopes(r, claims), " "); scopes != test.scopes {
+				t.Fatalf("expected %q, got %q", test.scopes, scopes)
+			}
+		})
+	}
+}
+
+func TestValidateRequiresKeyringForScopes(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		opts  []authOpt
+		fails bool
+	}{
+		{"allowlist with keyring", []authOpt{WithScopeAllowlist("billing:write"), WithCookieKeyring(NewKeyring([]byte("cookie-key")))}, false},
+		{"allowlist without keyring", []authOpt{WithScopeAllowlist("billing:write"), WithJWKS("https://idp.example.com/jwks", "https://idp.example.com", "client", 0)}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			err := Validate(nil, oauth2Config(), WithAuthOptions(test.opts...))
+
+			if failed := containsError(err, "auth : scope allowlist"); failed != test.fails {
+				t.Fatalf("expected failure %v, got %v", test.fails, err)
+			}
+		})
+	}
+}
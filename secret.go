@@ -0,0 +1,125 @@
+package wx
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"sync"
+	"time"
+)
+
+type SecretSource interface {
+	Secret(ctx context.Context) (string, error)
+}
+
+type SecretFunc func(ctx context.Context) (string, error)
+
+func (f SecretFunc) Secret(ctx context.Context) (string, error) {
+	return f(ctx)
+}
+
+func NewFileSecret(path string) SecretSource {
+	return SecretFunc(func(ctx context.Context) (string, error) {
+		secret, err := os.ReadFile(path)
+		if err != nil {
+			return "", fmt.Errorf("read secret [%s] : %w", path, err)
+		}
+		return strings.TrimSpace(string(secret)), nil
+	})
+}
+
+func NewEnvSecret(name string) SecretSource {
+	return SecretFunc(func(ctx context.Context) (string, error) {
+		secret, ok := os.LookupEnv(name)
+		if !ok {
+			return "", fmt.Errorf("secret env [%s] not set", name)
+		}
+		return secret, nil
+	})
+}
+
+func NewVaultSecret(client *http.Client, addr string, token string, path string, key string) SecretSource {
+	return SecretFunc(func(ctx context.Context) (string, error) {
+
+		url := strings.TrimRight(addr, "/") + "/v1/" + strings.TrimLeft(path, "/")
+
+		req, err := http.NewRequestWithContext(ctx, http.MethodGet, url, nil)
+		if err != nil {
+			return "", fmt.Errorf("new request : %w", err)
+		}
+
+		req.Header.Set("X-Vault-Token", token)
+
+		resp, err := client.Do(req)
+		if err != nil {
+			return "", fmt.Errorf("vault [%s] : %w", path, err)
+		}
+
+		defer resp.Body.Close()
+
+		if resp.StatusCode != http.StatusOK {
+			return "", NewStatusError(resp.StatusCode, fmt.Errorf("vault [%s]", path))
+		}
+
+		var body struct {
+			Data struct {
+				Data map[string]string `json:"data"`
+			} `json:"data"`
+		}
+
+		if err = json.NewDecoder(resp.Body).Decode(&body); err != nil {
+			return "", fmt.Errorf("decode vault [%s] : %w", path, err)
+		}
+
+		secret, ok := body.Data.Data[key]
+		if !ok {
+			return "", fmt.Errorf("vault [%s] missing key [%s]", path, key)
+		}
+
+		return secret, nil
+	})
+}
+
+func NewCachedSecret(logger Logger, source SecretSource, ttl time.Duration) *cachedSecret {
+	return &cachedSecret{
+		Logger:       logger,
+		SecretSource: source,
+		Duration:     ttl,
+	}
+}
+
+type cachedSecret struct {
+	Logger
+	SecretSource
+	time.Duration
+
+	mutex  sync.Mutex
+	secret string
+	loaded time.Time
+}
+
+func (c *cachedSecret) Secret(ctx context.Context) (string, error) {
+	c.mutex.Lock()
+	defer c.mutex.Unlock()
+
+	if !c.loaded.IsZero() && time.Since(c.loaded) < c.Duration {
+		return c.secret, nil
+	}
+
+	secret, err := c.SecretSource.Secret(ctx)
+	if err != nil {
+		if c.loaded.IsZero() {
+			return "", err
+		}
+
+		c.Logger.Errorf("reload secret : %v", err)
+		ReportError(ctx, NewStatusError(http.StatusInternalServerError, err), map[string]string{"component": "secret"})
+		return c.secret, nil
+	}
+
+	c.secret, c.loaded = secret, time.Now()
+	return c.secret, nil
+}
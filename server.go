@@ -1,9 +1,11 @@
 package wx
 
 import (
+	"fmt"
 	"net/http"
 	"net/url"
 	"strings"
+	"time"
 
 	"golang.org/x/oauth2"
 )
@@ -16,42 +18,590 @@ type Logger interface {
 	Debug(a ...interface{})
 }
 
+type nopLogger struct{}
+
+func (nopLogger) Error(a ...interface{})              {}
+func (nopLogger) Errorf(fmt string, a ...interface{}) {}
+func (nopLogger) Info(a ...interface{})               {}
+func (nopLogger) Infof(fmt string, a ...interface{})  {}
+func (nopLogger) Debug(a ...interface{})              {}
+
+type Middleware func(http.Handler) http.Handler
+
+type serverOpt func(*serverConfig)
+
+func WithAuthOptions(opts ...authOpt) serverOpt {
+	return func(c *serverConfig) {
+		c.authOpts = append(c.authOpts, opts...)
+	}
+}
+
+func WithProxyOptions(opts ...proxyOpt) serverOpt {
+	return func(c *serverConfig) {
+		c.proxyOpts = append(c.proxyOpts, opts...)
+	}
+}
+
+func WithAuthPath(path string) serverOpt {
+	return func(c *serverConfig) {
+		c.authPath = strings.TrimRight(path, "/")
+	}
+}
+
+func WithProxyPath(path string) serverOpt {
+	return func(c *serverConfig) {
+		c.proxyPath = strings.TrimRight(path, "/") + "/"
+	}
+}
+
+func WithRoute(pattern string, handler http.Handler) serverOpt {
+	return func(c *serverConfig) {
+		c.routes = append(c.routes, route{pattern, handler})
+	}
+}
+
+func WithMiddleware(middleware ...Middleware) serverOpt {
+	return func(c *serverConfig) {
+		c.middleware = append(c.middleware, middleware...)
+	}
+}
+
+func WithRouteMiddleware(pattern string, middleware ...Middleware) serverOpt {
+	return func(c *serverConfig) {
+		c.routeMiddleware = append(c.routeMiddleware, RouteMiddleware(pattern, middleware...))
+	}
+}
+
+func WithLogger(logger Logger) serverOpt {
+	return func(c *serverConfig) {
+		c.logger = logger
+	}
+}
+
+func WithErrorRenderer(renderer *errorRenderer) serverOpt {
+	return func(c *serverConfig) {
+		c.errorRenderer = renderer
+	}
+}
+
+func WithAuditSinks(sinks ...AuditSink) serverOpt {
+	return func(c *serverConfig) {
+		c.auditSinks = append(c.auditSinks, sinks...)
+	}
+}
+
+func WithTrustedProxies(depth int) serverOpt {
+	return func(c *serverConfig) {
+		c.trustedProxies = depth
+	}
+}
+
+func WithIPRules(rules ...IPRule) serverOpt {
+	return func(c *serverConfig) {
+		c.ipRules = append(c.ipRules, rules...)
+	}
+}
+
+func WithGeoIP(lookup CountryLookup, rules ...GeoRule) serverOpt {
+	return func(c *serverConfig) {
+		c.countryLookup = lookup
+		c.geoRules = append(c.geoRules, rules...)
+	}
+}
+
+func WithSlowRequestLog(thresholds SlowRequestThresholds) serverOpt {
+	return func(c *serverConfig) {
+		c.slowRequests = &thresholds
+	}
+}
+
+func WithCSP(policy string) serverOpt {
+	return func(c *serverConfig) {
+		c.cspPolicy = policy
+	}
+}
+
+func WithAuthLimits(limits AuthLimits) serverOpt {
+	return func(c *serverConfig) {
+		c.authLimits = &limits
+	}
+}
+
+func WithLoadShedding(config LoadShedding) serverOpt {
+	return func(c *serverConfig) {
+		c.loadShedding = &config
+	}
+}
+
+func WithClientLimits(limits ClientLimits) serverOpt {
+	return func(c *serverConfig) {
+		c.clientLimits = &limits
+	}
+}
+
+func WithErrorReporter(reporter ErrorReporter) serverOpt {
+	return func(c *serverConfig) {
+		c.errorReporter = reporter
+	}
+}
+
+func WithMetrics(metrics Metrics) serverOpt {
+	return func(c *serverConfig) {
+		c.metrics = metrics
+	}
+}
+
+func WithIdPHealthCheck(jwksURL string, interval time.Duration, maxAge time.Duration) serverOpt {
+	return func(c *serverConfig) {
+		c.jwksURL = jwksURL
+		c.idpCheckInterval = interval
+		c.idpKeysMaxAge = maxAge
+	}
+}
+
+func WithAuthorizer(authorizer Authorizer) serverOpt {
+	return func(c *serverConfig) {
+		c.authorizer = authorizer
+	}
+}
+
+func WithTokenMinter(minter *tokenMinter) serverOpt {
+	return func(c *serverConfig) {
+		c.minter = minter
+	}
+}
+
+func WithCachePool(pool *cachePool) serverOpt {
+	return func(c *serverConfig) {
+		c.cachePool = pool
+	}
+}
+
+func WithImpersonation(role string, ttl time.Duration, keyring *keyring, roles ...string) serverOpt {
+	return func(c *serverConfig) {
+		c.impersonationRole = role
+		c.impersonationTTL = ttl
+		c.impersonationKeyring = keyring
+		c.impersonationRoles = roles
+	}
+}
+
+func WithDebug(role string) serverOpt {
+	return func(c *serverConfig) {
+		c.debug = true
+		c.debugRole = role
+	}
+}
+
+func WithAdminDashboard(metrics *dashboardMetrics) serverOpt {
+	return func(c *serverConfig) {
+		c.dashboard = metrics
+	}
+}
+
+func WithAdmin(role string, opts ...adminOpt) serverOpt {
+	return func(c *serverConfig) {
+		c.admin = true
+		c.adminRole = role
+		c.adminOpts = append(c.adminOpts, opts...)
+	}
+}
+
+func WithReadiness(opts ...readinessOpt) serverOpt {
+	return func(c *serverConfig) {
+		c.readiness = true
+		c.readinessOpts = append(c.readinessOpts, opts...)
+	}
+}
+
+func WithVersion(servedBy bool) serverOpt {
+	return func(c *serverConfig) {
+		c.version = true
+		c.servedBy = servedBy
+	}
+}
+
+func newServerConfig(opts ...serverOpt) *serverConfig {
+	config := &serverConfig{
+		logger:        nopLogger{},
+		authPath:      "/auth",
+		errorRenderer: defaultErrorRenderer,
+	}
+
+	for _, opt := range opts {
+		opt(config)
+	}
+
+	return config
+}
+
+type serverConfig struct {
+	logger               Logger
+	authOpts             []authOpt
+	proxyOpts            []proxyOpt
+	authPath             string
+	proxyPath            string
+	routes               []route
+	middleware           []Middleware
+	routeMiddleware      []routeMiddleware
+	debug                bool
+	errorRenderer        *errorRenderer
+	auditSinks           []AuditSink
+	trustedProxies       int
+	ipRules              []IPRule
+	countryLookup        CountryLookup
+	geoRules             []GeoRule
+	slowRequests         *SlowRequestThresholds
+	debugRole            string
+	admin                bool
+	adminRole            string
+	adminOpts            []adminOpt
+	dashboard            *dashboardMetrics
+	cspPolicy            string
+	authLimits           *AuthLimits
+	loadShedding         *LoadShedding
+	clientLimits         *ClientLimits
+	errorReporter        ErrorReporter
+	metrics              Metrics
+	jwksURL              string
+	idpCheckInterval     time.Duration
+	idpKeysMaxAge        time.Duration
+	authorizer           Authorizer
+	minter               *tokenMinter
+	cachePool            *cachePool
+	impersonationRole    string
+	impersonationTTL     time.Duration
+	impersonationKeyring *keyring
+	impersonationRoles   []string
+	readiness            bool
+	readinessOpts        []readinessOpt
+	version              bool
+	servedBy             bool
+}
+
+func (c *serverConfig) features() []string {
+
+	features := []string{}
+
+	enabled := []struct {
+		name string
+		on   bool
+	}{
+		{"admin", c.admin},
+		{"audit", len(c.auditSinks) > 0},
+		{"auth_limits", c.authLimits != nil},
+		{"authorizer", c.authorizer != nil},
+		{"cache_pool", c.cachePool != nil},
+		{"client_limits", c.clientLimits != nil},
+		{"csp", c.cspPolicy != ""},
+		{"debug", c.debug},
+		{"error_reporter", c.errorReporter != nil},
+		{"geoip", c.countryLookup != nil},
+		{"impersonation", c.impersonationKeyring != nil},
+		{"ip_filter", len(c.ipRules) > 0},
+		{"load_shedding", c.loadShedding != nil},
+		{"metrics", c.metrics != nil},
+		{"slow_request_log", c.slowRequests != nil},
+		{"token_minter", c.minter != nil},
+	}
+
+	for _, feature := range enabled {
+		if feature.on {
+			features = append(features, feature.name)
+		}
+	}
+
+	return features
+}
+
+type route struct {
+	pattern string
+	handler http.Handler
+}
+
+// Deprecated: use NewWebServerE, which returns configuration errors instead of logging them.
 func NewWebServer(
 	logger Logger,
 	target *url.URL,
 	config oauth2.Config,
 	handler http.Handler,
+	opts ...serverOpt,
 ) http.Handler {
 
+	if err := Validate(target, config, opts...); err != nil {
+		for _, err := range strings.Split(err.Error(), "\n") {
+			logger.Errorf("invalid configuration : %v", err)
+		}
+	}
+
+	server, err := newWebServer(logger, target, config, handler, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return server
+}
+
+func NewWebServerE(
+	logger Logger,
+	target *url.URL,
+	config oauth2.Config,
+	handler http.Handler,
+	opts ...serverOpt,
+) (http.Handler, error) {
+
+	if err := Validate(target, config, opts...); err != nil {
+		return nil, fmt.Errorf("invalid configuration : %w", err)
+	}
+
+	return newWebServer(logger, target, config, handler, opts...)
+}
+
+func newWebServer(
+	logger Logger,
+	target *url.URL,
+	config oauth2.Config,
+	handler http.Handler,
+	opts ...serverOpt,
+) (http.Handler, error) {
+
+	serverConfig := newServerConfig(opts...)
+
+	if err := validateRedirectPath(config, serverConfig); err != nil {
+		logger.Infof("configuration warning : %v", err)
+	}
+
 	authServer := NewAuthServer(
 		logger,
-		WithOAuthConfig(config),
+		append([]authOpt{WithOAuthConfig(config), WithLoginPath(serverConfig.authPath + "/login")}, serverConfig.authOpts...)...,
 	)
 
 	proxyServer := NewProxyServer(
 		logger,
-		WithTarget(target),
-		WithModifier(authServer.ModifyHeader),
+		append(proxyModifiers(target, authServer, serverConfig), serverConfig.proxyOpts...)...,
 	)
 
-	proxyPath := strings.TrimRight(target.Path, "/") + "/"
+	proxyPath := serverConfig.proxyPath
+	if proxyPath == "" {
+		proxyPath = strings.TrimRight(target.Path, "/") + "/"
+	}
 
-	return New(authServer, proxyServer, proxyPath, handler)
+	if serverConfig.idpCheckInterval > 0 {
+		idpHealth := NewIdPHealthCheck(logger, http.DefaultClient, config.Endpoint.TokenURL, serverConfig.jwksURL, serverConfig.idpCheckInterval, serverConfig.idpKeysMaxAge)
+
+		if serverConfig.admin {
+			opts = append(opts, WithAdmin(serverConfig.adminRole, WithHealthCheck("idp", idpHealth.Check)))
+		}
+
+		if serverConfig.readiness {
+			opts = append(opts, WithReadiness(WithReadinessCheck("idp", idpHealth.Check)))
+		}
+	}
+
+	return NewE(authServer, proxyServer, proxyPath, handler, append([]serverOpt{WithLogger(logger)}, opts...)...)
+}
+
+func proxyModifiers(target *url.URL, authServer AuthServer, config *serverConfig) []proxyOpt {
+
+	opts := []proxyOpt{WithTarget(target), WithModifier(authServer.ModifyHeader)}
+
+	if config.minter != nil {
+		opts = append(opts, WithModifier(config.minter.ModifyHeader))
+	}
+
+	return opts
 }
 
+// Deprecated: use NewE, which returns route conflicts as an error instead of panicking.
 func New(
-	authServer *authServer,
-	proxyServer *proxyServer,
+	authServer AuthServer,
+	proxyServer ProxyServer,
 	proxyPath string,
 	handler http.Handler,
+	opts ...serverOpt,
 ) http.Handler {
 
+	server, err := NewE(authServer, proxyServer, proxyPath, handler, opts...)
+	if err != nil {
+		panic(err)
+	}
+
+	return server
+}
+
+func NewE(
+	authServer AuthServer,
+	proxyServer ProxyServer,
+	proxyPath string,
+	handler http.Handler,
+	opts ...serverOpt,
+) (http.Handler, error) {
+
+	config := newServerConfig(opts...)
+
+	login, reauth, callback := authServer.Login, authServer.Reauth, authServer.Callback
+
+	if config.authLimits != nil {
+		limiter := NewAuthLimiter(config.logger, *config.authLimits)
+		login, reauth, callback = limiter.Limit(login), limiter.Limit(reauth), limiter.Limit(callback)
+	}
+
 	server := http.NewServeMux()
-	server.HandleFunc("/auth/login", authServer.Login)
-	server.HandleFunc("/auth/logout", authServer.Logout)
-	server.HandleFunc("/auth/callback", authServer.Callback)
-	server.HandleFunc("/auth/userinfo", authServer.UserInfo)
+	server.HandleFunc(config.authPath+"/login", login)
+	server.HandleFunc(config.authPath+"/logout", authServer.Logout)
+	server.HandleFunc(config.authPath+"/callback", callback)
+	server.HandleFunc(config.authPath+"/userinfo", authServer.UserInfo)
+	server.HandleFunc(config.authPath+"/verify", authServer.Verify)
+	server.HandleFunc(config.authPath+"/forward", authServer.ForwardAuth)
+	server.HandleFunc(config.authPath+"/reauth", reauth)
+	server.HandleFunc(config.authPath+"/switch", authServer.SwitchAccount)
+	server.HandleFunc(config.authPath+"/accounts", authServer.Accounts)
+
+	if config.minter != nil {
+		server.HandleFunc(config.authPath+"/jwks", config.minter.JWKS)
+	}
+
+	if config.cachePool != nil {
+		server.Handle(cachePoolPath, config.cachePool)
+	}
+
+	var impersonator *impersonator
+	if config.impersonationKeyring != nil {
+		impersonator = NewImpersonator(config.logger, config.impersonationKeyring, config.impersonationRole, config.impersonationTTL, config.impersonationRoles...)
+		server.HandleFunc(config.authPath+"/impersonate", impersonator.Start)
+		server.HandleFunc(config.authPath+"/impersonate/stop", impersonator.Stop)
+	}
+
+	info := ReadBuildInfo(config.features()...)
+
+	if config.version {
+		server.HandleFunc("GET /version", NewVersionHandler(info))
+	}
+
+	if config.readiness {
+		readiness := NewReadiness(
+			config.logger,
+			append([]readinessOpt{
+				WithReadinessCheck("upstream", proxyServer.Health),
+				WithReadinessCheck("session_store", authServer.Health),
+			}, config.readinessOpts...)...,
+		)
+
+		server.HandleFunc("GET /readyz", readiness.Ready)
+	}
+
 	server.HandleFunc(proxyPath, proxyServer.Serve)
 	server.Handle("/", handler)
-	return server
+
+	if config.debug {
+		server.Handle("/debug/", authServer.RequireRole(config.debugRole)(NewDebugServer()))
+	}
+
+	var root http.Handler = server
+
+	metrics := config.metrics
+
+	if config.admin {
+		adminOpts := []adminOpt{WithHealthCheck("upstream", proxyServer.Health)}
+
+		if config.dashboard != nil {
+			adminOpts = append(adminOpts, WithDashboard(config.dashboard))
+
+			if metrics != nil {
+				metrics = NewMultiMetrics(metrics, config.dashboard)
+			} else {
+				metrics = config.dashboard
+			}
+		}
+
+		adminServer := NewAdminServer(
+			config.logger,
+			append(adminOpts, config.adminOpts...)...,
+		)
+
+		server.Handle("/admin/", authServer.RequireRole(config.adminRole)(adminServer.Handler()))
+		root = adminServer.Maintenance(root)
+	}
+
+	for _, route := range config.routes {
+		if err := handleRoute(server, route); err != nil {
+			return nil, err
+		}
+	}
+
+	if len(config.routeMiddleware) > 0 {
+		root = NewRouteMiddleware(config.routeMiddleware...)(root)
+	}
+
+	for i := len(config.middleware) - 1; i >= 0; i-- {
+		root = config.middleware[i](root)
+	}
+
+	if config.countryLookup != nil {
+		root = NewGeoFilter(config.logger, config.countryLookup, config.authPath+"/login", config.geoRules...)(root)
+	}
+
+	if len(config.ipRules) > 0 {
+		root = NewIPFilter(config.logger, config.ipRules...)(root)
+	}
+
+	if config.slowRequests != nil {
+		root = NewWithSlowRequestLog(config.logger, *config.slowRequests, root)
+	}
+
+	if config.authorizer != nil {
+		root = NewAuthorizer(config.logger, config.authPath, config.authorizer)(root)
+	}
+
+	if impersonator != nil {
+		root = impersonator.Handler(root)
+	}
+
+	if config.clientLimits != nil {
+		root = NewClientLimiter(config.logger, *config.clientLimits).Handler(root)
+	}
+
+	root = authServer.Authenticate(root)
+
+	if len(config.auditSinks) > 0 {
+		root = NewAuditor(config.logger, config.auditSinks...).Handler(root)
+	}
+
+	if config.loadShedding != nil {
+		root = NewLoadShedder(config.logger, *config.loadShedding).Handler(root)
+	}
+
+	root = config.errorRenderer.Handler(root)
+
+	if config.errorReporter != nil {
+		root = NewWithErrorReporter(config.errorReporter, root)
+	}
+
+	if config.cspPolicy != "" {
+		root = NewWithCSPNonce(config.cspPolicy, root)
+	}
+
+	if metrics != nil {
+		root = NewWithMetrics(metrics, root)
+	}
+
+	if config.servedBy {
+		root = NewWithServedBy(info, root)
+	}
+
+	root = NewWithClientIP(config.trustedProxies, root)
+
+	return NewWithRequestID(root), nil
+}
+
+func handleRoute(server *http.ServeMux, route route) (err error) {
+
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("route %q : %v", route.pattern, r)
+		}
+	}()
+
+	server.Handle(route.pattern, route.handler)
+	return nil
 }
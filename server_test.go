@@ -0,0 +1,42 @@
+package wx
+
+import (
+	"net/http"
+	"net/url"
+	"testing"
+)
+
+func TestNewRejectsConflictingRoutes(t *testing.T) {
+
+	target, err := url.Parse("https://upstream.example.com/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	handler := http.NotFoundHandler()
+
+	tests := []struct {
+		name  string
+		opts  []serverOpt
+		valid bool
+	}{
+		{"distinct routes", []serverOpt{WithRoute("/a", handler), WithRoute("/b", handler)}, true},
+		{"duplicate route", []serverOpt{WithRoute("/a", handler), WithRoute("/a", handler)}, false},
+		{"conflicting wildcards", []serverOpt{WithRoute("/a/{x}", handler), WithRoute("/a/{y}", handler)}, false},
+		{"route over proxy path", []serverOpt{WithRoute("/api/", handler)}, false},
+		{"route over admin", []serverOpt{WithAdmin("admin"), WithRoute("/admin/", handler)}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			authServer := NewAuthServer(nopLogger{})
+			proxyServer := NewProxyServer(nopLogger{}, WithTarget(target))
+
+			server, err := NewE(authServer, proxyServer, "/api/", handler, test.opts...)
+			if valid := err == nil && server != nil; valid != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
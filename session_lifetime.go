@@ -0,0 +1,112 @@
+package wx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"golang.org/x/oauth2"
+)
+
+var errSessionKeyring = errors.New("session lifetimes require sealed session metadata")
+
+type SessionLifetimes struct {
+	Session    time.Duration
+	Persistent time.Duration
+	Absolute   time.Duration
+}
+
+func WithSessionLifetimes(lifetimes SessionLifetimes) authOpt {
+	return func(a *authServer) {
+		a.lifetimes = &lifetimes
+	}
+}
+
+func (a *authServer) sessionMetaCookieName(name string) string {
+	return name + "_session"
+}
+
+func (a *authServer) cookieExpiry(token *oauth2.Token, remember bool) time.Time {
+
+	if a.lifetimes == nil {
+		return token.Expiry
+	}
+
+	if !remember {
+		return time.Time{}
+	}
+
+	if a.lifetimes.Persistent > 0 {
+		return time.Now().Add(a.lifetimes.Persistent)
+	}
+
+	return token.Expiry
+}
+
+func (a *authServer) setSessionMeta(w http.ResponseWriter, name string, remember bool, expiry time.Time) error {
+
+	if a.lifetimes == nil {
+		return nil
+	}
+
+	if a.keyring == nil {
+		return errSessionKeyring
+	}
+
+	sealed, err := a.keyring.Seal(fmt.Sprintf("%d.%t", time.Now().Unix(), remember))
+	if err != nil {
+		return fmt.Errorf("seal session : %w", err)
+	}
+
+	http.SetCookie(w, &http.Cookie{
+		Name:     a.sessionMetaCookieName(name),
+		Value:    sealed,
+		Path:     "/",
+		Expires:  expiry,
+		HttpOnly: true,
+	})
+
+	return nil
+}
+
+func (a *authServer) checkSessionAge(r *http.Request) error {
+
+	if a.lifetimes == nil {
+		return nil
+	}
+
+	if a.keyring == nil {
+		return fmt.Errorf("%w: %w", ErrUnauthorized, errSessionKeyring)
+	}
+
+	cookie, err := r.Cookie(a.sessionMetaCookieName(a.sessionCookieName(r)))
+	if err != nil {
+		return fmt.Errorf("%w: missing session metadata", ErrUnauthorized)
+	}
+
+	value, err := a.keyring.Open(cookie.Value)
+	if err != nil {
+		return fmt.Errorf("%w: invalid session metadata : %w", ErrUnauthorized, err)
+	}
+
+	issuedValue, rememberValue, _ := strings.Cut(value, ".")
+
+	issued, err := strconv.ParseInt(issuedValue, 10, 64)
+	if err != nil {
+		return fmt.Errorf("%w: invalid session metadata", ErrUnauthorized)
+	}
+
+	maxAge := a.lifetimes.Absolute
+	if rememberValue != "true" && a.lifetimes.Session > 0 && (maxAge == 0 || a.lifetimes.Session < maxAge) {
+		maxAge = a.lifetimes.Session
+	}
+
+	if maxAge > 0 && time.Since(time.Unix(issued, 0)) > maxAge {
+		return fmt.Errorf("%w: session older than %v", ErrUnauthorized, maxAge)
+	}
+
+	return nil
+}
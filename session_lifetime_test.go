@@ -0,0 +1,62 @@
+package wx
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestCheckSessionAge(t *testing.T) {
+
+	ring := NewKeyring([]byte("cookie-key"))
+	lifetimes := &SessionLifetimes{Session: time.Hour, Absolute: 24 * time.Hour}
+
+	seal := func(issued time.Time, remember bool) string {
+		sealed, err := ring.Seal(fmt.Sprintf("%d.%t", issued.Unix(), remember))
+		if err != nil {
+			t.Fatal(err)
+		}
+		return sealed
+	}
+
+	now := time.Now()
+
+	tests := []struct {
+		name    string
+		keyring *keyring
+		cookie  string
+		valid   bool
+	}{
+		{"fresh session", ring, seal(now, false), true},
+		{"expired session", ring, seal(now.Add(-2*time.Hour), false), false},
+		{"remembered session", ring, seal(now.Add(-2*time.Hour), true), true},
+		{"past absolute lifetime", ring, seal(now.Add(-48*time.Hour), true), false},
+		{"forged issued at", ring, fmt.Sprintf("%d.true", now.Unix()), false},
+		{"missing metadata", ring, "", false},
+		{"no keyring", nil, fmt.Sprintf("%d.true", now.Unix()), false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			a := &authServer{Logger: nopLogger{}, keyring: test.keyring, lifetimes: lifetimes, authCookieName: "auth"}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth_session", Value: test.cookie})
+			}
+
+			err := a.checkSessionAge(r)
+
+			if valid := err == nil; valid != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+
+			if err != nil && StatusCode(err) != http.StatusUnauthorized {
+				t.Fatalf("expected 401, got %v", err)
+			}
+		})
+	}
+}
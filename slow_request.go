@@ -0,0 +1,150 @@
+package wx
+
+import (
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+const contextKeyTimings contextKey = "timings"
+
+type warnLogger interface {
+	Warnf(fmt string, a ...interface{})
+}
+
+type SlowRequestThresholds struct {
+	Latency      time.Duration
+	ResponseSize int64
+}
+
+func (t SlowRequestThresholds) Exceeded(latency time.Duration, size int64) bool {
+	return (t.Latency > 0 && latency > t.Latency) || (t.ResponseSize > 0 && size > t.ResponseSize)
+}
+
+func NewWithSlowRequestLog(logger Logger, thresholds SlowRequestThresholds, handler http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		timings := &requestTimings{phases: map[string]time.Duration{}}
+		r = r.WithContext(context.WithValue(r.Context(), contextKeyTimings, timings))
+
+		writer := NewCountingWriter(w)
+		start := time.Now()
+
+		handler.ServeHTTP(writer, r)
+
+		latency := time.Since(start)
+		if !thresholds.Exceeded(latency, writer.Size()) {
+			return
+		}
+
+		subject := ""
+		if identity, ok := UserFromContext(r.Context()); ok {
+			subject = identity.Subject
+		}
+
+		format := "slow request : %v %v : status %d : latency %v : size %d bytes : subject %q : timings [%v]"
+		args := []interface{}{r.Method, r.URL.Path, writer.StatusCode(), latency, writer.Size(), subject, timings}
+
+		if warn, ok := logger.(warnLogger); ok {
+			warn.Warnf(format, args...)
+		} else {
+			logger.Infof(format, args...)
+		}
+	})
+}
+
+func RecordTiming(ctx context.Context, phase string, duration time.Duration) {
+	if timings, ok := ctx.Value(contextKeyTimings).(*requestTimings); ok {
+		timings.record(phase, duration)
+	}
+}
+
+type requestTimings struct {
+	sync.Mutex
+	phases map[string]time.Duration
+}
+
+func (t *requestTimings) record(phase string, duration time.Duration) {
+	t.Lock()
+	defer t.Unlock()
+
+	t.phases[phase] += duration
+}
+
+func (t *requestTimings) String() string {
+	t.Lock()
+	defer t.Unlock()
+
+	phases := []string{}
+	for phase, duration := range t.phases {
+		phases = append(phases, fmt.Sprintf("%v=%v", phase, duration))
+	}
+
+	sort.Strings(phases)
+	return strings.Join(phases, " ")
+}
+
+func NewCountingWriter(w http.ResponseWriter) *countingWriter {
+	return &countingWriter{
+		ResponseWriter: w,
+	}
+}
+
+type countingWriter struct {
+	http.ResponseWriter
+
+	size       int64
+	statusCode int
+}
+
+func (c *countingWriter) WriteHeader(statusCode int) {
+	if c.statusCode == 0 && !informational(statusCode) {
+		c.statusCode = statusCode
+	}
+	c.ResponseWriter.WriteHeader(statusCode)
+}
+
+func (c *countingWriter) Write(bytes []byte) (int, error) {
+	if c.statusCode == 0 {
+		c.statusCode = http.StatusOK
+	}
+
+	n, err := c.ResponseWriter.Write(bytes)
+	c.size += int64(n)
+	return n, err
+}
+
+func (c *countingWriter) ReadFrom(r io.Reader) (int64, error) {
+	if c.statusCode == 0 {
+		c.statusCode = http.StatusOK
+	}
+
+	n, err := copyBody(c.ResponseWriter, r)
+	c.size += n
+	return n, err
+}
+
+func (c *countingWriter) Flush() {
+	if flusher, ok := c.ResponseWriter.(http.Flusher); ok {
+		flusher.Flush()
+	}
+}
+
+func (c *countingWriter) Unwrap() http.ResponseWriter {
+	return c.ResponseWriter
+}
+
+func (c *countingWriter) Size() int64 {
+	return c.size
+}
+
+func (c *countingWriter) StatusCode() int {
+	if c.statusCode == 0 {
+		return http.StatusOK
+	}
+	return c.statusCode
+}
@@ -0,0 +1,60 @@
+package wx
+
+import (
+	"context"
+	"sync"
+	"time"
+)
+
+const stateLifetime = time.Hour
+
+type StateStore interface {
+	Issue(ctx context.Context, state string, expiry time.Time) error
+	Consume(ctx context.Context, state string, expiry time.Time) (bool, error)
+}
+
+func NewMemoryStateStore() *memoryStateStore {
+	return &memoryStateStore{
+		issued: map[string]time.Time{},
+	}
+}
+
+type memoryStateStore struct {
+	mutex  sync.Mutex
+	issued map[string]time.Time
+}
+
+func (s *memoryStateStore) Issue(ctx context.Context, state string, expiry time.Time) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	s.expire(time.Now())
+
+	s.issued[state] = expiry
+	return nil
+}
+
+func (s *memoryStateStore) Consume(ctx context.Context, state string, expiry time.Time) (bool, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	now := time.Now()
+
+	s.expire(now)
+
+	issued, found := s.issued[state]
+	if !found {
+		return false, nil
+	}
+
+	delete(s.issued, state)
+	return now.Before(issued) && now.Before(expiry), nil
+}
+
+func (s *memoryStateStore) expire(now time.Time) {
+	for state, expiry := range s.issued {
+		if now.After(expiry) {
+			delete(s.issued, state)
+		}
+	}
+}
@@ -0,0 +1,71 @@
+package wx
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+)
+
+func TestMemoryStateStore(t *testing.T) {
+
+	tests := []struct {
+		name     string
+		issue    []string
+		lifetime time.Duration
+		consume  []string
+		fresh    []bool
+	}{
+		{"issued state", []string{"a"}, time.Hour, []string{"a"}, []bool{true}},
+		{"state not issued", []string{"a"}, time.Hour, []string{"b"}, []bool{false}},
+		{"replayed state", []string{"a"}, time.Hour, []string{"a", "a"}, []bool{true, false}},
+		{"expired state", []string{"a"}, -time.Second, []string{"a"}, []bool{false}},
+		{"independent states", []string{"a", "b"}, time.Hour, []string{"b", "a"}, []bool{true, true}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			store := NewMemoryStateStore()
+
+			for _, state := range test.issue {
+				if err := store.Issue(context.Background(), state, time.Now().Add(test.lifetime)); err != nil {
+					t.Fatal(err)
+				}
+			}
+
+			for i, state := range test.consume {
+				fresh, err := store.Consume(context.Background(), state, time.Now().Add(time.Hour))
+				if err != nil {
+					t.Fatal(err)
+				}
+
+				if fresh != test.fresh[i] {
+					t.Fatalf("expected %v fresh %v, got %v", state, test.fresh[i], fresh)
+				}
+			}
+		})
+	}
+}
+
+func TestCallbackRejectsUnissuedState(t *testing.T) {
+
+	a := NewAuthServer(nopLogger{}, WithOAuthConfig(oauth2Config())).(*authServer)
+
+	state, err := a.encode(State{RedirectUri: "https://evil.example.com/", Timestamp: time.Now().Unix()})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := httptest.NewRequest(http.MethodGet, "https://wx.example.com/auth/callback?"+url.Values{"code": {"code"}, "state": {state}}.Encode(), nil)
+	r.AddCookie(&http.Cookie{Name: a.stateCookieName, Value: state})
+
+	w := httptest.NewRecorder()
+	a.Callback(w, r)
+
+	if w.Code != http.StatusBadRequest {
+		t.Fatalf("expected 400, got %v : %v", w.Code, w.Body.String())
+	}
+}
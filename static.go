@@ -0,0 +1,120 @@
+package wx
+
+import (
+	"fmt"
+	"io"
+	"io/fs"
+	"mime"
+	"net/http"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+type staticOpt func(*staticServer)
+
+func WithPrecompressed() staticOpt {
+	return func(s *staticServer) {
+		s.precompressed = true
+	}
+}
+
+func WithMaxAge(ttl time.Duration) staticOpt {
+	return func(s *staticServer) {
+		s.maxAge = ttl
+	}
+}
+
+func NewStaticServer(fsys fs.FS, opts ...staticOpt) *staticServer {
+	server := &staticServer{
+		fsys:       fsys,
+		fileServer: http.FileServer(http.FS(fsys)),
+	}
+
+	for _, opt := range opts {
+		opt(server)
+	}
+
+	return server
+}
+
+type staticServer struct {
+	fsys          fs.FS
+	fileServer    http.Handler
+	precompressed bool
+	maxAge        time.Duration
+}
+
+func (s *staticServer) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+
+	if s.maxAge > 0 && w.Header().Get("Cache-Control") == "" {
+		w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%v", int(s.maxAge.Seconds())))
+	}
+
+	if s.precompressed {
+		w.Header().Add("Vary", "Accept-Encoding")
+
+		if s.serveCompressed(w, r) {
+			return
+		}
+	}
+
+	s.fileServer.ServeHTTP(w, r)
+}
+
+func (s *staticServer) serveCompressed(w http.ResponseWriter, r *http.Request) bool {
+
+	name := strings.TrimPrefix(path.Clean(r.URL.Path), "/")
+
+	for _, encoding := range []struct{ name, ext string }{{"br", ".br"}, {"gzip", ".gz"}} {
+		if !acceptsEncoding(r, encoding.name) {
+			continue
+		}
+
+		file, err := s.fsys.Open(name + encoding.ext)
+		if err != nil {
+			continue
+		}
+
+		defer file.Close()
+
+		info, err := file.Stat()
+		if err != nil || info.IsDir() {
+			continue
+		}
+
+		content, ok := file.(io.ReadSeeker)
+		if !ok {
+			continue
+		}
+
+		if contentType := mime.TypeByExtension(path.Ext(name)); contentType != "" {
+			w.Header().Set("Content-Type", contentType)
+		}
+
+		w.Header().Set("Content-Encoding", encoding.name)
+		http.ServeContent(w, r, name, info.ModTime(), content)
+		return true
+	}
+
+	return false
+}
+
+func acceptsEncoding(r *http.Request, encoding string) bool {
+	for _, value := range strings.Split(r.Header.Get("Accept-Encoding"), ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(value), ";")
+		if !strings.EqualFold(strings.TrimSpace(name), encoding) {
+			continue
+		}
+
+		if q, found := strings.CutPrefix(strings.TrimSpace(params), "q="); found {
+			weight, err := strconv.ParseFloat(q, 64)
+			return err == nil && weight > 0
+		}
+
+		return true
+	}
+
+	return false
+}
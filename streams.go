@@ -0,0 +1,358 @@
+package wx
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"net/http"
+	"sort"
+	"strconv"
+	"sync"
+	"sync/atomic"
+	"time"
+)
+
+const (
+	contextKeyStreams contextKey = "streams"
+	contextKeyStream  contextKey = "stream"
+)
+
+const (
+	StreamComplete      = "complete"
+	StreamClientGone    = "client_gone"
+	StreamWriteError    = "write_error"
+	StreamUpstreamError = "upstream_error"
+	StreamIdleTimeout   = "idle_timeout"
+	StreamMaxLifetime   = "max_lifetime"
+	StreamMessageSize   = "message_size"
+	StreamDrained       = "drained"
+)
+
+var errStreamLimit = fmt.Errorf("%w: too many open streams", ErrServiceUnavailable)
+
+type streamOpt func(*streamTracker)
+
+func WithMaxStreams(max int) streamOpt {
+	return func(t *streamTracker) {
+		t.max = max
+	}
+}
+
+func WithRouteStreamLimit(path string, max int) streamOpt {
+	return func(t *streamTracker) {
+		t.routes = append(t.routes, &streamLimit{path: path, max: max})
+	}
+}
+
+func WithStreamRoute(path string) streamOpt {
+	return WithRouteStreamLimit(path, 0)
+}
+
+func NewStreamTracker(metrics Metrics, opts ...streamOpt) *streamTracker {
+	if metrics == nil {
+		metrics = nopMetrics{}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+
+	tracker := &streamTracker{
+		Metrics:  metrics,
+		streams:  map[*stream]struct{}{},
+		draining: make(chan struct{}),
+		ctx:      ctx,
+		cancel:   cancel,
+	}
+
+	for _, opt := range opts {
+		opt(tracker)
+	}
+
+	return tracker
+}
+
+type streamLimit struct {
+	path  string
+	max   int
+	count int
+}
+
+type stream struct {
+	route   string
+	user    string
+	started time.Time
+	bytes   atomic.Int64
+	reason  atomic.Value
+}
+
+type streamTracker struct {
+	Metrics
+
+	mutex    sync.Mutex
+	active   sync.WaitGroup
+	count    int
+	drained  bool
+	draining chan struct{}
+	ctx      context.Context
+	cancel   context.CancelFunc
+	max      int
+	routes   []*streamLimit
+	streams  map[*stream]struct{}
+	closed   map[string]int
+}
+
+func (t *streamTracker) Handler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := context.WithValue(r.Context(), contextKeyStreams, t)
+		next.ServeHTTP(w, r.WithContext(ctx))
+	})
+}
+
+func (t *streamTracker) Track(ctx context.Context, path string) (context.Context, func(), error) {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	if t.drained {
+		return nil, nil, fmt.Errorf("%w: server is draining", ErrServiceUnavailable)
+	}
+
+	if t.max > 0 && t.count >= t.max {
+		t.Metrics.Counter("streams_rejected_total", 1, map[string]string{"limit": "global"})
+		return nil, nil, errStreamLimit
+	}
+
+	route := t.route(path)
+	if route != nil && route.max > 0 && route.count >= route.max {
+		t.Metrics.Counter("streams_rejected_total", 1, map[string]string{"limit": route.path})
+		return nil, nil, errStreamLimit
+	}
+
+	s := &stream{route: "*", started: time.Now()}
+
+	if route != nil {
+		route.count++
+		s.route = route.path
+	}
+
+	if identity, ok := UserFromContext(ctx); ok {
+		s.user = identity.Subject
+	}
+
+	t.streams[s] = struct{}{}
+	t.count++
+	t.active.Add(1)
+	t.Metrics.Gauge("streams_active", float64(t.count), nil)
+
+	ctx, cancel := context.WithCancel(context.WithValue(ctx, contextKeyStream, s))
+	stop := context.AfterFunc(t.ctx, cancel)
+
+	var once sync.Once
+
+	return ctx, func() {
+		once.Do(func() {
+			reason, _ := s.reason.Load().(string)
+			if reason == "" {
+				switch {
+				case t.ctx.Err() != nil:
+					reason = StreamDrained
+				case ctx.Err() != nil:
+					reason = StreamClientGone
+				default:
+					reason = StreamComplete
+				}
+			}
+
+			stop()
+			cancel()
+
+			tags := map[string]string{"route": s.route, "reason": reason}
+			t.Metrics.Counter("streams_closed_total", 1, tags)
+			t.Metrics.Counter("stream_bytes_total", float64(s.bytes.Load()), map[string]string{"route": s.route})
+			t.Metrics.Histogram("stream_duration_seconds", time.Since(s.started).Seconds(), tags)
+
+			t.mutex.Lock()
+			if route != nil {
+				route.count--
+			}
+			delete(t.streams, s)
+			if reason != StreamComplete {
+				if t.closed == nil {
+					t.closed = map[string]int{}
+				}
+				t.closed[reason]++
+			}
+			t.count--
+			t.Metrics.Gauge("streams_active", float64(t.count), nil)
+			t.mutex.Unlock()
+
+			t.active.Done()
+		})
+	}, nil
+}
+
+type StreamSummary struct {
+	Active int
+	Routes map[string]int
+	Users  map[string]int
+	Oldest time.Duration
+	Bytes  int64
+	Closed map[string]int
+}
+
+func (t *streamTracker) Summary() StreamSummary {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	summary := StreamSummary{
+		Active: len(t.streams),
+		Routes: map[string]int{},
+		Users:  map[string]int{},
+		Closed: t.closed,
+	}
+
+	t.closed = nil
+
+	for s := range t.streams {
+		summary.Routes[s.route]++
+		if s.user != "" {
+			summary.Users[s.user]++
+		}
+		if age := time.Since(s.started); age > summary.Oldest {
+			summary.Oldest = age
+		}
+		summary.Bytes += s.bytes.Load()
+	}
+
+	return summary
+}
+
+func (t *streamTracker) Report(logger Logger) {
+
+	summary := t.Summary()
+
+	for route, count := range summary.Routes {
+		t.Metrics.Gauge("streams_active_route", float64(count), map[string]string{"route": route})
+	}
+
+	t.Metrics.Gauge("streams_active_users", float64(len(summary.Users)), nil)
+	t.Metrics.Gauge("streams_oldest_seconds", summary.Oldest.Seconds(), nil)
+
+	if summary.Active == 0 && len(summary.Closed) == 0 {
+		return
+	}
+
+	users := make([]string, 0, len(summary.Users))
+	for user := range summary.Users {
+		users = append(users, user)
+	}
+
+	sort.Slice(users, func(i, j int) bool {
+		return summary.Users[users[i]] > summary.Users[users[j]]
+	})
+
+	top := map[string]int{}
+	for _, user := range users[:min(len(users), 5)] {
+		top[user] = summary.Users[user]
+	}
+
+	logger.Infof("streams : active %d, routes %v, users %d (top %v), oldest %v, bytes %d, abnormal closes %v",
+		summary.Active, summary.Routes, len(summary.Users), top, summary.Oldest.Round(time.Second), summary.Bytes, summary.Closed)
+}
+
+func (t *streamTracker) route(path string) *streamLimit {
+	for _, route := range t.routes {
+		if matchPath(route.path, path) {
+			return route
+		}
+	}
+	return nil
+}
+
+func (t *streamTracker) Draining() <-chan struct{} {
+	return t.draining
+}
+
+func (t *streamTracker) Active() int {
+	t.mutex.Lock()
+	defer t.mutex.Unlock()
+
+	return t.count
+}
+
+func (t *streamTracker) Drain(ctx context.Context) error {
+	t.mutex.Lock()
+	if !t.drained {
+		t.drained = true
+		close(t.draining)
+	}
+	t.mutex.Unlock()
+
+	done := make(chan struct{})
+	go func() {
+		t.active.Wait()
+		close(done)
+	}()
+
+	select {
+	case <-done:
+		return nil
+	case <-ctx.Done():
+		t.Metrics.Counter("streams_force_closed_total", float64(t.Active()), nil)
+		t.cancel()
+		<-done
+		return ctx.Err()
+	}
+}
+
+func TrackStream(r *http.Request) (context.Context, func(), error) {
+	releaseClient, err := admitClientStream(r)
+	if err != nil {
+		return r.Context(), nil, err
+	}
+
+	tracker, ok := r.Context().Value(contextKeyStreams).(*streamTracker)
+	if !ok {
+		releaseShedSlot(r)
+		return r.Context(), releaseClient, nil
+	}
+
+	ctx, release, err := tracker.Track(r.Context(), r.URL.Path)
+	if err != nil {
+		releaseClient()
+		return ctx, nil, err
+	}
+
+	releaseShedSlot(r)
+
+	return ctx, func() {
+		release()
+		releaseClient()
+	}, nil
+}
+
+func StreamTransferred(ctx context.Context, n int) {
+	if s, ok := ctx.Value(contextKeyStream).(*stream); ok {
+		s.bytes.Add(int64(n))
+	}
+}
+
+func StreamClosed(ctx context.Context, reason string) {
+	if s, ok := ctx.Value(contextKeyStream).(*stream); ok {
+		s.reason.CompareAndSwap(nil, reason)
+	}
+}
+
+func RejectStream(w http.ResponseWriter, r *http.Request, err error) {
+	if errors.Is(err, errStreamLimit) {
+		w.Header().Set("Retry-After", strconv.Itoa(5+rand.IntN(10)))
+	}
+
+	RenderError(w, r, err)
+}
+
+func StreamDraining(ctx context.Context) <-chan struct{} {
+	if tracker, ok := ctx.Value(contextKeyStreams).(*streamTracker); ok {
+		return tracker.Draining()
+	}
+	return nil
+}
@@ -0,0 +1,50 @@
+package wx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+)
+
+type TargetSelector func(r *http.Request) (*url.URL, error)
+
+func NewClaimTargetSelector(claim string, targets map[string]string) (TargetSelector, error) {
+
+	if claim == "" {
+		return nil, errors.New("target selector : claim is empty")
+	}
+
+	if len(targets) == 0 {
+		return nil, errors.New("target selector : no targets")
+	}
+
+	parsed := map[string]*url.URL{}
+
+	for value, target := range targets {
+		if err := validateURL(target); err != nil {
+			return nil, fmt.Errorf("target selector : %v : %w", value, err)
+		}
+		parsed[value], _ = url.Parse(target)
+	}
+
+	return func(r *http.Request) (*url.URL, error) {
+
+		identity, ok := UserFromContext(r.Context())
+		if !ok {
+			return nil, errMissingCredentials
+		}
+
+		values := claimValues(identity.Claims, claim)
+		if len(values) == 0 {
+			return nil, fmt.Errorf("%w: missing claim %v", ErrForbidden, claim)
+		}
+
+		target, ok := parsed[values[0]]
+		if !ok {
+			return nil, fmt.Errorf("%w: no target for %v %q", ErrForbidden, claim, values[0])
+		}
+
+		return target, nil
+	}, nil
+}
@@ -0,0 +1,165 @@
+package wx
+
+import (
+	"context"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestClaimTargetSelector(t *testing.T) {
+
+	selector, err := NewClaimTargetSelector("tenant_id", map[string]string{
+		"acme":   "https://acme.internal",
+		"globex": "https://globex.internal/api",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name   string
+		claims map[string]interface{}
+		target string
+		status int
+	}{
+		{"mapped tenant", map[string]interface{}{"tenant_id": "acme"}, "https://acme.internal", 0},
+		{"mapped tenant with path", map[string]interface{}{"tenant_id": "globex"}, "https://globex.internal/api", 0},
+		{"unmapped tenant", map[string]interface{}{"tenant_id": "initech"}, "", http.StatusForbidden},
+		{"host injection", map[string]interface{}{"tenant_id": "evil.example.com"}, "", http.StatusForbidden},
+		{"path injection", map[string]interface{}{"tenant_id": "acme/../admin"}, "", http.StatusForbidden},
+		{"missing claim", map[string]interface{}{}, "", http.StatusForbidden},
+		{"no identity", nil, "", http.StatusUnauthorized},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.claims != nil {
+				r = r.WithContext(ContextWithUser(r.Context(), &Identity{Claims: test.claims}))
+			}
+
+			target, err := selector(r)
+
+			if test.status != 0 {
+				if StatusCode(err) != test.status {
+					t.Fatalf("expected %v, got %v", test.status, err)
+				}
+				return
+			}
+
+			if err != nil {
+				t.Fatalf("unexpected error : %v", err)
+			}
+
+			if target.String() != test.target {
+				t.Fatalf("expected %v, got %v", test.target, target)
+			}
+		})
+	}
+}
+
+func TestClaimTargetSelectorConfig(t *testing.T) {
+
+	tests := []struct {
+		name    string
+		claim   string
+		targets map[string]string
+	}{
+		{"empty claim", "", map[string]string{"acme": "https://acme.internal"}},
+		{"no targets", "tenant_id", nil},
+		{"relative target", "tenant_id", map[string]string{"acme": "/acme"}},
+		{"non http target", "tenant_id", map[string]string{"acme": "file:///etc"}},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			if _, err := NewClaimTargetSelector(test.claim, test.targets); err == nil {
+				t.Fatal("expected error")
+			}
+		})
+	}
+}
+
+type targetMetrics struct {
+	nopMetrics
+	targets []string
+}
+
+func (m *targetMetrics) Histogram(name string, value float64, tags map[string]string) {
+	if name == "upstream_request_duration_seconds" {
+		m.targets = append(m.targets, tags["target"])
+	}
+}
+
+func TestSelectorOnlyProxy(t *testing.T) {
+
+	upstream := func(name string) *httptest.Server {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			io.WriteString(w, name)
+		}))
+		t.Cleanup(server.Close)
+		return server
+	}
+
+	acme := upstream("acme")
+	globex := upstream("globex")
+
+	selector, err := NewClaimTargetSelector("tenant_id", map[string]string{
+		"acme":   acme.URL,
+		"globex": globex.URL,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	proxy := NewProxyServer(nopLogger{}, WithTargetSelector(selector))
+
+	tests := []struct {
+		name   string
+		tenant string
+		status int
+		body   string
+		target string
+	}{
+		{"first tenant", "acme", http.StatusOK, "acme", acme.Listener.Addr().String()},
+		{"second tenant", "globex", http.StatusOK, "globex", globex.Listener.Addr().String()},
+		{"unmapped tenant", "initech", http.StatusForbidden, "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			metrics := &targetMetrics{}
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			ctx := ContextWithUser(r.Context(), &Identity{Claims: map[string]interface{}{"tenant_id": test.tenant}})
+			r = r.WithContext(context.WithValue(ctx, contextKeyMetrics, metrics))
+
+			w := httptest.NewRecorder()
+			proxy.Serve(w, r)
+
+			if w.Code != test.status {
+				t.Fatalf("expected %v, got %v : %v", test.status, w.Code, w.Body.String())
+			}
+
+			if test.status != http.StatusOK {
+				return
+			}
+
+			if w.Body.String() != test.body {
+				t.Fatalf("expected body %q, got %q", test.body, w.Body.String())
+			}
+
+			if len(metrics.targets) != 1 || metrics.targets[0] != test.target {
+				t.Fatalf("expected target tag %v, got %v", test.target, metrics.targets)
+			}
+		})
+	}
+
+	if err := proxy.Health(context.Background()); err == nil {
+		t.Fatal("expected health to fail without a static target")
+	}
+}
@@ -0,0 +1,207 @@
+package wx
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/golang/groupcache/lru"
+	"golang.org/x/oauth2"
+)
+
+const (
+	tokenExchangeEntries = 4096
+	tokenExchangeSkew    = 30 * time.Second
+
+	grantTypeTokenExchange = "urn:ietf:params:oauth:grant-type:token-exchange"
+	tokenTypeAccessToken   = "urn:ietf:params:oauth:token-type:access_token"
+)
+
+type tokenExchangeOpt func(*tokenExchanger)
+
+func WithActorToken(source SecretSource) tokenExchangeOpt {
+	return func(t *tokenExchanger) {
+		t.actor = source
+	}
+}
+
+func WithExchangeClientSecret(source SecretSource) tokenExchangeOpt {
+	return func(t *tokenExchanger) {
+		t.clientSecret = source
+	}
+}
+
+func NewTokenExchanger(logger Logger, client *http.Client, config oauth2.Config, opts ...tokenExchangeOpt) *tokenExchanger {
+	exchanger := &tokenExchanger{
+		Logger: logger,
+		Client: client,
+		Config: config,
+		tokens: lru.New(tokenExchangeEntries),
+	}
+
+	for _, opt := range opts {
+		opt(exchanger)
+	}
+
+	return exchanger
+}
+
+type tokenExchanger struct {
+	Logger
+	*http.Client
+	oauth2.Config
+
+	mutex        sync.Mutex
+	tokens       *lru.Cache
+	actor        SecretSource
+	clientSecret SecretSource
+}
+
+type exchangedToken struct {
+	authorization string
+	expiry        time.Time
+}
+
+func (t *tokenExchanger) Modifier(audience string, scopes ...string) Modifier {
+	return func(r *http.Request) error {
+
+		authorization := r.Header.Get("Authorization")
+		if authorization == "" {
+			return nil
+		}
+
+		subjectToken := authorization
+		if _, token, found := strings.Cut(authorization, " "); found {
+			subjectToken = token
+		}
+
+		exchanged, err := t.Exchange(r.Context(), subjectToken, audience, scopes...)
+		if err != nil {
+			return NewStatusError(http.StatusBadGateway, fmt.Errorf("token exchange [%v] : %w", audience, err))
+		}
+
+		r.Header.Set("Authorization", exchanged)
+		return nil
+	}
+}
+
+func (t *tokenExchanger) Exchange(ctx context.Context, subjectToken string, audience string, scopes ...string) (string, error) {
+
+	sum := sha256.Sum256([]byte(subjectToken))
+	key := hex.EncodeToString(sum[:]) + "|" + audience + "|" + strings.Join(scopes, " ")
+
+	t.mutex.Lock()
+	if value, ok := t.tokens.Get(key); ok {
+		if token := value.(exchangedToken); time.Now().Before(token.expiry) {
+			t.mutex.Unlock()
+			return token.authorization, nil
+		}
+		t.tokens.Remove(key)
+	}
+	t.mutex.Unlock()
+
+	token, err := t.exchange(ctx, subjectToken, audience, scopes)
+	if err != nil {
+		return "", err
+	}
+
+	t.mutex.Lock()
+	t.tokens.Add(key, token)
+	t.mutex.Unlock()
+
+	t.Logger.Debug("exchanged token for audience : ", audience)
+
+	return token.authorization, nil
+}
+
+func (t *tokenExchanger) exchange(ctx context.Context, subjectToken string, audience string, scopes []string) (exchangedToken, error) {
+
+	form := url.Values{
+		"grant_type":           {grantTypeTokenExchange},
+		"subject_token":        {subjectToken},
+		"subject_token_type":   {tokenTypeAccessToken},
+		"requested_token_type": {tokenTypeAccessToken},
+		"audience":             {audience},
+	}
+
+	if len(scopes) > 0 {
+		form.Set("scope", strings.Join(scopes, " "))
+	}
+
+	if t.actor != nil {
+		actorToken, err := t.actor.Secret(ctx)
+		if err != nil {
+			return exchangedToken{}, fmt.Errorf("actor token : %w", err)
+		}
+		form.Set("actor_token", actorToken)
+		form.Set("actor_token_type", tokenTypeAccessToken)
+	}
+
+	clientSecret := t.Config.ClientSecret
+	if t.clientSecret != nil {
+		secret, err := t.clientSecret.Secret(ctx)
+		if err != nil {
+			return exchangedToken{}, fmt.Errorf("client secret : %w", err)
+		}
+		clientSecret = secret
+	}
+
+	if t.Config.Endpoint.AuthStyle == oauth2.AuthStyleInParams {
+		form.Set("client_id", t.Config.ClientID)
+		form.Set("client_secret", clientSecret)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, t.Config.Endpoint.TokenURL, strings.NewReader(form.Encode()))
+	if err != nil {
+		return exchangedToken{}, fmt.Errorf("new request: %w", err)
+	}
+
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	if t.Config.Endpoint.AuthStyle != oauth2.AuthStyleInParams {
+		req.SetBasicAuth(url.QueryEscape(t.Config.ClientID), url.QueryEscape(clientSecret))
+	}
+
+	resp, err := t.Client.Do(req)
+	if err != nil {
+		return exchangedToken{}, fmt.Errorf("client do: %w", err)
+	}
+
+	defer resp.Body.Close()
+
+	var body struct {
+		AccessToken string `json:"access_token"`
+		TokenType   string `json:"token_type"`
+		ExpiresIn   int    `json:"expires_in"`
+		Error       string `json:"error"`
+	}
+
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return exchangedToken{}, fmt.Errorf("decode response : %w", err)
+	}
+
+	if resp.StatusCode != http.StatusOK || body.AccessToken == "" {
+		return exchangedToken{}, NewStatusError(resp.StatusCode, fmt.Errorf("exchange rejected : %v", body.Error))
+	}
+
+	if body.TokenType == "" || strings.EqualFold(body.TokenType, "n_a") {
+		body.TokenType = "Bearer"
+	}
+
+	expiry := time.Now().Add(time.Duration(body.ExpiresIn)*time.Second - tokenExchangeSkew)
+	if body.ExpiresIn == 0 {
+		expiry = time.Now().Add(time.Minute)
+	}
+
+	return exchangedToken{
+		authorization: body.TokenType + " " + body.AccessToken,
+		expiry:        expiry,
+	}, nil
+}
@@ -0,0 +1,162 @@
+package wx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+)
+
+func NewTokenMinter(issuer string, audience string, ttl time.Duration, keys ...*ecdsa.PrivateKey) (*tokenMinter, error) {
+
+	if len(keys) == 0 {
+		return nil, errors.New("token minter : no signing keys")
+	}
+
+	minter := &tokenMinter{
+		issuer:   issuer,
+		audience: audience,
+		ttl:      ttl,
+	}
+
+	for _, key := range keys {
+		if key.Curve != elliptic.P256() {
+			return nil, errors.New("token minter : keys must use P-256")
+		}
+		minter.keys = append(minter.keys, mintingKey{id: keyID(&key.PublicKey), key: key})
+	}
+
+	return minter, nil
+}
+
+type tokenMinter struct {
+	issuer   string
+	audience string
+	ttl      time.Duration
+	keys     []mintingKey
+}
+
+type mintingKey struct {
+	id  string
+	key *ecdsa.PrivateKey
+}
+
+type MintedClaims struct {
+	Issuer    string   `json:"iss"`
+	Subject   string   `json:"sub"`
+	Audience  string   `json:"aud,omitempty"`
+	Email     string   `json:"email,omitempty"`
+	Roles     []string `json:"roles,omitempty"`
+	Actor     *Actor   `json:"act,omitempty"`
+	IssuedAt  int64    `json:"iat"`
+	ExpiresAt int64    `json:"exp"`
+}
+
+type Actor struct {
+	Subject string `json:"sub"`
+}
+
+func (m *tokenMinter) Mint(identity *Identity) (string, error) {
+
+	now := time.Now()
+	expiry := now.Add(m.ttl)
+	if !identity.Expiry.IsZero() && identity.Expiry.Before(expiry) {
+		expiry = identity.Expiry
+	}
+
+	claims := MintedClaims{
+		Issuer:    m.issuer,
+		Subject:   identity.Subject,
+		Audience:  m.audience,
+		Email:     identity.Email,
+		Roles:     identity.Roles,
+		IssuedAt:  now.Unix(),
+		ExpiresAt: expiry.Unix(),
+	}
+
+	if identity.Actor != "" {
+		claims.Actor = &Actor{Subject: identity.Actor}
+	}
+
+	signer := m.keys[0]
+
+	return signES256(signer.key, map[string]string{"alg": "ES256", "typ": "JWT", "kid": signer.id}, claims)
+}
+
+func (m *tokenMinter) ModifyHeader(r *http.Request) error {
+
+	identity, ok := UserFromContext(r.Context())
+	if !ok {
+		return nil
+	}
+
+	token, err := m.Mint(identity)
+	if err != nil {
+		return NewStatusError(http.StatusInternalServerError, err)
+	}
+
+	r.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (m *tokenMinter) JWKS(w http.ResponseWriter, r *http.Request) {
+
+	keys := []map[string]string{}
+	for _, key := range m.keys {
+		keys = append(keys, map[string]string{
+			"kty": "EC",
+			"crv": "P-256",
+			"use": "sig",
+			"alg": "ES256",
+			"kid": key.id,
+			"x":   base64.RawURLEncoding.EncodeToString(key.key.PublicKey.X.FillBytes(make([]byte, 32))),
+			"y":   base64.RawURLEncoding.EncodeToString(key.key.PublicKey.Y.FillBytes(make([]byte, 32))),
+		})
+	}
+
+	w.Header().Set("Content-Type", "application/json")
+	w.Header().Set("Cache-Control", "public, max-age=300")
+	json.NewEncoder(w).Encode(map[string]interface{}{"keys": keys})
+}
+
+func signES256(key *ecdsa.PrivateKey, header interface{}, claims interface{}) (string, error) {
+
+	encodedHeader, err := json.Marshal(header)
+	if err != nil {
+		return "", fmt.Errorf("marshal header : %w", err)
+	}
+
+	payload, err := json.Marshal(claims)
+	if err != nil {
+		return "", fmt.Errorf("marshal claims : %w", err)
+	}
+
+	signed := base64.RawURLEncoding.EncodeToString(encodedHeader) + "." + base64.RawURLEncoding.EncodeToString(payload)
+	digest := sha256.Sum256([]byte(signed))
+
+	r, s, err := ecdsa.Sign(rand.Reader, key, digest[:])
+	if err != nil {
+		return "", fmt.Errorf("sign token : %w", err)
+	}
+
+	signature := make([]byte, 64)
+	r.FillBytes(signature[:32])
+	s.FillBytes(signature[32:])
+
+	return signed + "." + base64.RawURLEncoding.EncodeToString(signature), nil
+}
+
+func keyID(key *ecdsa.PublicKey) string {
+	thumbprint := fmt.Sprintf(`{"crv":"P-256","kty":"EC","x":"%s","y":"%s"}`,
+		base64.RawURLEncoding.EncodeToString(key.X.FillBytes(make([]byte, 32))),
+		base64.RawURLEncoding.EncodeToString(key.Y.FillBytes(make([]byte, 32))),
+	)
+	sum := sha256.Sum256([]byte(thumbprint))
+	return base64.RawURLEncoding.EncodeToString(sum[:])
+}
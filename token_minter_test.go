@@ -0,0 +1,129 @@
+package wx
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestTokenMinterMintsVerifiedIdentities(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minter, err := NewTokenMinter("https://wx.example.com", "upstream", time.Minute, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwks := httptest.NewServer(http.HandlerFunc(minter.JWKS))
+	defer jwks.Close()
+
+	keyring := NewKeyring([]byte("cookie-key"))
+	a := NewAuthServer(nopLogger{}, WithCookieKeyring(keyring)).(*authServer)
+
+	claims := map[string]interface{}{"sub": "alice", "roles": []string{"admin"}, "exp": time.Now().Add(time.Hour).Unix()}
+	token := "Bearer " + unsignedToken(t, claims)
+
+	sealed, err := keyring.Seal(token)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name    string
+		cookie  string
+		header  string
+		subject string
+	}{
+		{"sealed cookie", sealed, "", "alice"},
+		{"forged cookie", token, "", ""},
+		{"forged cookie keeps inbound header", token, "Bearer inbound", ""},
+		{"no cookie", "", "", ""},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			var upstream *http.Request
+
+			handler := a.Authenticate(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				if err := minter.ModifyHeader(r); err != nil {
+					t.Fatalf("unexpected error : %v", err)
+				}
+				upstream = r
+			}))
+
+			r := httptest.NewRequest(http.MethodGet, "/", nil)
+			if test.cookie != "" {
+				r.AddCookie(&http.Cookie{Name: "auth", Value: test.cookie})
+			}
+			if test.header != "" {
+				r.Header.Set("Authorization", test.header)
+			}
+
+			handler.ServeHTTP(httptest.NewRecorder(), r)
+
+			authorization := upstream.Header.Get("Authorization")
+
+			if test.subject == "" {
+				if authorization != test.header {
+					t.Fatalf("expected authorization %q, got %q", test.header, authorization)
+				}
+				return
+			}
+
+			minted, err := NewJWKSVerifier(jwks.Client(), jwks.URL, "https://wx.example.com", "upstream", time.Minute).Verify(r.Context(), authorization)
+			if err != nil {
+				t.Fatalf("minted token does not verify : %v", err)
+			}
+
+			if minted["sub"] != test.subject || minted["iss"] != "https://wx.example.com" || minted["aud"] != "upstream" {
+				t.Fatalf("unexpected claims %v", minted)
+			}
+		})
+	}
+}
+
+func TestValidateRequiresKeyringForMinter(t *testing.T) {
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	minter, err := NewTokenMinter("https://wx.example.com", "upstream", time.Minute, key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	jwksOpt := WithAuthOptions(WithJWKS("https://idp.example.com/jwks", "https://idp.example.com", "client", time.Hour))
+	keyringOpt := WithAuthOptions(WithCookieKeyring(NewKeyring([]byte("cookie-key"))))
+
+	tests := []struct {
+		name  string
+		opts  []serverOpt
+		fails bool
+	}{
+		{"minter with keyring", []serverOpt{WithTokenMinter(minter), keyringOpt}, false},
+		{"minter with jwks only", []serverOpt{WithTokenMinter(minter), jwksOpt}, true},
+		{"no minter with jwks", []serverOpt{jwksOpt}, false},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			err := Validate(nil, oauth2Config(), test.opts...)
+
+			if failed := containsError(err, "token minter"); failed != test.fails {
+				t.Fatalf("expected failure %v, got %v", test.fails, err)
+			}
+		})
+	}
+}
@@ -0,0 +1,160 @@
+package wx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+)
+
+type PathRewrite struct {
+	Pattern     string `json:"pattern" yaml:"pattern"`
+	Replacement string `json:"replacement" yaml:"replacement"`
+}
+
+type TransformRule struct {
+	Path                  string            `json:"path,omitempty" yaml:"path,omitempty"`
+	Method                string            `json:"method,omitempty" yaml:"method,omitempty"`
+	SetHeaders            map[string]string `json:"set_headers,omitempty" yaml:"set_headers,omitempty"`
+	RemoveHeaders         []string          `json:"remove_headers,omitempty" yaml:"remove_headers,omitempty"`
+	RewritePath           *PathRewrite      `json:"rewrite_path,omitempty" yaml:"rewrite_path,omitempty"`
+	AddQuery              map[string]string `json:"add_query,omitempty" yaml:"add_query,omitempty"`
+	SetResponseHeaders    map[string]string `json:"set_response_headers,omitempty" yaml:"set_response_headers,omitempty"`
+	RemoveResponseHeaders []string          `json:"remove_response_headers,omitempty" yaml:"remove_response_headers,omitempty"`
+	MapStatus             map[int]int       `json:"map_status,omitempty" yaml:"map_status,omitempty"`
+}
+
+func (t TransformRule) Matches(method string, path string) bool {
+	if t.Method != "" && !strings.EqualFold(t.Method, method) {
+		return false
+	}
+
+	return t.Path == "" || matchPath(t.Path, path)
+}
+
+type compiledTransform struct {
+	TransformRule
+	rewrite *regexp.Regexp
+}
+
+func CompileTransforms(rules ...TransformRule) (*transformer, error) {
+
+	compiled := []compiledTransform{}
+	errs := []error{}
+
+	for i, rule := range rules {
+		transform := compiledTransform{TransformRule: rule}
+
+		if rule.RewritePath != nil {
+			rewrite, err := regexp.Compile(rule.RewritePath.Pattern)
+			if err != nil {
+				errs = append(errs, fmt.Errorf("rule %d : rewrite path : %w", i, err))
+			}
+			transform.rewrite = rewrite
+		}
+
+		for from, to := range rule.MapStatus {
+			if from < 200 || from > 599 || to < 200 || to > 599 {
+				errs = append(errs, fmt.Errorf("rule %d : map status %d => %d : invalid status code", i, from, to))
+			}
+		}
+
+		for _, headers := range [][]string{rule.RemoveHeaders, rule.RemoveResponseHeaders, sortedKeys(rule.SetHeaders), sortedKeys(rule.SetResponseHeaders)} {
+			for _, name := range headers {
+				if strings.TrimSpace(name) == "" || strings.ContainsAny(name, " :\r\n") {
+					errs = append(errs, fmt.Errorf("rule %d : invalid header name %q", i, name))
+				}
+			}
+		}
+
+		compiled = append(compiled, transform)
+	}
+
+	if err := errors.Join(errs...); err != nil {
+		return nil, err
+	}
+
+	return &transformer{rules: compiled}, nil
+}
+
+type transformer struct {
+	rules []compiledTransform
+}
+
+func (t *transformer) ModifyRequest(r *http.Request) error {
+
+	path := r.URL.Path
+	if route, ok := RouteFromContext(r.Context()); ok {
+		path = route.Path
+	}
+
+	for _, rule := range t.rules {
+		if !rule.Matches(r.Method, path) {
+			continue
+		}
+
+		for _, name := range rule.RemoveHeaders {
+			r.Header.Del(name)
+		}
+
+		for name, value := range rule.SetHeaders {
+			r.Header.Set(name, value)
+		}
+
+		if rule.rewrite != nil {
+			r.URL.Path = rule.rewrite.ReplaceAllString(r.URL.Path, rule.RewritePath.Replacement)
+			r.URL.RawPath = ""
+		}
+
+		if len(rule.AddQuery) > 0 {
+			query := r.URL.Query()
+			for name, value := range rule.AddQuery {
+				query.Add(name, value)
+			}
+			r.URL.RawQuery = query.Encode()
+		}
+	}
+
+	return nil
+}
+
+func (t *transformer) ModifyResponse(resp *http.Response) error {
+
+	if resp.Request == nil {
+		return nil
+	}
+
+	method, path := resp.Request.Method, resp.Request.URL.Path
+	if route, ok := RouteFromContext(resp.Request.Context()); ok {
+		path = route.Path
+	}
+
+	for _, rule := range t.rules {
+		if !rule.Matches(method, path) {
+			continue
+		}
+
+		for _, name := range rule.RemoveResponseHeaders {
+			resp.Header.Del(name)
+		}
+
+		for name, value := range rule.SetResponseHeaders {
+			resp.Header.Set(name, value)
+		}
+
+		if status, ok := rule.MapStatus[resp.StatusCode]; ok {
+			resp.StatusCode = status
+			resp.Status = fmt.Sprintf("%d %s", status, http.StatusText(status))
+		}
+	}
+
+	return nil
+}
+
+func WithTransforms(transformer *transformer) proxyOpt {
+	return func(p *proxyServer) {
+		p.Modifiers = append(p.Modifiers, transformer.ModifyRequest)
+		p.responseModifiers = append(p.responseModifiers, transformer.ModifyResponse)
+	}
+}
@@ -0,0 +1,40 @@
+package wx
+
+import (
+	"net/http"
+	"time"
+)
+
+type TransportSettings struct {
+	MaxIdleConns          int
+	MaxIdleConnsPerHost   int
+	MaxConnsPerHost       int
+	IdleConnTimeout       time.Duration
+	ResponseHeaderTimeout time.Duration
+}
+
+func NewTargetClient(settings TransportSettings) *http.Client {
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+
+	if settings.MaxIdleConns > 0 {
+		transport.MaxIdleConns = settings.MaxIdleConns
+	}
+
+	if settings.MaxIdleConnsPerHost > 0 {
+		transport.MaxIdleConnsPerHost = settings.MaxIdleConnsPerHost
+	}
+
+	if settings.MaxConnsPerHost > 0 {
+		transport.MaxConnsPerHost = settings.MaxConnsPerHost
+	}
+
+	if settings.IdleConnTimeout > 0 {
+		transport.IdleConnTimeout = settings.IdleConnTimeout
+	}
+
+	if settings.ResponseHeaderTimeout > 0 {
+		transport.ResponseHeaderTimeout = settings.ResponseHeaderTimeout
+	}
+
+	return &http.Client{Transport: transport}
+}
@@ -0,0 +1,97 @@
+package wx
+
+import (
+	"context"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+const contextKeyTrustedSource contextKey = "trusted_source"
+
+type TrustedHeaders struct {
+	User    string
+	Email   string
+	Groups  string
+	Sources []netip.Prefix
+}
+
+func WithTrustedHeaders(headers TrustedHeaders) authOpt {
+	return func(a *authServer) {
+		if headers.User == "" {
+			headers.User = "X-Remote-User"
+		}
+		if headers.Email == "" {
+			headers.Email = "X-Remote-Email"
+		}
+		if headers.Groups == "" {
+			headers.Groups = "X-Remote-Groups"
+		}
+		a.trustedHeaders = &headers
+	}
+}
+
+func (a *authServer) trustedSource(r *http.Request) bool {
+	ip, ok := resolveClientIP(r, 0)
+	return ok && containsIP(a.trustedHeaders.Sources, ip)
+}
+
+func (a *authServer) trustedIdentity(r *http.Request) (*Identity, bool) {
+
+	if a.trustedHeaders == nil {
+		return nil, false
+	}
+
+	user := strings.TrimSpace(r.Header.Get(a.trustedHeaders.User))
+	if user == "" {
+		return nil, false
+	}
+
+	if !a.trustedSource(r) {
+		a.Logger.Infof("ignoring trusted headers from untrusted source : %v", r.RemoteAddr)
+		return nil, false
+	}
+
+	groups := []string{}
+	for _, group := range strings.Split(r.Header.Get(a.trustedHeaders.Groups), ",") {
+		if group = strings.TrimSpace(group); group != "" {
+			groups = append(groups, group)
+		}
+	}
+
+	email := strings.TrimSpace(r.Header.Get(a.trustedHeaders.Email))
+
+	claims := map[string]interface{}{"sub": user}
+	if email != "" {
+		claims["email"] = email
+	}
+
+	identity := NewIdentity("", claims, a.roleClaim)
+	identity.Roles = groups
+
+	return identity, true
+}
+
+func (a *authServer) withTrustedSource(r *http.Request) *http.Request {
+
+	if a.trustedHeaders == nil || !a.trustedSource(r) {
+		return r
+	}
+
+	return r.WithContext(context.WithValue(r.Context(), contextKeyTrustedSource, true))
+}
+
+func (a *authServer) stripTrustedHeaders(r *http.Request) {
+
+	if a.trustedHeaders == nil {
+		return
+	}
+
+	if trusted, _ := r.Context().Value(contextKeyTrustedSource).(bool); trusted {
+		return
+	}
+
+	r.Header.Del(a.trustedHeaders.User)
+	r.Header.Del(a.trustedHeaders.Email)
+	r.Header.Del(a.trustedHeaders.Groups)
+}
@@ -0,0 +1,153 @@
+package wx
+
+import (
+	"bufio"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+)
+
+func WithUpgradeIdleTimeout(timeout time.Duration) proxyOpt {
+	return func(p *proxyServer) {
+		p.upgradeIdleTimeout = timeout
+	}
+}
+
+func (p *proxyServer) Tunnel(w http.ResponseWriter, r *http.Request, resp *http.Response) {
+
+	upstream, ok := resp.Body.(io.ReadWriteCloser)
+	if !ok {
+		RenderError(w, r, NewStatusError(http.StatusBadGateway, errors.New("upgraded body not writable")))
+		p.Logger.Error("upgraded body not writable")
+		return
+	}
+
+	ctx := r.Context()
+
+	conn, brw, err := http.NewResponseController(w).Hijack()
+	if err != nil {
+		RenderError(w, r, NewStatusError(http.StatusInternalServerError, fmt.Errorf("hijack : %w", err)))
+		p.Logger.Errorf("hijack : %v", err)
+		return
+	}
+
+	defer conn.Close()
+
+	if err := conn.SetDeadline(time.Time{}); err != nil {
+		p.Logger.Errorf("clear deadline : %v", err)
+	}
+
+	if err := writeUpgradeResponse(brw, resp.Status, w.Header()); err != nil {
+		p.Logger.Errorf("write upgrade response : %v", err)
+		return
+	}
+
+	closeAll := func() {
+		conn.Close()
+		upstream.Close()
+	}
+
+	stop := context.AfterFunc(ctx, closeAll)
+	defer stop()
+
+	var idle *time.Timer
+	if p.upgradeIdleTimeout > 0 {
+		idle = time.AfterFunc(p.upgradeIdleTimeout, func() {
+			StreamClosed(ctx, StreamIdleTimeout)
+			closeAll()
+		})
+		defer idle.Stop()
+	}
+
+	if lifetime := p.upgradePolicy.MaxLifetime; lifetime > 0 {
+		timer := time.AfterFunc(lifetime, func() {
+			StreamClosed(ctx, StreamMaxLifetime)
+			closeAll()
+		})
+		defer timer.Stop()
+	}
+
+	var client io.Reader = brw.Reader
+	if max := p.upgradePolicy.MaxMessageSize; max > 0 && isWebSocket(r) {
+		client = &wsMessageLimiter{Reader: client, max: max}
+	}
+
+	metrics := MetricsFromContext(r.Context())
+	tags := map[string]string{"target": p.Target.Host, "protocol": strings.ToLower(r.Header.Get("Upgrade"))}
+	start := time.Now()
+
+	metrics.Gauge("upgrade_connections", float64(p.upgrades.Add(1)), tags)
+	defer func() {
+		metrics.Gauge("upgrade_connections", float64(p.upgrades.Add(-1)), tags)
+		metrics.Histogram("upgrade_duration_seconds", time.Since(start).Seconds(), tags)
+	}()
+
+	done := make(chan error, 2)
+	go func() { done <- p.pipe(ctx, upstream, client, idle) }()
+	go func() { done <- p.pipe(ctx, conn, upstream, idle) }()
+
+	err = <-done
+	if errors.Is(err, errMessageTooLarge) {
+		StreamClosed(ctx, StreamMessageSize)
+		metrics.Counter("upgrade_rejected_total", 1, map[string]string{"target": p.Target.Host, "reason": "message_size"})
+		conn.Write(wsCloseTooLarge)
+	}
+
+	closeAll()
+	<-done
+
+	if err != nil && ctx.Err() == nil {
+		p.Logger.Debug("tunnel closed : ", err)
+	}
+
+	p.Logger.Info("tunnel done")
+}
+
+func (p *proxyServer) pipe(ctx context.Context, dst io.Writer, src io.Reader, idle *time.Timer) error {
+
+	buf := copyBuffers.Get().(*[]byte)
+	defer copyBuffers.Put(buf)
+
+	for {
+		n, err := src.Read(*buf)
+		if n > 0 {
+			if idle != nil {
+				idle.Reset(p.upgradeIdleTimeout)
+			}
+
+			if _, err := dst.Write((*buf)[:n]); err != nil {
+				return err
+			}
+
+			StreamTransferred(ctx, n)
+		}
+
+		if err != nil {
+			if err == io.EOF {
+				return nil
+			}
+			return err
+		}
+	}
+}
+
+func writeUpgradeResponse(brw *bufio.ReadWriter, status string, header http.Header) error {
+
+	if _, err := fmt.Fprintf(brw, "HTTP/1.1 %s\r\n", status); err != nil {
+		return err
+	}
+
+	if err := header.Write(brw); err != nil {
+		return err
+	}
+
+	if _, err := brw.WriteString("\r\n"); err != nil {
+		return err
+	}
+
+	return brw.Flush()
+}
@@ -0,0 +1,185 @@
+package wx
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"sync"
+	"time"
+)
+
+var errMessageTooLarge = errors.New("websocket message too large")
+
+var wsCloseTooLarge = []byte{0x88, 0x02, 0x03, 0xf1}
+
+type UpgradePolicy struct {
+	Role           string
+	MaxMessageSize int64
+	MaxLifetime    time.Duration
+	MaxPerUser     int
+}
+
+func WithUpgradePolicy(policy UpgradePolicy) proxyOpt {
+	return func(p *proxyServer) {
+		p.upgradePolicy = policy
+		p.upgradesPerUser = map[string]int{}
+	}
+}
+
+func isUpgrade(r *http.Request) bool {
+	return r.Header.Get("Upgrade") != "" && headerContains(r.Header, "Connection", "upgrade")
+}
+
+func isWebSocket(r *http.Request) bool {
+	return strings.EqualFold(r.Header.Get("Upgrade"), "websocket")
+}
+
+func headerContains(header http.Header, name string, token string) bool {
+	for _, value := range header.Values(name) {
+		for _, part := range strings.Split(value, ",") {
+			if strings.EqualFold(strings.TrimSpace(part), token) {
+				return true
+			}
+		}
+	}
+	return false
+}
+
+func (p *proxyServer) admitUpgrade(r *http.Request) (func(), error) {
+
+	policy := p.upgradePolicy
+	identity, authenticated := UserFromContext(r.Context())
+
+	reject := func(reason string, err error) (func(), error) {
+		MetricsFromContext(r.Context()).Counter("upgrade_rejected_total", 1, map[string]string{"target": p.Target.Host, "reason": reason})
+		return nil, err
+	}
+
+	if policy.Role != "" {
+		if !authenticated {
+			return reject("unauthenticated", errMissingCredentials)
+		}
+		if !identity.HasRole(policy.Role) {
+			Audit(r, AuditAuthorizationDenied, map[string]string{"path": r.URL.Path, "role": policy.Role})
+			return reject("role", fmt.Errorf("%w: missing role %v", ErrForbidden, policy.Role))
+		}
+	}
+
+	if policy.MaxPerUser <= 0 || !authenticated {
+		return func() {}, nil
+	}
+
+	p.upgradeMutex.Lock()
+	defer p.upgradeMutex.Unlock()
+
+	if p.upgradesPerUser[identity.Subject] >= policy.MaxPerUser {
+		return reject("per_user", fmt.Errorf("%w: %d connections open", ErrTooManyRequests, policy.MaxPerUser))
+	}
+
+	p.upgradesPerUser[identity.Subject]++
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			p.upgradeMutex.Lock()
+			defer p.upgradeMutex.Unlock()
+
+			if p.upgradesPerUser[identity.Subject]--; p.upgradesPerUser[identity.Subject] <= 0 {
+				delete(p.upgradesPerUser, identity.Subject)
+			}
+		})
+	}, nil
+}
+
+type wsMessageLimiter struct {
+	io.Reader
+	max       int64
+	header    []byte
+	remaining int64
+	message   int64
+}
+
+func (l *wsMessageLimiter) Read(p []byte) (int, error) {
+
+	n, err := l.Reader.Read(p)
+
+	for i := 0; i < n; {
+		if l.remaining > 0 {
+			take := int64(n - i)
+			if take > l.remaining {
+				take = l.remaining
+			}
+			l.remaining -= take
+			i += int(take)
+			continue
+		}
+
+		l.header = append(l.header, p[i])
+		i++
+
+		size := wsHeaderSize(l.header)
+		if size < 0 || len(l.header) < size {
+			continue
+		}
+
+		fin, opcode, length := wsFrame(l.header)
+		l.header = l.header[:0]
+		l.remaining = length
+
+		if opcode >= 0x8 {
+			continue
+		}
+
+		if opcode != 0 {
+			l.message = 0
+		}
+
+		if l.message += length; l.message > l.max {
+			return i, errMessageTooLarge
+		}
+
+		if fin {
+			l.message = 0
+		}
+	}
+
+	return n, err
+}
+
+func wsHeaderSize(header []byte) int {
+	if len(header) < 2 {
+		return -1
+	}
+
+	size := 2
+	switch header[1] & 0x7f {
+	case 126:
+		size += 2
+	case 127:
+		size += 8
+	}
+
+	if header[1]&0x80 != 0 {
+		size += 4
+	}
+
+	return size
+}
+
+func wsFrame(header []byte) (bool, byte, int64) {
+
+	fin := header[0]&0x80 != 0
+	opcode := header[0] & 0x0f
+
+	switch length := header[1] & 0x7f; length {
+	case 126:
+		return fin, opcode, int64(binary.BigEndian.Uint16(header[2:4]))
+	case 127:
+		return fin, opcode, int64(binary.BigEndian.Uint64(header[2:10]) & (1<<63 - 1))
+	default:
+		return fin, opcode, int64(length)
+	}
+}
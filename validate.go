@@ -0,0 +1,216 @@
+package wx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	"golang.org/x/oauth2"
+)
+
+func validateRedirectPath(config oauth2.Config, serverConfig *serverConfig) error {
+
+	redirectUrl, err := url.Parse(config.RedirectURL)
+	if err != nil || redirectUrl.Path == serverConfig.authPath+"/callback" {
+		return nil
+	}
+
+	return fmt.Errorf("oauth : redirect url path %q does not match callback route %q", redirectUrl.Path, serverConfig.authPath+"/callback")
+}
+
+func Validate(target *url.URL, config oauth2.Config, opts ...serverOpt) error {
+
+	serverConfig := newServerConfig(opts...)
+
+	auth := &authServer{
+		authCookieName:  "auth",
+		stateCookieName: "state",
+	}
+
+	for _, opt := range serverConfig.authOpts {
+		opt(auth)
+	}
+
+	errs := []error{}
+
+	if config.ClientID == "" {
+		errs = append(errs, errors.New("oauth : client id is empty"))
+	}
+
+	if config.ClientSecret == "" && auth.clientSecret == nil && !auth.pkce {
+		errs = append(errs, errors.New("oauth : client secret is empty and no client secret source is configured"))
+	}
+
+	if err := validateURL(config.Endpoint.AuthURL); err != nil {
+		errs = append(errs, fmt.Errorf("oauth : auth url : %w", err))
+	}
+
+	if err := validateURL(config.Endpoint.TokenURL); err != nil {
+		errs = append(errs, fmt.Errorf("oauth : token url : %w", err))
+	}
+
+	if err := validateURL(config.RedirectURL); err != nil {
+		errs = append(errs, fmt.Errorf("oauth : redirect url : %w", err))
+	}
+
+	if target == nil {
+		errs = append(errs, errors.New("proxy : target is not set"))
+	} else if err := validateURL(target.String()); err != nil {
+		errs = append(errs, fmt.Errorf("proxy : target : %w", err))
+	}
+
+	for _, name := range []string{auth.authCookieName, auth.stateCookieName} {
+		if err := (&http.Cookie{Name: name, Value: "x"}).Valid(); err != nil {
+			errs = append(errs, fmt.Errorf("cookie : %w", err))
+		}
+	}
+
+	if auth.authCookieName == auth.stateCookieName {
+		errs = append(errs, fmt.Errorf("cookie : auth and state cookies share the name %q", auth.authCookieName))
+	}
+
+	if auth.keyring == nil && auth.jwks == nil && !auth.unverified {
+		errs = append(errs, errors.New("auth : session cookies cannot be verified : configure WithCookieKeyring or WithJWKS, or opt out with WithUnverifiedSessions"))
+	}
+
+	if auth.jwks != nil {
+		if err := validateURL(auth.jwks.issuer); err != nil {
+			errs = append(errs, fmt.Errorf("auth : jwks issuer : %w", err))
+		}
+
+		if auth.jwks.audience == "" {
+			errs = append(errs, errors.New("auth : jwks audience is empty"))
+		}
+	}
+
+	if serverConfig.minter != nil && auth.keyring == nil {
+		errs = append(errs, errors.New("token minter : minting requires sealed session cookies : configure WithCookieKeyring"))
+	}
+
+	if len(auth.scopeAllowlist) > 0 && auth.keyring == nil {
+		errs = append(errs, errors.New("auth : scope allowlist requires sealed scope cookies : configure WithCookieKeyring"))
+	}
+
+	if auth.lifetimes != nil && auth.keyring == nil {
+		errs = append(errs, fmt.Errorf("auth : %w : configure WithCookieKeyring", errSessionKeyring))
+	}
+
+	if auth.trustedHeaders != nil && len(auth.trustedHeaders.Sources) == 0 {
+		errs = append(errs, errors.New("auth : trusted headers enabled without trusted sources"))
+	}
+
+	if auth.strictOIDC != nil {
+		if err := validateURL(auth.strictOIDC.Issuer); err != nil {
+			errs = append(errs, fmt.Errorf("auth : strict oidc issuer : %w", err))
+		}
+	}
+
+	if serverConfig.admin && serverConfig.adminRole == "" {
+		errs = append(errs, errors.New("admin : role is empty"))
+	}
+
+	if serverConfig.debug && serverConfig.debugRole == "" {
+		errs = append(errs, errors.New("debug : role is empty"))
+	}
+
+	if !strings.HasPrefix(serverConfig.authPath, "/") {
+		errs = append(errs, fmt.Errorf("routes : auth path %q must start with /", serverConfig.authPath))
+	}
+
+	errs = append(errs, validateRoutes(target, serverConfig)...)
+
+	return errors.Join(errs...)
+}
+
+func validateURL(rawURL string) error {
+
+	if rawURL == "" {
+		return errors.New("not set")
+	}
+
+	parsed, err := url.Parse(rawURL)
+	if err != nil {
+		return err
+	}
+
+	if parsed.Scheme != "http" && parsed.Scheme != "https" {
+		return fmt.Errorf("%q must be an absolute http or https url", rawURL)
+	}
+
+	if parsed.Host == "" {
+		return fmt.Errorf("%q has no host", rawURL)
+	}
+
+	return nil
+}
+
+func validateRoutes(target *url.URL, config *serverConfig) []error {
+
+	proxyPath := config.proxyPath
+	if proxyPath == "" && target != nil {
+		proxyPath = strings.TrimRight(target.Path, "/") + "/"
+	}
+
+	patterns := map[string]string{
+		config.authPath + "/login":    "auth login",
+		config.authPath + "/logout":   "auth logout",
+		config.authPath + "/callback": "auth callback",
+		config.authPath + "/userinfo": "auth userinfo",
+		config.authPath + "/verify":   "auth verify",
+		config.authPath + "/forward":  "auth forward",
+		config.authPath + "/reauth":   "auth reauth",
+		config.authPath + "/switch":   "auth switch",
+		config.authPath + "/accounts": "auth accounts",
+		"/":                           "handler",
+	}
+
+	if proxyPath != "" {
+		if owner, found := patterns[proxyPath]; found {
+			return []error{fmt.Errorf("routes : proxy path %q conflicts with %v", proxyPath, owner)}
+		}
+		patterns[proxyPath] = "proxy"
+	}
+
+	if config.minter != nil {
+		patterns[config.authPath+"/jwks"] = "auth jwks"
+	}
+
+	if config.cachePool != nil {
+		patterns[cachePoolPath] = "cache pool"
+	}
+
+	if config.impersonationKeyring != nil {
+		patterns[config.authPath+"/impersonate"] = "auth impersonate"
+		patterns[config.authPath+"/impersonate/stop"] = "auth impersonate"
+	}
+
+	if config.readiness {
+		patterns["/readyz"] = "readiness"
+	}
+
+	if config.version {
+		patterns["/version"] = "version"
+	}
+
+	if config.debug {
+		patterns["/debug/"] = "debug"
+	}
+
+	if config.admin {
+		patterns["/admin/"] = "admin"
+	}
+
+	errs := []error{}
+
+	for _, route := range config.routes {
+		if owner, found := patterns[route.pattern]; found {
+			errs = append(errs, fmt.Errorf("routes : route %q conflicts with %v", route.pattern, owner))
+			continue
+		}
+		patterns[route.pattern] = "route"
+	}
+
+	return errs
+}
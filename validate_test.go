@@ -0,0 +1,139 @@
+package wx
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"testing"
+
+	"golang.org/x/oauth2"
+)
+
+func oauth2Config() oauth2.Config {
+	return oauth2.Config{
+		ClientID:     "client",
+		ClientSecret: "secret",
+		RedirectURL:  "https://wx.example.com/auth/callback",
+		Endpoint: oauth2.Endpoint{
+			AuthURL:  "https://idp.example.com/authorize",
+			TokenURL: "https://idp.example.com/token",
+		},
+	}
+}
+
+func containsError(err error, prefix string) bool {
+
+	var joined interface{ Unwrap() []error }
+	if !errors.As(err, &joined) {
+		return err != nil && strings.HasPrefix(err.Error(), prefix)
+	}
+
+	for _, err := range joined.Unwrap() {
+		if strings.HasPrefix(err.Error(), prefix) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func TestNewWebServerRejectsInvalidConfig(t *testing.T) {
+
+	target, err := url.Parse("https://upstream.example.com/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := oauth2Config()
+	invalid.ClientID = ""
+
+	rewritten := oauth2Config()
+	rewritten.RedirectURL = "https://wx.example.com/sso/callback"
+
+	sealed := WithAuthOptions(WithCookieKeyring(NewKeyring([]byte("cookie-key"))))
+
+	tests := []struct {
+		name   string
+		config oauth2.Config
+		opts   []serverOpt
+		valid  bool
+	}{
+		{"valid", oauth2Config(), []serverOpt{sealed}, true},
+		{"missing client id", invalid, []serverOpt{sealed}, false},
+		{"unverifiable sessions", oauth2Config(), nil, false},
+		{"unverified sessions opt in", oauth2Config(), []serverOpt{WithAuthOptions(WithUnverifiedSessions())}, true},
+		{"empty admin role", oauth2Config(), []serverOpt{sealed, WithAdmin("")}, false},
+		{"redirect path behind a rewrite", rewritten, []serverOpt{sealed}, true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+			handler, err := NewWebServerE(nopLogger{}, target, test.config, http.NotFoundHandler(), test.opts...)
+			if valid := err == nil && handler != nil; valid != test.valid {
+				t.Fatalf("expected valid %v, got %v", test.valid, err)
+			}
+		})
+	}
+}
+
+func TestValidateJWKS(t *testing.T) {
+
+	tests := []struct {
+		name  string
+		opt   authOpt
+		fails bool
+	}{
+		{"issuer and audience", WithJWKS("https://idp.example.com/jwks", "https://idp.example.com", "client", 0), false},
+		{"empty issuer", WithJWKS("https://idp.example.com/jwks", "", "client", 0), true},
+		{"empty audience", WithJWKS("https://idp.example.com/jwks", "https://idp.example.com", "", 0), true},
+	}
+
+	for _, test := range tests {
+		t.Run(test.name, func(t *testing.T) {
+
+			err := Validate(nil, oauth2Config(), WithAuthOptions(test.opt))
+
+			if failed := containsError(err, "auth : jwks"); failed != test.fails {
+				t.Fatalf("expected failure %v, got %v", test.fails, err)
+			}
+		})
+	}
+}
+
+type recordingLogger struct {
+	nopLogger
+	errors []string
+	infos  []string
+}
+
+func (l *recordingLogger) Infof(format string, a ...interface{}) {
+	l.infos = append(l.infos, fmt.Sprintf(format, a...))
+}
+
+func (l *recordingLogger) Errorf(format string, a ...interface{}) {
+	l.errors = append(l.errors, fmt.Sprintf(format, a...))
+}
+
+func TestNewWebServerLogsInvalidConfig(t *testing.T) {
+
+	target, err := url.Parse("https://upstream.example.com/api")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	invalid := oauth2Config()
+	invalid.ClientID = ""
+
+	logger := &recordingLogger{}
+
+	handler := NewWebServer(logger, target, invalid, http.NotFoundHandler(), WithAuthOptions(WithCookieKeyring(NewKeyring([]byte("cookie-key")))))
+	if handler == nil {
+		t.Fatal("expected handler")
+	}
+
+	if !strings.Contains(strings.Join(logger.errors, "\n"), "oauth : client id is empty") {
+		t.Fatalf("expected logged configuration error, got %v", logger.errors)
+	}
+}
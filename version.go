@@ -0,0 +1,123 @@
+package wx
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime"
+	"runtime/debug"
+)
+
+const modulePath = "github.com/reverted/wx"
+
+var (
+	Version   string
+	Commit    string
+	BuildDate string
+)
+
+type BuildInfo struct {
+	Module    string   `json:"module"`
+	Version   string   `json:"version"`
+	Commit    string   `json:"commit,omitempty"`
+	BuildDate string   `json:"build_date,omitempty"`
+	Modified  bool     `json:"modified,omitempty"`
+	GoVersion string   `json:"go_version"`
+	Features  []string `json:"features"`
+}
+
+func ReadBuildInfo(features ...string) BuildInfo {
+
+	info := BuildInfo{
+		Module:    modulePath,
+		Version:   Version,
+		Commit:    Commit,
+		BuildDate: BuildDate,
+		GoVersion: runtime.Version(),
+		Features:  features,
+	}
+
+	if info.Features == nil {
+		info.Features = []string{}
+	}
+
+	build, ok := debug.ReadBuildInfo()
+	if !ok {
+		return info
+	}
+
+	if info.Version == "" {
+		info.Version = moduleVersion(build)
+	}
+
+	for _, setting := range build.Settings {
+		switch setting.Key {
+		case "vcs.revision":
+			if info.Commit == "" {
+				info.Commit = setting.Value
+			}
+		case "vcs.time":
+			if info.BuildDate == "" {
+				info.BuildDate = setting.Value
+			}
+		case "vcs.modified":
+			info.Modified = setting.Value == "true"
+		}
+	}
+
+	return info
+}
+
+func moduleVersion(build *debug.BuildInfo) string {
+
+	if build.Main.Path == modulePath {
+		return build.Main.Version
+	}
+
+	for _, dep := range build.Deps {
+		if dep.Path == modulePath {
+			if dep.Replace != nil {
+				return dep.Replace.Version
+			}
+			return dep.Version
+		}
+	}
+
+	return ""
+}
+
+func (b BuildInfo) ServedBy() string {
+
+	value := "wx/" + b.Version
+	if b.Version == "" {
+		value = "wx"
+	}
+
+	if b.Commit != "" {
+		value += fmt.Sprintf(" (%.12s)", b.Commit)
+	}
+
+	if host, err := os.Hostname(); err == nil {
+		value = host + " " + value
+	}
+
+	return value
+}
+
+func NewVersionHandler(info BuildInfo) http.HandlerFunc {
+	return func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "application/json")
+		json.NewEncoder(w).Encode(info)
+	}
+}
+
+func NewWithServedBy(info BuildInfo, handler http.Handler) http.Handler {
+
+	servedBy := info.ServedBy()
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("X-Served-By", servedBy)
+		handler.ServeHTTP(w, r)
+	})
+}